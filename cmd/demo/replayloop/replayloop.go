@@ -14,6 +14,7 @@ import (
 	commonv1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/common/v1"
 	eventv1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/event/v1"
 	providerv1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/provider/v1"
+	trackv1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/track/v1"
 	"github.com/google/uuid"
 	"github.com/spf13/cobra"
 	"google.golang.org/grpc"
@@ -45,7 +46,9 @@ func NewReplayLoopCmd() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "replayloop",
 		Short: "replay events in an inifinite loop.",
-
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			return replay.ValidateSpeed(cfg.Speed)
+		},
 		Run: func(cmd *cobra.Command, args []string) {
 			replayLoop(cmd.Context())
 		},
@@ -61,12 +64,14 @@ func NewReplayLoopCmd() *cobra.Command {
 	cmd.Flags().BoolVar(&useJobIDKey,
 		"use-jobid-key", true,
 		"use job id for event key")
+	cmd.Flags().DurationVar(&myStress.WorkerProgress,
+		"worker-stats", 0, "interval for showing worker progress stats (example: \"10s\")")
 	cmd.Flags().DurationVar(&myStress.Pause,
 		"pause", 2*time.Second,
 		"max. pause before next iteration is issued (will use random value)")
 
-	cmd.Flags().IntVar(&cfg.Speed, "speed", 1,
-		"Recording speed (0 means: go as fast as possible)")
+	cmd.Flags().Float64Var(&cfg.Speed, "speed", 1.0,
+		"Replay speed multiplier, e.g. 0.5 or 1.5 (0 means: go as fast as possible)")
 	cmd.Flags().StringVar(&cfg.SourceAddr,
 		"source-addr",
 		"",
@@ -76,6 +81,15 @@ func NewReplayLoopCmd() *cobra.Command {
 		false,
 		"connect gRPC address without TLS (development only)")
 
+	cmd.Flags().StringVar(&cfg.SourceFile,
+		"source-file",
+		"",
+		"replay file providing the event data (instead of source-addr)")
+	cmd.MarkFlagsMutuallyExclusive("source-addr", "source-file")
+	cmd.Flags().StringVar(&cfg.SourceMode,
+		"source-mode", cfg.SourceMode,
+		"how data is loaded from source (stream, page)")
+
 	cmd.Flags().StringVarP(&cfg.Token,
 		"token", "t", "", "authentication token")
 
@@ -87,6 +101,10 @@ func NewReplayLoopCmd() *cobra.Command {
 		"replay this duration with max speed (relative to first event timestamp)")
 	cmd.Flags().BoolVar(&cfg.FFPreRace,
 		"ff-prerace", true, "fast forward prerace events")
+	cmd.Flags().BoolVar(&cfg.Anonymize,
+		"anonymize", false, "replace driver and team names by generic names")
+	cmd.Flags().BoolVar(&cfg.Retime,
+		"retime", false, "shift timestamps so the event appears to happen now")
 	cmd.Flags().StringVar(&demoPrefix, "demo-prefix",
 		"Demo replay of", "prefix for demo events")
 	cmd.MarkFlagsMutuallyExclusive("include-events", "exclude-events")
@@ -101,17 +119,25 @@ func replayLoop(ctx context.Context) {
 
 	myCtx, cancel := context.WithCancel(ctx)
 	configOptions := myStress.CollectStandardJobProcessorOptions()
+	if cfg.SourceFile == "" {
+		configOptions = append(configOptions,
+			myStress.WithSourceClientProvider(func() *grpc.ClientConn {
+				c, err := util.NewClient(cfg.SourceAddr,
+					util.WithTLSEnabled(!cfg.SourceInsecure))
+				if err != nil {
+					logger.Fatal("could not connect source server", log.ErrorField(err))
+				}
+				logger.Debug("connected to source server")
+				return c
+			}))
+	}
+	summary := replay.NewStatsSummary(myStress.WorkerThreads, logger.Named("summary"))
 	configOptions = append(configOptions,
 		myStress.WithLogging(logger),
-		myStress.WithContext(myCtx),
-		myStress.WithSourceClientProvider(func() *grpc.ClientConn {
-			c, err := util.NewClient(cfg.SourceAddr, util.WithTLSEnabled(!cfg.SourceInsecure))
-			if err != nil {
-				logger.Fatal("could not connect source server", log.ErrorField(err))
-			}
-			logger.Debug("connected to source server")
-			return c
+		myStress.WithFinishHandler(func(jobsDone int, stats *[]myStress.WorkerStats) {
+			summary.Output(len(*stats))
 		}),
+		myStress.WithContext(myCtx),
 		myStress.WithTargetClientProvider(func() *grpc.ClientConn {
 			c, err := util.ConnectGrpc(config.DefaultCliArgs())
 			if err != nil {
@@ -121,6 +147,9 @@ func replayLoop(ctx context.Context) {
 			return c
 		}),
 		myStress.WithJobHandler(func(j *myStress.Job) error {
+			if cfg.SourceFile != "" {
+				return replayFile(j, summary)
+			}
 			req := eventv1.GetLatestEventsRequest{}
 			c := eventv1grpc.NewEventServiceClient(j.SourceClient)
 			r, err := c.GetLatestEvents(context.Background(), &req)
@@ -178,64 +207,16 @@ func replayLoop(ctx context.Context) {
 				j.Logger.Error("could not get events", log.ErrorField(err))
 				return err
 			}
-			opts := []replay.ReplayOption{}
-
-			var ctx context.Context
-			var jobCancel context.CancelFunc
-			ctx, jobCancel = context.WithCancel(j.Ctx)
-
-			if jobDuration > 0 {
-				d := jobDuration
-				if !jobDurationFixed {
-					//nolint:gosec // ok here
-					d = time.Duration((1 + rand.Intn(int(d.Seconds()))) * int(time.Second))
-				}
-				ctx, jobCancel = context.WithTimeout(j.Ctx, d)
-				deadLine, _ := ctx.Deadline()
-				j.Logger.Info("job param",
-					log.Duration("duration", d),
-					log.Time("deadline", deadLine))
-			}
+			opts, jobCancel := jobReplayOptions(j)
 			defer jobCancel()
 
-			opts = append(opts, replay.WithFastForward(cfg.FastForward))
-
-			if cfg.Speed > 0 {
-				opts = append(opts, replay.WithSpeed(cfg.Speed))
-			}
-			opts = append(opts, replay.WithContext(ctx))
-			if cfg.Token != "" {
-				opts = append(opts, replay.WithTokenProvider(func() string {
-					return cfg.Token
-				}))
+			dp, err := cfg.NewDataProvider(j.SourceClient, e.Id,
+				demoRequestProvider(j, e, eventResp.Track))
+			if err != nil {
+				j.Logger.Error("could not create data provider", log.ErrorField(err))
+				return err
 			}
 
-			dp := replay.NewDataProvider(j.SourceClient, e.Id,
-				func() *providerv1.RegisterEventRequest {
-					recordingMode := func() providerv1.RecordingMode {
-						if cfg.DoNotPersist {
-							return providerv1.RecordingMode_RECORDING_MODE_DO_NOT_PERSIST
-						} else {
-							return providerv1.RecordingMode_RECORDING_MODE_PERSIST
-						}
-					}
-					//nolint:errcheck // ok here
-					demoEvent := proto.Clone(e).(*eventv1.Event)
-					if useJobIDKey {
-						demoEvent.Key = fmt.Sprintf("demo-worker-%d", j.ID)
-						e.Key = fmt.Sprintf("demo-worker-%d", j.ID)
-					} else {
-						demoEvent.Key = uuid.New().String()
-					}
-					demoEvent.Name = demoPrefix + " " + e.Name
-					return &providerv1.RegisterEventRequest{
-						Key:           demoEvent.Key,
-						Event:         demoEvent,
-						Track:         eventResp.Track,
-						RecordingMode: recordingMode(),
-					}
-				})
-
 			testMode := false
 			if testMode {
 				j.Logger.Debug("picked event", log.Uint32("id", e.Id), log.String("name", e.Name))
@@ -247,6 +228,8 @@ func replayLoop(ctx context.Context) {
 				if err := rt.Replay(e.Id); err != nil {
 					j.Logger.Error("error replaying event", log.ErrorField(err))
 				}
+				stats := rt.GetStats()
+				summary.AddStats(j.WorkerID, &stats)
 			}
 			mutex.Lock()
 			activeReplayEvents = slices.DeleteFunc(activeReplayEvents,
@@ -283,3 +266,102 @@ func replayLoop(ctx context.Context) {
 		}
 	}
 }
+
+// replayFile replays the event stored in the replay file
+func replayFile(j *myStress.Job, summary *replay.StatsSummary) error {
+	stored, err := replay.ReadEventFromFile(cfg.SourceFile)
+	if err != nil {
+		j.Logger.Error("could not read replay file", log.ErrorField(err))
+		return err
+	}
+	opts, jobCancel := jobReplayOptions(j)
+	defer jobCancel()
+
+	dp, err := replay.NewFileDataProvider(cfg.SourceFile,
+		demoRequestProvider(j, stored.Event, stored.Track))
+	if err != nil {
+		j.Logger.Error("could not create data provider", log.ErrorField(err))
+		return err
+	}
+	rt := replay.NewReplayTask(j.TargetClient, dp, opts...)
+	if err := rt.Replay(stored.Event.Id); err != nil {
+		j.Logger.Error("error replaying event", log.ErrorField(err))
+	}
+	stats := rt.GetStats()
+	summary.AddStats(j.WorkerID, &stats)
+	return nil
+}
+
+// jobReplayOptions returns the replay options for a job.
+// The returned cancel func has to be called when the job is done.
+func jobReplayOptions(j *myStress.Job) ([]replay.ReplayOption, context.CancelFunc) {
+	opts := []replay.ReplayOption{}
+
+	var ctx context.Context
+	var jobCancel context.CancelFunc
+	ctx, jobCancel = context.WithCancel(j.Ctx)
+
+	if jobDuration > 0 {
+		jobCancel()
+		d := jobDuration
+		if !jobDurationFixed {
+			//nolint:gosec // ok here
+			d = time.Duration((1 + rand.Intn(int(d.Seconds()))) * int(time.Second))
+		}
+		ctx, jobCancel = context.WithTimeout(j.Ctx, d)
+		deadLine, _ := ctx.Deadline()
+		j.Logger.Info("job param",
+			log.Duration("duration", d),
+			log.Time("deadline", deadLine))
+	}
+
+	opts = append(opts, replay.WithFastForward(cfg.FastForward))
+
+	opts = append(opts, replay.WithSpeed(cfg.Speed))
+	opts = append(opts, replay.WithContext(ctx))
+	opts = append(opts, replay.WithTransformer(cfg.Transformers()...))
+	if myStress.WorkerProgress > 0 {
+		opts = append(opts, replay.WithStatsCallback(
+			myStress.WorkerProgress, func(s *replay.Stats) {
+				j.Logger.Info("stats", log.String("progress", s.Progress()))
+			}))
+	}
+	if cfg.Token != "" {
+		opts = append(opts, replay.WithTokenProvider(func() string {
+			return cfg.Token
+		}))
+	}
+	return opts, jobCancel
+}
+
+//nolint:whitespace // by design
+func demoRequestProvider(
+	j *myStress.Job,
+	e *eventv1.Event,
+	track *trackv1.Track,
+) replay.ProvideEventRequest {
+	return func() *providerv1.RegisterEventRequest {
+		recordingMode := func() providerv1.RecordingMode {
+			if cfg.DoNotPersist {
+				return providerv1.RecordingMode_RECORDING_MODE_DO_NOT_PERSIST
+			} else {
+				return providerv1.RecordingMode_RECORDING_MODE_PERSIST
+			}
+		}
+		//nolint:errcheck // ok here
+		demoEvent := proto.Clone(e).(*eventv1.Event)
+		if useJobIDKey {
+			demoEvent.Key = fmt.Sprintf("demo-worker-%d", j.ID)
+			e.Key = fmt.Sprintf("demo-worker-%d", j.ID)
+		} else {
+			demoEvent.Key = uuid.New().String()
+		}
+		demoEvent.Name = demoPrefix + " " + e.Name
+		return &providerv1.RegisterEventRequest{
+			Key:           demoEvent.Key,
+			Event:         demoEvent,
+			Track:         track,
+			RecordingMode: recordingMode(),
+		}
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"github.com/mpapenbr/iracelog-cli/cmd/event/check"
 	"github.com/mpapenbr/iracelog-cli/cmd/event/deleteit"
 	"github.com/mpapenbr/iracelog-cli/cmd/event/edit"
+	"github.com/mpapenbr/iracelog-cli/cmd/event/export"
 	"github.com/mpapenbr/iracelog-cli/cmd/event/list"
 	"github.com/mpapenbr/iracelog-cli/cmd/event/load"
 	"github.com/mpapenbr/iracelog-cli/cmd/event/replay"
@@ -29,6 +30,7 @@ func NewEventCmd() *cobra.Command {
 	cmd.AddCommand(load.NewEventLoadCmd())
 	cmd.AddCommand(edit.NewEventEditCmd())
 	cmd.AddCommand(replay.NewEventReplayCmd())
+	cmd.AddCommand(export.NewEventExportCmd())
 	cmd.AddCommand(session.NewEventSessionCmd())
 	cmd.AddCommand(check.NewCheckCmd())
 	cmd.AddCommand(state.NewStateCmd())
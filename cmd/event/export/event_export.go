@@ -0,0 +1,133 @@
+package export
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"strings"
+
+	eventv1grpc "buf.build/gen/go/mpapenbr/iracelog/grpc/go/iracelog/event/v1/eventv1grpc"
+	eventv1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/event/v1"
+	providerv1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/provider/v1"
+	"github.com/spf13/cobra"
+
+	"github.com/mpapenbr/iracelog-cli/config"
+	"github.com/mpapenbr/iracelog-cli/log"
+	"github.com/mpapenbr/iracelog-cli/util"
+	"github.com/mpapenbr/iracelog-cli/util/replay"
+)
+
+var (
+	outFile string
+	cfg     = replay.DefaultConfig()
+)
+
+func NewEventExportCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "export [event]",
+		Short: "export a stored event to a replay file.",
+		Long: `Export a stored event to a replay file.
+The file can be replayed with 'event replay --source-file'.
+Files ending with .gz are written gzip compressed.`,
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			return nil
+		},
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			exportEvent(cmd.Context(), args[0])
+		},
+	}
+	cmd.Flags().StringVar(&outFile, "out", "",
+		"replay file to write")
+	//nolint:errcheck // by design
+	cmd.MarkFlagRequired("out")
+	cmd.Flags().StringVar(&cfg.SourceMode,
+		"source-mode", cfg.SourceMode,
+		"how data is loaded from the server (stream, page)")
+	cmd.Flags().IntVar(&cfg.PageSize,
+		"page-size", cfg.PageSize, "number of items requested per call")
+	return cmd
+}
+
+func exportEvent(ctx context.Context, arg string) {
+	log.Info("connect ism ", log.String("addr", config.DefaultCliArgs().Addr))
+	conn, err := util.ConnectGrpc(config.DefaultCliArgs())
+	if err != nil {
+		log.Error("did not connect", log.ErrorField(err))
+		return
+	}
+	defer conn.Close()
+
+	req := eventv1.GetEventRequest{
+		EventSelector: util.ResolveEvent(arg),
+	}
+	c := eventv1grpc.NewEventServiceClient(conn)
+	e, err := c.GetEvent(ctx, &req)
+	if err != nil {
+		log.Error("could not load event", log.ErrorField(err), log.String("event", arg))
+		return
+	}
+	log.Info("Event loaded.",
+		log.String("event", e.Event.Name),
+		log.Uint32("id", e.Event.Id))
+
+	dp, err := cfg.NewDataProvider(conn, e.Event.Id,
+		func() *providerv1.RegisterEventRequest {
+			return &providerv1.RegisterEventRequest{
+				Key:   e.Event.Key,
+				Event: e.Event,
+				Track: e.Track,
+			}
+		})
+	if err != nil {
+		log.Error("could not create data provider", log.ErrorField(err))
+		return
+	}
+	defer dp.(interface{ Close() }).Close()
+
+	stats, err := writeFile(outFile, func(w io.Writer) (*replay.ExportStats, error) {
+		return replay.Export(w, dp, e.Event.Id)
+	})
+	if err != nil {
+		log.Error("could not export event", log.ErrorField(err))
+		return
+	}
+	log.Info("Event exported",
+		log.String("file", outFile),
+		log.Int("states", stats.States),
+		log.Int("drivers", stats.Drivers),
+		log.Int("speedmaps", stats.Speedmaps))
+}
+
+// writeFile creates the file and calls write with a (gzip compressing) writer
+//
+//nolint:whitespace // by design
+func writeFile(
+	filename string,
+	write func(w io.Writer) (*replay.ExportStats, error),
+) (stats *replay.ExportStats, err error) {
+	f, err := os.Create(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { err = errors.Join(err, f.Close()) }()
+	buf := bufio.NewWriter(f)
+	var w io.Writer = buf
+	var gz *gzip.Writer
+	if strings.HasSuffix(filename, ".gz") {
+		gz = gzip.NewWriter(buf)
+		w = gz
+	}
+	if stats, err = write(w); err != nil {
+		return stats, err
+	}
+	if gz != nil {
+		if err = gz.Close(); err != nil {
+			return stats, err
+		}
+	}
+	return stats, buf.Flush()
+}
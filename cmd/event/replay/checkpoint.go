@@ -0,0 +1,54 @@
+package replay
+
+import (
+	"github.com/mpapenbr/iracelog-cli/log"
+	"github.com/mpapenbr/iracelog-cli/util/replay"
+)
+
+// prepareResume reads the checkpoint of the replay to resume (if any).
+// Event key, source and checkpoint file are taken from the checkpoint unless
+// they are given explicitly.
+func prepareResume(args *[]string) (*replay.Checkpoint, error) {
+	if cfg.Resume == "" {
+		return nil, nil
+	}
+	cp, err := replay.ReadCheckpoint(cfg.Resume)
+	if err != nil {
+		return nil, err
+	}
+	log.Info("Resuming replay",
+		log.String("key", cp.EventKey),
+		log.String("checkpoint", cfg.Resume))
+	cfg.EventKey = cp.EventKey
+	if cfg.SourceFile == "" && len(*args) == 0 {
+		if cp.SourceFile != "" {
+			cfg.SourceFile = cp.SourceFile
+		} else {
+			*args = []string{cp.Event}
+		}
+	}
+	if cfg.Checkpoint == "" {
+		cfg.Checkpoint = cfg.Resume
+	}
+	return cp, nil
+}
+
+//nolint:whitespace // by design
+func checkpointOptions(
+	args []string,
+	resume *replay.Checkpoint,
+) []replay.ReplayOption {
+	opts := []replay.ReplayOption{}
+	if resume != nil {
+		opts = append(opts, replay.WithResume(resume))
+	}
+	if cfg.Checkpoint != "" {
+		cp := &replay.Checkpoint{SourceFile: cfg.SourceFile}
+		if len(args) > 0 {
+			cp.Event = args[0]
+		}
+		opts = append(opts, replay.WithCheckpoint(
+			cfg.Checkpoint, cfg.CheckpointInterval, cp))
+	}
+	return opts
+}
@@ -0,0 +1,152 @@
+package replay
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mpapenbr/iracelog-cli/log"
+	"github.com/mpapenbr/iracelog-cli/util/replay"
+)
+
+var (
+	interactive   bool
+	controlSocket string
+)
+
+const controlHelp = `commands:
+  p, pause         pause the replay
+  r, resume        resume the replay
+  s, speed <n>     set replay speed, e.g. 0.5 (0: as fast as possible)
+  g, seek <time>   seek to session time (seconds, duration like 1h5m or +5m)
+  i, status        show current position
+  h, help          show this help`
+
+// controller handles line based commands for a running replay task
+type controller struct {
+	task *replay.ReplayTask
+}
+
+// startControl enables stdin commands and/or the control socket (if configured).
+// The returned func has to be called when the replay is done.
+func startControl(task *replay.ReplayTask) func() {
+	c := &controller{task: task}
+	cleanup := func() {}
+	if interactive {
+		fmt.Fprintln(os.Stderr, controlHelp)
+		go c.handle(os.Stdin, os.Stderr)
+	}
+	if controlSocket != "" {
+		l, err := (&net.ListenConfig{}).Listen(
+			context.Background(), "unix", controlSocket)
+		if err != nil {
+			log.Error("could not create control socket",
+				log.String("socket", controlSocket),
+				log.ErrorField(err))
+			return cleanup
+		}
+		log.Info("control socket created", log.String("socket", controlSocket))
+		go func() {
+			for {
+				conn, err := l.Accept()
+				if err != nil {
+					return
+				}
+				go func() {
+					defer conn.Close()
+					c.handle(conn, conn)
+				}()
+			}
+		}()
+		cleanup = func() {
+			l.Close()
+			os.Remove(controlSocket)
+		}
+	}
+	return cleanup
+}
+
+func (c *controller) handle(in io.Reader, out io.Writer) {
+	scanner := bufio.NewScanner(in)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		fmt.Fprintln(out, c.exec(line))
+	}
+}
+
+func (c *controller) exec(line string) string {
+	fields := strings.Fields(line)
+	arg := ""
+	if len(fields) > 1 {
+		arg = fields[1]
+	}
+	switch fields[0] {
+	case "p", "pause":
+		c.task.Pause()
+		return "paused"
+	case "r", "resume":
+		c.task.Resume()
+		return "resumed"
+	case "s", "speed":
+		speed, err := strconv.ParseFloat(arg, 64)
+		if err != nil {
+			return "invalid speed: " + arg
+		}
+		if err := c.task.SetSpeed(speed); err != nil {
+			return err.Error()
+		}
+		return fmt.Sprintf("speed set to %g", speed)
+	case "g", "seek":
+		_, cur := c.task.Position()
+		target, err := parseSeekTarget(arg, cur)
+		if err != nil {
+			return err.Error()
+		}
+		if err := c.task.SeekTo(target); err != nil {
+			return err.Error()
+		}
+		return fmt.Sprintf("seeking to %s", formatSessionTime(target))
+	case "i", "status":
+		num, cur := c.task.Position()
+		return fmt.Sprintf("session %d at %s, speed %g, paused %t",
+			num, formatSessionTime(cur), c.task.Speed(), c.task.IsPaused())
+	case "h", "help", "?":
+		return controlHelp
+	default:
+		return "unknown command: " + fields[0]
+	}
+}
+
+// parseSeekTarget accepts seconds, durations (1h5m) and relative values (+5m)
+func parseSeekTarget(arg string, cur float64) (float64, error) {
+	if arg == "" {
+		return 0, errors.New("missing seek target")
+	}
+	base := 0.0
+	if strings.HasPrefix(arg, "+") {
+		base = cur
+		arg = arg[1:]
+	}
+	if secs, err := strconv.ParseFloat(arg, 64); err == nil {
+		return base + secs, nil
+	}
+	d, err := time.ParseDuration(arg)
+	if err != nil {
+		return 0, fmt.Errorf("invalid seek target: %s", arg)
+	}
+	return base + d.Seconds(), nil
+}
+
+func formatSessionTime(secs float64) string {
+	return (time.Duration(secs * float64(time.Second))).Round(time.Second).String()
+}
@@ -0,0 +1,101 @@
+package replay
+
+import (
+	"bufio"
+	"os"
+	"time"
+
+	"google.golang.org/grpc"
+
+	"github.com/mpapenbr/iracelog-cli/config"
+	"github.com/mpapenbr/iracelog-cli/log"
+	"github.com/mpapenbr/iracelog-cli/util"
+	"github.com/mpapenbr/iracelog-cli/util/replay"
+)
+
+// destinations holds the servers receiving the replay (see --addr and --dest)
+// or the dry-run file (see --dry-run)
+type destinations struct {
+	conns  []*grpc.ClientConn
+	file   *os.File
+	dryRun *bufio.Writer
+}
+
+// connectDestinations connects to all destination servers.
+// No server is used for a dry-run.
+func connectDestinations() (*destinations, error) {
+	ret := &destinations{}
+	if cfg.DryRun != "" {
+		log.Info("dry-run, writing requests", log.String("file", cfg.DryRun))
+		f, err := os.Create(cfg.DryRun)
+		if err != nil {
+			return nil, err
+		}
+		ret.file = f
+		ret.dryRun = bufio.NewWriter(f)
+		return ret, nil
+	}
+	addrs := append([]string{config.DefaultCliArgs().Addr}, destAddrs...)
+	for _, addr := range addrs {
+		log.Info("connect dest server", log.String("addr", addr))
+		conn, err := util.NewClient(addr, util.WithCliArgs(config.DefaultCliArgs()))
+		if err != nil {
+			ret.Close()
+			return nil, err
+		}
+		ret.conns = append(ret.conns, conn)
+	}
+	return ret, nil
+}
+
+func (d *destinations) Close() {
+	for _, c := range d.conns {
+		c.Close()
+	}
+	if d.file != nil {
+		if err := d.dryRun.Flush(); err != nil {
+			log.Error("could not write dry-run file", log.ErrorField(err))
+		}
+		d.file.Close()
+	}
+}
+
+// primary returns the destination passed to the replay task (nil on dry-run)
+func (d *destinations) primary() *grpc.ClientConn {
+	if len(d.conns) == 0 {
+		return nil
+	}
+	return d.conns[0]
+}
+
+// options adds the destinations besides the primary one
+func (d *destinations) options() []replay.ReplayOption {
+	if d.dryRun != nil {
+		return []replay.ReplayOption{replay.WithDryRun(d.dryRun, time.Now())}
+	}
+	if len(d.conns) < 2 {
+		return nil
+	}
+	dests := make([]*replay.Destination, 0, len(d.conns)-1)
+	for i, c := range d.conns[1:] {
+		dest := &replay.Destination{Conn: c}
+		if token := destToken(i + 1); token != "" {
+			dest.TokenProvider = func() string { return token }
+		}
+		dests = append(dests, dest)
+	}
+	return []replay.ReplayOption{replay.WithDestinations(dests...)}
+}
+
+// destToken returns the token for the destination with index i.
+// Tokens are assigned by position, a single token is used for all destinations.
+func destToken(i int) string {
+	switch {
+	case len(destTokens) == 1:
+		return destTokens[0]
+	case i < len(destTokens):
+		return destTokens[i]
+	default:
+		return ""
+	}
+}
@@ -2,37 +2,74 @@ package replay
 
 import (
 	"context"
+	"os"
+	"os/signal"
 	"time"
 
 	eventv1grpc "buf.build/gen/go/mpapenbr/iracelog/grpc/go/iracelog/event/v1/eventv1grpc"
 	eventv1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/event/v1"
 	providerv1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/provider/v1"
+	trackv1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/track/v1"
 	"github.com/google/uuid"
 	"github.com/spf13/cobra"
+	"google.golang.org/grpc"
 
-	"github.com/mpapenbr/iracelog-cli/config"
 	"github.com/mpapenbr/iracelog-cli/log"
 	"github.com/mpapenbr/iracelog-cli/util"
 	"github.com/mpapenbr/iracelog-cli/util/replay"
 )
 
-var cfg *replay.Config
+var (
+	cfg             *replay.Config
+	progress        time.Duration
+	fromSessionTime time.Duration
+	toSessionTime   time.Duration
+	destAddrs       []string
+	destTokens      []string
+)
 
 func init() {
 	cfg = replay.DefaultConfig()
 }
 
+//nolint:funlen // by design
 func NewEventReplayCmd() *cobra.Command {
 	cmd := &cobra.Command{
-		Use:   "replay",
+		Use:   "replay [event]",
 		Short: "replay an event.",
-		Args:  cobra.ExactArgs(1),
+		Long: `replay an event.
+The event is read from the source server or from a replay file (--source-file).
+When using a replay file no event argument is required.
+An interrupted replay can be continued with --resume. The replay uses the
+event key of the checkpoint and continues after the last published data.
+The replay is sent to the server given by --addr. Use --dest (repeatable) to
+send it to further servers at the same time. The tokens given by --token are
+assigned in the same order, a single token is used for all servers.`,
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			// unset bounds are taken from the event, so 0 has to be kept apart
+			if cmd.Flags().Changed("from-session-time") {
+				cfg.FromSessionTime = &fromSessionTime
+			}
+			if cmd.Flags().Changed("to-session-time") {
+				cfg.ToSessionTime = &toSessionTime
+			}
+			return replay.ValidateSpeed(cfg.Speed)
+		},
+		Args: func(cmd *cobra.Command, args []string) error {
+			if cfg.SourceFile != "" {
+				return cobra.NoArgs(cmd, args)
+			}
+			if cfg.Resume != "" {
+				return cobra.MaximumNArgs(1)(cmd, args)
+			}
+			return cobra.ExactArgs(1)(cmd, args)
+		},
 		Run: func(cmd *cobra.Command, args []string) {
-			replayEvent(args[0])
+			replayEvent(cmd.Context(), args)
 		},
 	}
-	cmd.PersistentFlags().IntVar(&cfg.Speed, "speed", 1,
-		"Recording speed (0 means: go as fast as possible)")
+	cmd.PersistentFlags().Float64Var(&cfg.Speed, "speed", 1.0,
+		"Replay speed multiplier, e.g. 0.5 or 1.5 (0 means: go as fast as possible)")
 	cmd.PersistentFlags().StringVar(&cfg.SourceAddr,
 		"source-addr",
 		"",
@@ -41,9 +78,30 @@ func NewEventReplayCmd() *cobra.Command {
 		"source-insecure",
 		false,
 		"connect gRPC address without TLS (development only)")
+	cmd.PersistentFlags().StringVar(&cfg.SourceFile,
+		"source-file",
+		"",
+		"replay file providing the event data (instead of source-addr)")
+	cmd.MarkFlagsMutuallyExclusive("source-addr", "source-file")
+	cmd.Flags().StringVar(&cfg.SourceMode,
+		"source-mode", cfg.SourceMode,
+		"how data is loaded from source (stream, page)")
+	cmd.Flags().IntVar(&cfg.PageSize,
+		"page-size", cfg.PageSize, "number of items requested per call from source")
+	cmd.Flags().BoolVar(&cfg.Prefetch,
+		"prefetch", cfg.Prefetch, "load next page from source in background")
+	cmd.Flags().IntVar(&cfg.FetchRetries,
+		"fetch-retries", cfg.FetchRetries, "retries for failed requests to source")
 
-	cmd.PersistentFlags().StringVarP(&cfg.Token,
-		"token", "t", "", "authentication token")
+	cmd.Flags().StringArrayVar(&destAddrs,
+		"dest", []string{},
+		"additional ISM gRPC address (repeat to replay to multiple servers at once)")
+	cmd.Flags().StringVar(&cfg.DryRun,
+		"dry-run", "",
+		"write the requests with their scheduled send time to this file instead of a server")
+	cmd.PersistentFlags().StringArrayVarP(&destTokens,
+		"token", "t", []string{},
+		"authentication token (repeat to set a token per server: --addr, then each --dest)")
 	cmd.PersistentFlags().StringVar(&cfg.EventKey,
 		"key", "", "event key to use for replay")
 	cmd.PersistentFlags().BoolVar(&cfg.DoNotPersist,
@@ -54,86 +112,259 @@ func NewEventReplayCmd() *cobra.Command {
 		"replay this duration with max speed (relative to first event timestamp)")
 	cmd.Flags().BoolVar(&cfg.FFPreRace,
 		"ff-prerace", true, "fast forward prerace events")
+	cmd.Flags().DurationVar(&cfg.CatchUpLimit,
+		"catch-up-limit", cfg.CatchUpLimit,
+		"max delay to catch up when behind schedule (0: always catch up)")
+	cmd.Flags().StringSliceVar(&cfg.SessionTypes,
+		"session-types", cfg.SessionTypes,
+		"replay only sessions of these types (practice, qualify, race)")
+	cmd.Flags().UintSliceVar(&cfg.SessionNums,
+		"sessions", cfg.SessionNums, "replay only these session nums")
+	cmd.Flags().StringSliceVar(&cfg.CarNums,
+		"cars", cfg.CarNums, "replay only these car numbers")
+	cmd.Flags().StringSliceVar(&cfg.CarClasses,
+		"car-classes", cfg.CarClasses, "replay only cars of these car classes")
+	cmd.Flags().StringVar(&cfg.ChaosSpec,
+		"chaos", cfg.ChaosSpec,
+		"inject faults, e.g. \"drop=0.01,state.reorder=0.05,disconnect=0.001\" "+
+			"(keys: drop, dup, delay, reorder, truncate, disconnect, max-delay)")
+	cmd.Flags().Int64Var(&cfg.ChaosSeed,
+		"chaos-seed", cfg.ChaosSeed, "seed for fault injection (0: random)")
+	cmd.Flags().DurationVar(&fromSessionTime,
+		"from-session-time", 0,
+		"start replay at this session time (default: event's replay info)")
+	cmd.Flags().DurationVar(&toSessionTime,
+		"to-session-time", 0,
+		"stop replay at this session time (default: event's replay info)")
+	cmd.Flags().IntVar(&cfg.SessionNum,
+		"session-num", -1,
+		"session num for the session time bounds (default: race session)")
+	cmd.Flags().IntVar(&cfg.MaxRetries,
+		"max-retries", cfg.MaxRetries, "max retries per message on transient errors")
+	cmd.Flags().DurationVar(&cfg.RetryBackoff,
+		"retry-backoff", cfg.RetryBackoff, "initial backoff between retries")
+	cmd.Flags().DurationVar(&cfg.RetryMaxBackoff,
+		"retry-max-backoff", cfg.RetryMaxBackoff, "max backoff between retries")
+	cmd.Flags().IntVar(&cfg.MaxFailures,
+		"max-failures", cfg.MaxFailures,
+		"abort after this many consecutive messages were dropped (0: never abort)")
+	cmd.Flags().BoolVar(&cfg.Anonymize,
+		"anonymize", false, "replace driver and team names by generic names")
+	cmd.Flags().BoolVar(&cfg.Retime,
+		"retime", false, "shift timestamps so the event appears to happen now")
+	cmd.Flags().StringVar(&cfg.EventName,
+		"event-name", "", "name for the replayed event")
+	cmd.Flags().DurationVar(&progress,
+		"progress", 10*time.Second, "interval for progress output (0: disabled)")
+	cmd.Flags().StringVar(&cfg.Checkpoint,
+		"checkpoint", "", "write replay progress to this checkpoint file")
+	cmd.Flags().DurationVar(&cfg.CheckpointInterval,
+		"checkpoint-interval", cfg.CheckpointInterval,
+		"interval for writing the checkpoint file")
+	cmd.Flags().StringVar(&cfg.Resume,
+		"resume", "", "resume the replay recorded in this checkpoint file")
+	cmd.Flags().BoolVarP(&interactive,
+		"interactive", "i", false, "control the replay by commands on stdin")
+	cmd.Flags().StringVar(&controlSocket,
+		"control-socket", "", "unix socket to control the replay (same commands as stdin)")
 
 	return cmd
 }
 
-//nolint:funlen,gocognit,cyclop //by design
-func replayEvent(arg string) {
-	log.Info("connect source server", log.String("addr", cfg.SourceAddr))
-	source, err := util.NewClient(
-		cfg.SourceAddr,
-		util.WithTLSEnabled(!cfg.SourceInsecure))
+func replayEvent(ctx context.Context, args []string) {
+	resume, err := prepareResume(&args)
 	if err != nil {
-		log.Error("did not connect", log.ErrorField(err))
+		log.Error("could not resume replay", log.ErrorField(err))
 		return
 	}
-	defer source.Close()
-
-	log.Info("connect dest server", log.String("addr", config.DefaultCliArgs().Addr))
-	dest, err := util.NewClient(
-		config.DefaultCliArgs().Addr,
-		util.WithCliArgs(config.DefaultCliArgs()))
+	dests, err := connectDestinations()
 	if err != nil {
 		log.Error("did not connect", log.ErrorField(err))
 		return
 	}
-	defer dest.Close()
+	defer dests.Close()
 
-	req := eventv1.GetEventRequest{
-		EventSelector: util.ResolveEvent(arg),
+	var dp replay.ReplayDataProvider
+	var event *eventv1.Event
+	if cfg.SourceFile != "" {
+		dp, event, err = fileDataProvider()
+	} else {
+		var source *grpc.ClientConn
+		source, dp, event, err = sourceDataProvider(args[0])
+		if source != nil {
+			defer source.Close()
+		}
+	}
+	if err != nil {
+		return
 	}
 
-	ctx := context.Background()
-	c := eventv1grpc.NewEventServiceClient(source)
-	e, err := c.GetEvent(ctx, &req)
+	opts, err := replayOptions(event)
 	if err != nil {
-		log.Error("could not load event", log.ErrorField(err), log.String("event", arg))
+		log.Error("invalid replay options", log.ErrorField(err))
 		return
 	}
+	opts = append(opts, checkpointOptions(args, resume)...)
+	opts = append(opts, dests.options()...)
+	// an interrupted replay stops cleanly, so the checkpoint is up to date
+	ctx, cancel := signal.NotifyContext(ctx, os.Interrupt)
+	defer cancel()
+	opts = append(opts, replay.WithContext(ctx))
+	r := replay.NewReplayTask(dests.primary(), dp, opts...)
+	stopControl := startControl(r)
+	defer stopControl()
+	if err := r.Replay(event.Id); err != nil {
+		log.Error("Error replaying event", log.ErrorField(err))
+	}
+	logReplayResult(r)
+}
 
-	log.Info("Event loaded.",
-		log.String("event", e.Event.Name),
-		log.Uint32("id", e.Event.Id))
+func logReplayResult(r *replay.ReplayTask) {
+	errStats := r.ErrorStats()
+	stats := r.GetStats()
+	log.Info("Replay finished",
+		log.String("stats", stats.String()),
+		log.Stringer("drift", &stats.Drift),
+		log.Int("retried", errStats.Retried),
+		log.Int("skipped", errStats.Skipped),
+		log.Int("dropped", errStats.Dropped))
+	if cfg.ChaosSpec != "" {
+		log.Info("Injected faults", log.Any("chaos", r.ChaosStats()))
+	}
+}
 
-	dp := replay.NewDataProvider(source, e.Event.Id,
-		func() *providerv1.RegisterEventRequest {
-			recordingMode := func() providerv1.RecordingMode {
-				if cfg.DoNotPersist {
-					return providerv1.RecordingMode_RECORDING_MODE_DO_NOT_PERSIST
-				} else {
-					return providerv1.RecordingMode_RECORDING_MODE_PERSIST
-				}
-			}
-			if cfg.EventKey == "" {
-				cfg.EventKey = uuid.New().String()
-			}
-			e.Event.Key = cfg.EventKey
-			return &providerv1.RegisterEventRequest{
-				Key:           e.Event.Key,
-				Event:         e.Event,
-				Track:         e.Track,
-				RecordingMode: recordingMode(),
-			}
-		})
+func replayOptions(event *eventv1.Event) ([]replay.ReplayOption, error) {
 	opts := make([]replay.ReplayOption, 0)
-	if cfg.Speed > 0 {
-		opts = append(opts, replay.WithSpeed(cfg.Speed))
-	}
+	opts = append(opts, replay.WithSpeed(cfg.Speed))
 	if cfg.FastForward != time.Duration(0) {
 		opts = append(opts, replay.WithFastForward(cfg.FastForward))
 	}
-	if cfg.Token != "" {
+	if token := destToken(0); token != "" {
 		opts = append(opts, replay.WithTokenProvider(func() string {
-			return cfg.Token
+			return token
+		}))
+	}
+	if progress > 0 {
+		opts = append(opts, replay.WithStatsCallback(progress, func(s *replay.Stats) {
+			log.Info("Replay progress", log.String("progress", s.Progress()))
 		}))
 	}
+	if w := cfg.SessionWindow(event); w != nil {
+		log.Info("Using session window", log.Stringer("window", w))
+		opts = append(opts, replay.WithSessionWindow(w))
+	}
+	sf, err := cfg.SessionFilter()
+	if err != nil {
+		return nil, err
+	}
+	if sf != nil {
+		opts = append(opts, replay.WithSessionFilter(sf))
+	}
+	chaos, err := cfg.NewChaos(0)
+	if err != nil {
+		return nil, err
+	}
+	if chaos != nil {
+		log.Info("Injecting faults", log.Int64("seed", chaos.Seed))
+		opts = append(opts, replay.WithChaos(chaos))
+	}
 	opts = append(opts,
 		replay.WithFastForwardPreRace(cfg.FFPreRace),
+		replay.WithCatchUpLimit(cfg.CatchUpLimit),
+		replay.WithErrorPolicy(cfg.ErrorPolicy()),
+		replay.WithTransformer(cfg.Transformers()...),
 		replay.WithLogging(log.Default()))
+	return opts, nil
+}
 
-	r := replay.NewReplayTask(dest, dp, opts...)
-	if err := r.Replay(e.Event.Id); err != nil {
-		log.Error("Error replaying event", log.ErrorField(err))
+// sourceDataProvider creates a data provider for an event on the source server.
+// The returned connection has to be closed by the caller.
+//
+//nolint:whitespace // by design
+func sourceDataProvider(arg string) (
+	source *grpc.ClientConn,
+	dp replay.ReplayDataProvider,
+	event *eventv1.Event,
+	err error,
+) {
+	log.Info("connect source server", log.String("addr", cfg.SourceAddr))
+	source, err = util.NewClient(
+		cfg.SourceAddr,
+		util.WithTLSEnabled(!cfg.SourceInsecure))
+	if err != nil {
+		log.Error("did not connect", log.ErrorField(err))
+		return nil, nil, nil, err
+	}
+
+	req := eventv1.GetEventRequest{
+		EventSelector: util.ResolveEvent(arg),
+	}
+
+	c := eventv1grpc.NewEventServiceClient(source)
+	e, err := c.GetEvent(context.Background(), &req)
+	if err != nil {
+		log.Error("could not load event", log.ErrorField(err), log.String("event", arg))
+		return source, nil, nil, err
+	}
+
+	log.Info("Event loaded.",
+		log.String("event", e.Event.Name),
+		log.Uint32("id", e.Event.Id))
+
+	dp, err = cfg.NewDataProvider(source, e.Event.Id,
+		registerRequestProvider(e.Event, e.Track))
+	if err != nil {
+		log.Error("could not create data provider", log.ErrorField(err))
+		return source, nil, nil, err
+	}
+	return source, dp, e.Event, nil
+}
+
+// fileDataProvider creates a data provider for the event stored in the replay file
+func fileDataProvider() (replay.ReplayDataProvider, *eventv1.Event, error) {
+	log.Info("read replay file", log.String("file", cfg.SourceFile))
+	stored, err := replay.ReadEventFromFile(cfg.SourceFile)
+	if err != nil {
+		log.Error("could not read replay file",
+			log.ErrorField(err),
+			log.String("file", cfg.SourceFile))
+		return nil, nil, err
+	}
+	log.Info("Event loaded.",
+		log.String("event", stored.Event.Name),
+		log.Uint32("id", stored.Event.Id))
+
+	dp, err := replay.NewFileDataProvider(cfg.SourceFile,
+		registerRequestProvider(stored.Event, stored.Track))
+	if err != nil {
+		log.Error("could not create data provider", log.ErrorField(err))
+		return nil, nil, err
+	}
+	return dp, stored.Event, nil
+}
+
+//nolint:whitespace // by design
+func registerRequestProvider(
+	e *eventv1.Event,
+	track *trackv1.Track,
+) replay.ProvideEventRequest {
+	return func() *providerv1.RegisterEventRequest {
+		recordingMode := func() providerv1.RecordingMode {
+			if cfg.DoNotPersist {
+				return providerv1.RecordingMode_RECORDING_MODE_DO_NOT_PERSIST
+			} else {
+				return providerv1.RecordingMode_RECORDING_MODE_PERSIST
+			}
+		}
+		if cfg.EventKey == "" {
+			cfg.EventKey = uuid.New().String()
+		}
+		e.Key = cfg.EventKey
+		return &providerv1.RegisterEventRequest{
+			Key:           e.Key,
+			Event:         e,
+			Track:         track,
+			RecordingMode: recordingMode(),
+		}
 	}
 }
@@ -49,7 +49,7 @@ func NewEventSessionCmd() *cobra.Command {
 	cmd.Flags().StringSliceVar(&attrs, "attrs", []string{},
 		"session attributes to display")
 	cmd.Flags().StringVar(&format, "format", "text",
-		"output format (text, json,csv)")
+		"output format (text, json, csv)")
 
 	return cmd
 }
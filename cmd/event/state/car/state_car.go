@@ -40,7 +40,7 @@ func NewStateCarCmd() *cobra.Command {
 	}
 
 	cmd.Flags().StringVar(&format, "format", "text",
-		"output format (text, json,csv)")
+		"output format (text, json, csv)")
 	cmd.Flags().StringSliceVar(&attrs, "attrs", []string{},
 		"session attributes to display")
 	cmd.Flags().StringVar(&carNum, "carnum", "",
@@ -0,0 +1,138 @@
+package alert
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"sync"
+	"time"
+
+	livedatav1grpc "buf.build/gen/go/mpapenbr/iracelog/grpc/go/iracelog/livedata/v1/livedatav1grpc"
+	livedatav1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/livedata/v1"
+	"github.com/spf13/cobra"
+	"google.golang.org/grpc"
+
+	"github.com/mpapenbr/iracelog-cli/config"
+	"github.com/mpapenbr/iracelog-cli/log"
+	"github.com/mpapenbr/iracelog-cli/util"
+	"github.com/mpapenbr/iracelog-cli/util/alert"
+	"github.com/mpapenbr/iracelog-cli/util/stream"
+)
+
+var (
+	rulesFile     string
+	actionTimeout time.Duration
+)
+
+func NewLiveAlertCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "alert",
+		Short: "runs actions when rules match the live data",
+		Long: `Evaluates rules over the live data of an event and runs the configured
+action when a rule fires.
+The live data is not written, so there is no ndjson output.
+
+Rule types:
+  pit           a car enters the pit
+  gap           gap between two cars (or interval to the car ahead) below seconds
+  flag          flag state changes (optionally restricted by flags)
+  driverchange  driver of a car changes
+  stopped       a car stopped on track (speed 0 outside the pit)
+
+Actions:
+  print         print a line to stdout (default)
+  exec          run a command, the alert is passed as JSON on stdin
+  post          POST the alert as JSON to an URL
+
+Example rules file:
+  rules:
+    - name: battle
+      type: gap
+      cars: ["42", "7"]
+      seconds: 1.0
+    - name: pit-42
+      type: pit
+      cars: ["42"]
+      action:
+        type: exec
+        command: ["./radio.sh"]
+    - name: flags
+      type: flag
+      action:
+        type: post
+        url: http://localhost:8080/alert`,
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			return nil
+		},
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			liveAlert(cmd.Context(), args[0])
+		},
+	}
+	cmd.Flags().StringVar(&rulesFile, "rules", "",
+		"yaml file containing the rules")
+	cmd.Flags().DurationVar(&actionTimeout, "action-timeout", 10*time.Second,
+		"timeout for exec and post actions")
+	//nolint:errcheck // by design
+	cmd.MarkFlagRequired("rules")
+	return cmd
+}
+
+func liveAlert(ctx context.Context, eventArg string) {
+	rules, err := alert.LoadRules(rulesFile)
+	if err != nil {
+		log.Error("could not load rules", log.ErrorField(err))
+		return
+	}
+	sel := util.ResolveEvent(eventArg)
+	conn, err := util.ConnectGrpc(config.DefaultCliArgs())
+	if err != nil {
+		log.Error("did not connect", log.ErrorField(err))
+		return
+	}
+	defer conn.Close()
+
+	ctx, cancel := signal.NotifyContext(ctx, os.Interrupt)
+	defer cancel()
+
+	// actions may still run after the streams are done
+	d := alert.NewDispatcher(context.Background(), alert.WithTimeout(actionTimeout))
+	defer d.Close()
+	e := alert.NewEngine(rules, d.Dispatch)
+	log.Info("watching live data", log.Int("rules", len(rules)))
+
+	c := livedatav1grpc.NewLiveDataServiceClient(conn)
+	wg := sync.WaitGroup{}
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		subscribe(ctx, "driver", func(ctx context.Context, _ bool) (
+			grpc.ServerStreamingClient[livedatav1.LiveDriverDataResponse], error,
+		) {
+			return c.LiveDriverData(ctx, &livedatav1.LiveDriverDataRequest{Event: sel})
+		}, e.ApplyDriverData)
+	}()
+	go func() {
+		defer wg.Done()
+		subscribe(ctx, "state", func(ctx context.Context, _ bool) (
+			grpc.ServerStreamingClient[livedatav1.LiveRaceStateResponse], error,
+		) {
+			return c.LiveRaceState(ctx, &livedatav1.LiveRaceStateRequest{Event: sel})
+		}, e.ApplyState)
+	}()
+	wg.Wait()
+}
+
+//nolint:whitespace // by design
+func subscribe[T any](
+	ctx context.Context,
+	name string,
+	open stream.OpenFunc[T],
+	handle func(*T),
+) {
+	err := stream.Subscribe(ctx, name, open, handle,
+		stream.WithCliArgs(config.DefaultCliArgs()))
+	if err != nil {
+		log.Error("live stream failed", log.String("stream", name), log.ErrorField(err))
+	}
+}
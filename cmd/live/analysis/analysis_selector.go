@@ -2,17 +2,18 @@ package analysis
 
 import (
 	"context"
-	"errors"
-	"io"
 
 	livedatav1grpc "buf.build/gen/go/mpapenbr/iracelog/grpc/go/iracelog/livedata/v1/livedatav1grpc"
 	analysisv1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/analysis/v1"
 	livedatav1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/livedata/v1"
 	"github.com/spf13/cobra"
+	"google.golang.org/grpc"
 
 	"github.com/mpapenbr/iracelog-cli/config"
 	"github.com/mpapenbr/iracelog-cli/log"
 	"github.com/mpapenbr/iracelog-cli/util"
+	"github.com/mpapenbr/iracelog-cli/util/output/ndjson"
+	"github.com/mpapenbr/iracelog-cli/util/stream"
 )
 
 func NewLiveAnalysisSelectorCmd() *cobra.Command {
@@ -33,12 +34,18 @@ func NewLiveAnalysisSelectorCmd() *cobra.Command {
 	cmd.Flags().IntVar(&tailNum,
 		"tail", 2,
 		"request tail entries for components carlaps, racegraph")
+	ndjson.AddFlags(cmd)
 	return cmd
 }
 
 var tailNum int
 
 func liveAnalysisDataWithSelector(eventArg string) {
+	nd, err := ndjson.NewFromCliArgs(config.DefaultCliArgs())
+	if err != nil {
+		log.Error("invalid output options", log.ErrorField(err))
+		return
+	}
 	eventSel := util.ResolveEvent(eventArg)
 	sel := resolveAnalysisSelector()
 
@@ -53,23 +60,22 @@ func liveAnalysisDataWithSelector(eventArg string) {
 		Selector: sel,
 	}
 	c := livedatav1grpc.NewLiveDataServiceClient(conn)
-	r, err := c.LiveAnalysisSel(context.Background(), &req)
-	if err != nil {
-		log.Error("could not get live data", log.ErrorField(err))
-		return
-	}
-
-	for {
-		resp, err := r.Recv()
-		if errors.Is(err, io.EOF) {
-			break
-		}
-		if err != nil {
-			log.Error("error fetching live state", log.ErrorField(err))
-			return
-		} else {
+	err = stream.Subscribe(context.Background(), "analysis",
+		func(ctx context.Context, _ bool) (
+			grpc.ServerStreamingClient[livedatav1.LiveAnalysisSelResponse], error,
+		) {
+			return c.LiveAnalysisSel(ctx, &req)
+		},
+		func(resp *livedatav1.LiveAnalysisSelResponse) {
+			if nd != nil {
+				nd.Line(resp)
+				return
+			}
 			resolveOutput(resp, sel)
-		}
+		},
+		stream.WithCliArgs(config.DefaultCliArgs()))
+	if err != nil {
+		log.Error("error fetching live analysis", log.ErrorField(err))
 	}
 }
 
@@ -2,16 +2,17 @@ package analysis
 
 import (
 	"context"
-	"errors"
-	"io"
 
 	livedatav1grpc "buf.build/gen/go/mpapenbr/iracelog/grpc/go/iracelog/livedata/v1/livedatav1grpc"
 	livedatav1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/livedata/v1"
 	"github.com/spf13/cobra"
+	"google.golang.org/grpc"
 
 	"github.com/mpapenbr/iracelog-cli/config"
 	"github.com/mpapenbr/iracelog-cli/log"
 	"github.com/mpapenbr/iracelog-cli/util"
+	"github.com/mpapenbr/iracelog-cli/util/output/ndjson"
+	"github.com/mpapenbr/iracelog-cli/util/stream"
 )
 
 func NewLiveCarOccupancyCmd() *cobra.Command {
@@ -26,10 +27,16 @@ func NewLiveCarOccupancyCmd() *cobra.Command {
 			liveCarInfo(args[0])
 		},
 	}
+	ndjson.AddFlags(cmd)
 	return cmd
 }
 
 func liveCarInfo(eventArg string) {
+	nd, err := ndjson.NewFromCliArgs(config.DefaultCliArgs())
+	if err != nil {
+		log.Error("invalid output options", log.ErrorField(err))
+		return
+	}
 	sel := util.ResolveEvent(eventArg)
 	conn, err := util.ConnectGrpc(config.DefaultCliArgs())
 	if err != nil {
@@ -41,21 +48,17 @@ func liveCarInfo(eventArg string) {
 		Event: sel,
 	}
 	c := livedatav1grpc.NewLiveDataServiceClient(conn)
-	r, err := c.LiveCarOccupancies(context.Background(), &req)
-	if err != nil {
-		log.Error("could not get live data", log.ErrorField(err))
-		return
-	}
-
-	for {
-		resp, err := r.Recv()
-		if errors.Is(err, io.EOF) {
-			break
-		}
-		if err != nil {
-			log.Error("error fetching live state", log.ErrorField(err))
-			return
-		} else {
+	err = stream.Subscribe(context.Background(), "caroccupancy",
+		func(ctx context.Context, _ bool) (
+			grpc.ServerStreamingClient[livedatav1.LiveCarOccupanciesResponse], error,
+		) {
+			return c.LiveCarOccupancies(ctx, &req)
+		},
+		func(resp *livedatav1.LiveCarOccupanciesResponse) {
+			if nd != nil {
+				nd.Line(resp)
+				return
+			}
 			log.Debug("got count: ",
 				log.Int("count", len(resp.CarOccupancies)),
 			)
@@ -65,6 +68,9 @@ func liveCarInfo(eventArg string) {
 					log.Any("drivers", co.Drivers),
 				)
 			}
-		}
+		},
+		stream.WithCliArgs(config.DefaultCliArgs()))
+	if err != nil {
+		log.Error("error fetching live car occupancies", log.ErrorField(err))
 	}
 }
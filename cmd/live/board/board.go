@@ -0,0 +1,224 @@
+package board
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"time"
+
+	livedatav1grpc "buf.build/gen/go/mpapenbr/iracelog/grpc/go/iracelog/livedata/v1/livedatav1grpc"
+	commonv1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/common/v1"
+	livedatav1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/livedata/v1"
+	"github.com/spf13/cobra"
+	"google.golang.org/grpc"
+
+	"github.com/mpapenbr/iracelog-cli/config"
+	"github.com/mpapenbr/iracelog-cli/log"
+	"github.com/mpapenbr/iracelog-cli/util"
+	"github.com/mpapenbr/iracelog-cli/util/stream"
+)
+
+var (
+	carClass  string
+	highlight string
+	refresh   time.Duration
+)
+
+func NewLiveBoardCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "board",
+		Short: "shows a live timing board for a running event",
+		Long: `Shows a full-screen live timing board for a running event.
+The board is refreshed whenever new data arrives.
+
+Commands (confirm with Enter):
+  n, p          switch to the next/previous class tab
+  <num>         switch to class tab <num>
+  c <class>     switch to the tab of the car class
+  h <carnum>    highlight a car (h without carnum removes the highlight)
+  q             quit`,
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			return nil
+		},
+		// the board uses the alternate screen, logs would corrupt it
+		Annotations: map[string]string{config.AnnotationStdout: "board"},
+		Args:        cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			liveBoard(cmd.Context(), args[0])
+		},
+	}
+	cmd.Flags().StringVar(&carClass, "class", "",
+		"initial class tab")
+	cmd.Flags().StringVar(&highlight, "highlight", "",
+		"car number to highlight")
+	cmd.Flags().DurationVar(&refresh, "refresh", time.Second,
+		"redraw interval if no new data arrives")
+	return cmd
+}
+
+func liveBoard(ctx context.Context, eventArg string) {
+	conn, err := util.ConnectGrpc(config.DefaultCliArgs())
+	if err != nil {
+		log.Error("did not connect", log.ErrorField(err))
+		return
+	}
+	defer conn.Close()
+
+	ctx, cancel := signal.NotifyContext(ctx, os.Interrupt)
+	defer cancel()
+
+	m := newModel(eventArg)
+	m.setHighlight(highlight)
+	// not waited for, reading stdin can't be interrupted
+	go handleInput(os.Stdin, m, cancel)
+	wg := sync.WaitGroup{}
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		subscribe(ctx, conn, util.ResolveEvent(eventArg), m)
+	}()
+	go func() {
+		defer wg.Done()
+		draw(ctx, m, os.Stdout)
+	}()
+	wg.Wait()
+}
+
+// subscribe receives the live streams until the context is done
+//
+//nolint:whitespace // by design
+func subscribe(
+	ctx context.Context,
+	conn *grpc.ClientConn,
+	sel *commonv1.EventSelector,
+	m *model,
+) {
+	c := livedatav1grpc.NewLiveDataServiceClient(conn)
+	wg := sync.WaitGroup{}
+	wg.Add(3)
+	go func() {
+		defer wg.Done()
+		receive(ctx, m, "state",
+			func(ctx context.Context, _ bool) (
+				grpc.ServerStreamingClient[livedatav1.LiveRaceStateResponse], error,
+			) {
+				return c.LiveRaceState(ctx, &livedatav1.LiveRaceStateRequest{Event: sel})
+			},
+			m.applyState)
+	}()
+	go func() {
+		defer wg.Done()
+		receive(ctx, m, "driver",
+			func(ctx context.Context, _ bool) (
+				grpc.ServerStreamingClient[livedatav1.LiveDriverDataResponse], error,
+			) {
+				return c.LiveDriverData(ctx, &livedatav1.LiveDriverDataRequest{Event: sel})
+			},
+			m.applyDriverData)
+	}()
+	go func() {
+		defer wg.Done()
+		req := &livedatav1.LiveAnalysisSelRequest{
+			Event: sel,
+			Selector: &livedatav1.AnalysisSelector{
+				Components: []livedatav1.AnalysisComponent{
+					livedatav1.AnalysisComponent_ANALYSIS_COMPONENT_RACE_ORDER,
+				},
+			},
+		}
+		receive(ctx, m, "analysis",
+			func(ctx context.Context, _ bool) (
+				grpc.ServerStreamingClient[livedatav1.LiveAnalysisSelResponse], error,
+			) {
+				return c.LiveAnalysisSel(ctx, req)
+			},
+			m.applyAnalysis)
+	}()
+	wg.Wait()
+}
+
+// receive applies the stream data to the model. Broken streams are reopened.
+// Errors are shown in the status line of the board.
+//
+//nolint:whitespace // by design
+func receive[T any](
+	ctx context.Context,
+	m *model,
+	name string,
+	open stream.OpenFunc[T],
+	apply func(*T),
+) {
+	err := stream.Subscribe(ctx, name, open, apply,
+		stream.WithCliArgs(config.DefaultCliArgs()))
+	if ctx.Err() != nil {
+		return
+	}
+	if err == nil {
+		m.setStatus(fmt.Sprintf("%s stream ended", name))
+		return
+	}
+	log.Debug("live stream failed", log.String("stream", name), log.ErrorField(err))
+	m.setStatus(fmt.Sprintf("%s stream failed: %v", name, err))
+}
+
+// draw renders the board on changes (at most once per 100ms) and
+// every refresh interval
+func draw(ctx context.Context, m *model, w io.Writer) {
+	//nolint:errcheck // by design
+	io.WriteString(w, ansiAltScreen+ansiHideCursor)
+	//nolint:errcheck // by design
+	defer io.WriteString(w, ansiShowCursor+ansiMainScreen)
+	ticker := time.NewTicker(max(refresh, 100*time.Millisecond))
+	defer ticker.Stop()
+	// the classes are not known until the first driver data arrives
+	classPending := carClass
+	for {
+		if classPending != "" && m.selectClass(classPending) {
+			classPending = ""
+		}
+		m.render(w)
+		select {
+		case <-ctx.Done():
+			return
+		case <-m.changed:
+			time.Sleep(100 * time.Millisecond)
+		case <-ticker.C:
+		}
+	}
+}
+
+// handleInput processes the line based commands
+func handleInput(in io.Reader, m *model, quit func()) {
+	scanner := bufio.NewScanner(in)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		arg := strings.Join(fields[1:], " ")
+		switch fields[0] {
+		case "q", "quit":
+			quit()
+			return
+		case "n", "next":
+			m.nextClass(1)
+		case "p", "prev":
+			m.nextClass(-1)
+		case "c", "class":
+			if !m.selectClass(arg) {
+				m.setStatus("unknown class: " + arg)
+			}
+		case "h", "highlight":
+			m.setHighlight(arg)
+		default:
+			if !m.selectClass(fields[0]) {
+				m.setStatus("unknown command: " + fields[0])
+			}
+		}
+	}
+}
@@ -0,0 +1,179 @@
+package board
+
+import (
+	"cmp"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	carv1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/car/v1"
+	livedatav1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/livedata/v1"
+	racestatev1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/racestate/v1"
+)
+
+// allClasses is the tab showing the cars of all classes
+const allClasses = "All"
+
+// model collects the latest data of the live streams
+type model struct {
+	mu        sync.Mutex
+	event     string
+	session   *racestatev1.Session
+	cars      []*racestatev1.Car
+	entries   map[uint32]*carv1.CarEntry // key: carIdx
+	drivers   map[uint32]string          // current driver by carIdx
+	raceOrder []string                   // car numbers
+	classes   []string                   // tabs, the first one is allClasses
+	tab       int
+	highlight string // car number
+	updated   time.Time
+	status    string // last stream error
+	changed   chan struct{}
+}
+
+func newModel(event string) *model {
+	return &model{
+		event:   event,
+		entries: make(map[uint32]*carv1.CarEntry),
+		drivers: make(map[uint32]string),
+		classes: []string{allClasses},
+		changed: make(chan struct{}, 1),
+	}
+}
+
+// update applies f with the lock held and triggers a redraw
+func (m *model) update(f func()) {
+	m.mu.Lock()
+	f()
+	m.mu.Unlock()
+	m.notify()
+}
+
+func (m *model) notify() {
+	select {
+	case m.changed <- struct{}{}:
+	default:
+	}
+}
+
+func (m *model) applyState(resp *livedatav1.LiveRaceStateResponse) {
+	m.update(func() {
+		m.updated = time.Now()
+		m.session = resp.Session
+		m.cars = resp.Cars
+	})
+}
+
+func (m *model) applyDriverData(resp *livedatav1.LiveDriverDataResponse) {
+	m.update(func() {
+		m.updated = time.Now()
+		clear(m.entries)
+		classes := []string{}
+		for _, e := range resp.Entries {
+			m.entries[e.GetCar().GetCarIdx()] = e
+			if c := e.GetCar().GetCarClassName(); !slices.Contains(classes, c) {
+				classes = append(classes, c)
+			}
+		}
+		slices.Sort(classes)
+		cur := m.classes[m.tab]
+		m.classes = append([]string{allClasses}, classes...)
+		m.tab = max(slices.Index(m.classes, cur), 0)
+		m.drivers = resp.CurrentDrivers
+	})
+}
+
+func (m *model) applyAnalysis(resp *livedatav1.LiveAnalysisSelResponse) {
+	if resp.RaceOrder == nil {
+		return
+	}
+	m.update(func() {
+		m.updated = time.Now()
+		m.raceOrder = resp.RaceOrder
+	})
+}
+
+func (m *model) setStatus(status string) {
+	m.update(func() {
+		m.status = status
+	})
+}
+
+// selectClass switches the tab by name or number (1 is the first tab)
+func (m *model) selectClass(name string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	num, _ := strconv.Atoi(name) // 0 if name is not a number
+	for i, c := range m.classes {
+		if c == name || num == i+1 {
+			m.tab = i
+			m.notify()
+			return true
+		}
+	}
+	return false
+}
+
+// nextClass moves the tab by delta (wrapping around)
+func (m *model) nextClass(delta int) {
+	m.update(func() {
+		n := len(m.classes)
+		m.tab = ((m.tab+delta)%n + n) % n
+	})
+}
+
+func (m *model) setHighlight(carNum string) {
+	m.update(func() {
+		m.highlight = carNum
+	})
+}
+
+// row contains the data of a car shown on the board
+type row struct {
+	car      *racestatev1.Car
+	entry    *carv1.CarEntry
+	driver   string
+	orderIdx int
+}
+
+// rows returns the cars of the current tab in display order.
+// must be called with mu held
+func (m *model) rows() []row {
+	order := make(map[string]int, len(m.raceOrder))
+	for i, num := range m.raceOrder {
+		order[num] = i
+	}
+	ret := make([]row, 0, len(m.cars))
+	for _, c := range m.cars {
+		idx := uint32(c.CarIdx) //nolint:gosec // carIdx is not negative
+		e := m.entries[idx]
+		if e == nil {
+			continue // no driver data yet
+		}
+		if m.tab > 0 && e.GetCar().GetCarClassName() != m.classes[m.tab] {
+			continue
+		}
+		r := row{car: c, entry: e, driver: m.drivers[idx], orderIdx: len(order)}
+		if i, ok := order[e.GetCar().GetCarNumber()]; ok {
+			r.orderIdx = i
+		}
+		ret = append(ret, r)
+	}
+	// use the race order of the analysis, the position of the state otherwise
+	slices.SortStableFunc(ret, func(a, b row) int {
+		if a.orderIdx != b.orderIdx {
+			return cmp.Compare(a.orderIdx, b.orderIdx)
+		}
+		return cmp.Compare(posOrMax(a.car.Pos), posOrMax(b.car.Pos))
+	})
+	return ret
+}
+
+// posOrMax sorts cars without position to the end
+func posOrMax(pos int32) int32 {
+	if pos <= 0 {
+		return 1<<31 - 1
+	}
+	return pos
+}
@@ -0,0 +1,169 @@
+package board
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	racestatev1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/racestate/v1"
+)
+
+// ANSI escape sequences
+const (
+	ansiHome       = "\x1b[H"
+	ansiClearLine  = "\x1b[K"
+	ansiClearBelow = "\x1b[J"
+	ansiAltScreen  = "\x1b[?1049h"
+	ansiMainScreen = "\x1b[?1049l"
+	ansiHideCursor = "\x1b[?25l"
+	ansiShowCursor = "\x1b[?25h"
+	ansiBold       = "\x1b[1m"
+	ansiReverse    = "\x1b[7m"
+	ansiHighlight  = "\x1b[1;30;43m" // bold black on yellow
+	ansiDim        = "\x1b[2m"
+	ansiReset      = "\x1b[0m"
+)
+
+const rowFormat = "%3s %3s %-6s %4s %-24.24s %4s %9s %9s %8s %8s %-4s %5s"
+
+// render writes the complete board
+func (m *model) render(w io.Writer) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	b := &strings.Builder{}
+	line := func(format string, args ...any) {
+		fmt.Fprintf(b, format, args...)
+		b.WriteString(ansiReset + ansiClearLine + "\n")
+	}
+	b.WriteString(ansiHome)
+	line("%s%s%s", ansiBold, m.event, ansiReset)
+	line("%s", m.sessionInfo())
+	line("%s", m.tabs())
+	line("")
+	line(ansiBold+rowFormat,
+		"POS", "CLS", "CLASS", "#", "DRIVER", "LAP", "LAST", "BEST",
+		"GAP", "INT", "PIT", "STINT")
+	for _, r := range m.rows() {
+		style := ""
+		if m.highlight != "" && r.entry.GetCar().GetCarNumber() == m.highlight {
+			style = ansiHighlight
+		}
+		line(style+rowFormat,
+			intOrEmpty(r.car.Pos),
+			intOrEmpty(r.car.Pic),
+			truncate(r.entry.GetCar().GetCarClassName(), 6),
+			r.entry.GetCar().GetCarNumber(),
+			r.driver,
+			intOrEmpty(r.car.Lap),
+			lapTime(r.car.Last),
+			lapTime(r.car.Best),
+			delta(r.car.Gap),
+			delta(r.car.Interval),
+			pitStatus(r.car.State),
+			fmt.Sprintf("%d", r.car.StintLap),
+		)
+	}
+	line("")
+	line("%s", m.footer())
+	b.WriteString(ansiClearBelow)
+	//nolint:errcheck // by design
+	io.WriteString(w, b.String())
+}
+
+func (m *model) sessionInfo() string {
+	if m.session == nil {
+		return "waiting for data..."
+	}
+	s := m.session
+	lapsRemain := "-"
+	if s.LapsRemain >= 0 {
+		lapsRemain = fmt.Sprintf("%d", s.LapsRemain)
+	}
+	return fmt.Sprintf("Session %d  Time %s  Remain %s  Laps remain %s  Flag %s",
+		s.SessionNum,
+		duration(s.SessionTime),
+		duration(s.TimeRemain),
+		lapsRemain,
+		s.FlagState)
+}
+
+func (m *model) tabs() string {
+	b := &strings.Builder{}
+	for i, c := range m.classes {
+		if i == m.tab {
+			fmt.Fprintf(b, "%s %d:%s %s ", ansiReverse, i+1, c, ansiReset)
+		} else {
+			fmt.Fprintf(b, " %d:%s  ", i+1, c)
+		}
+	}
+	return b.String()
+}
+
+func (m *model) footer() string {
+	updated := "-"
+	if !m.updated.IsZero() {
+		updated = m.updated.Format(time.TimeOnly)
+	}
+	ret := fmt.Sprintf("%supdated %s | n/p: next/prev class, <num>: class tab, "+
+		"h <carnum>: highlight, q: quit (confirm with Enter)", ansiDim, updated)
+	if m.status != "" {
+		ret += "\n" + ansiReset + ansiBold + m.status
+	}
+	return ret
+}
+
+func intOrEmpty(v int32) string {
+	if v <= 0 {
+		return ""
+	}
+	return fmt.Sprintf("%d", v)
+}
+
+func truncate(s string, n int) string {
+	if len(s) > n {
+		return s[:n]
+	}
+	return s
+}
+
+// lapTime formats a lap time as m:ss.sss
+func lapTime(t *racestatev1.TimeWithMarker) string {
+	secs := t.GetTime()
+	if secs <= 0 {
+		return ""
+	}
+	m := int(secs / 60)
+	return fmt.Sprintf("%d:%06.3f", m, secs-float32(m*60))
+}
+
+func delta(secs float32) string {
+	if secs == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%.1f", secs)
+}
+
+func duration(secs float32) string {
+	if secs < 0 {
+		return "-"
+	}
+	return time.Duration(float64(secs) * float64(time.Second)).
+		Round(time.Second).String()
+}
+
+func pitStatus(state racestatev1.CarState) string {
+	//nolint:exhaustive // by design
+	switch state {
+	case racestatev1.CarState_CAR_STATE_PIT:
+		return "PIT"
+	case racestatev1.CarState_CAR_STATE_OUT:
+		return "OUT"
+	case racestatev1.CarState_CAR_STATE_SLOW:
+		return "SLOW"
+	case racestatev1.CarState_CAR_STATE_FIN:
+		return "FIN"
+	default:
+		return ""
+	}
+}
@@ -2,16 +2,26 @@ package driver
 
 import (
 	"context"
-	"errors"
-	"io"
+	"slices"
 
 	livedatav1grpc "buf.build/gen/go/mpapenbr/iracelog/grpc/go/iracelog/livedata/v1/livedatav1grpc"
 	livedatav1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/livedata/v1"
 	"github.com/spf13/cobra"
+	"google.golang.org/grpc"
 
 	"github.com/mpapenbr/iracelog-cli/config"
 	"github.com/mpapenbr/iracelog-cli/log"
 	"github.com/mpapenbr/iracelog-cli/util"
+	"github.com/mpapenbr/iracelog-cli/util/output"
+	"github.com/mpapenbr/iracelog-cli/util/output/driver"
+	"github.com/mpapenbr/iracelog-cli/util/output/ndjson"
+	"github.com/mpapenbr/iracelog-cli/util/stream"
+)
+
+var (
+	attrs   []string
+	format  string
+	carNums []string
 )
 
 func NewLiveDriverCmd() *cobra.Command {
@@ -26,10 +36,27 @@ func NewLiveDriverCmd() *cobra.Command {
 			liveDriverData(args[0])
 		},
 	}
+	cmd.Flags().StringVar(&format, "format", "text",
+		"output format (text, json,csv)")
+	cmd.Flags().StringSliceVar(&attrs, "attrs", []string{},
+		"driver attributes to display")
+	cmd.Flags().StringSliceVar(&carNums, "carnum", []string{},
+		"filter data for these cars")
+	ndjson.AddFlags(cmd)
 	return cmd
 }
 
 func liveDriverData(eventArg string) {
+	nd, err := ndjson.NewFromCliArgs(config.DefaultCliArgs())
+	if err != nil {
+		log.Error("invalid output options", log.ErrorField(err))
+		return
+	}
+	out, err := driverOutput()
+	if err != nil {
+		log.Error("invalid output options", log.ErrorField(err))
+		return
+	}
 	sel := util.ResolveEvent(eventArg)
 	conn, err := util.ConnectGrpc(config.DefaultCliArgs())
 	if err != nil {
@@ -37,26 +64,64 @@ func liveDriverData(eventArg string) {
 		return
 	}
 	defer conn.Close()
-	req := livedatav1.LiveDriverDataRequest{
-		Event: sel,
-	}
 	c := livedatav1grpc.NewLiveDataServiceClient(conn)
-	r, err := c.LiveDriverData(context.Background(), &req)
+
+	var handle func(resp *livedatav1.LiveDriverDataResponse)
+	if nd != nil {
+		handle = func(resp *livedatav1.LiveDriverDataResponse) { nd.Line(resp) }
+	} else {
+		out.Header()
+		defer out.Flush()
+		handle = driverLines(out)
+	}
+	err = stream.Subscribe(context.Background(), "driver",
+		func(ctx context.Context, _ bool) (
+			grpc.ServerStreamingClient[livedatav1.LiveDriverDataResponse], error,
+		) {
+			return c.LiveDriverData(ctx, &livedatav1.LiveDriverDataRequest{Event: sel})
+		},
+		func(resp *livedatav1.LiveDriverDataResponse) {
+			log.Debug("got driver data: ", log.Time("ts", resp.Timestamp.AsTime()))
+			handle(resp)
+		},
+		stream.WithCliArgs(config.DefaultCliArgs()))
 	if err != nil {
-		log.Error("could not get live data", log.ErrorField(err))
-		return
+		log.Error("error fetching live driver data", log.ErrorField(err))
 	}
+}
 
-	for {
-		resp, err := r.Recv()
-		if errors.Is(err, io.EOF) {
-			break
+// driverLines returns a function to output the entries of the driver data.
+// If --carnum is set only these cars are shown.
+func driverLines(out driver.Output) func(resp *livedatav1.LiveDriverDataResponse) {
+	return func(resp *livedatav1.LiveDriverDataResponse) {
+		for _, e := range resp.Entries {
+			if len(carNums) == 0 || slices.Contains(carNums, e.GetCar().GetCarNumber()) {
+				out.Line(e, resp.CurrentDrivers[e.GetCar().GetCarIdx()])
+			}
 		}
-		if err != nil {
-			log.Error("error fetching live state", log.ErrorField(err))
-			break
-		} else {
-			log.Debug("got driver data: ", log.Time("ts", resp.Timestamp.AsTime()))
+		out.Flush()
+	}
+}
+
+func driverOutput() (driver.Output, error) {
+	opts := []driver.Option{}
+	f, err := output.ParseFormat(format)
+	if err != nil {
+		return nil, err
+	}
+	opts = append(opts, driver.WithFormat(f))
+	if len(attrs) > 0 {
+		driverAttrs := []driver.DriverAttr{}
+		for _, a := range attrs {
+			v, err := driver.ParseDriverAttr(a)
+			if err != nil {
+				return nil, err
+			}
+			driverAttrs = append(driverAttrs, v)
 		}
+		opts = append(opts, driver.WithDriverAttrs(driverAttrs))
+	} else {
+		opts = append(opts, driver.WithAllDriverAttrs())
 	}
+	return driver.NewDriverOutput(opts...), nil
 }
@@ -0,0 +1,166 @@
+package exporter
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	livedatav1grpc "buf.build/gen/go/mpapenbr/iracelog/grpc/go/iracelog/livedata/v1/livedatav1grpc"
+	livedatav1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/livedata/v1"
+	"github.com/spf13/cobra"
+	"google.golang.org/grpc"
+
+	"github.com/mpapenbr/iracelog-cli/config"
+	"github.com/mpapenbr/iracelog-cli/log"
+	"github.com/mpapenbr/iracelog-cli/util"
+	"github.com/mpapenbr/iracelog-cli/util/stream"
+)
+
+const (
+	contentTypeOpenMetrics = "application/openmetrics-text; version=1.0.0; charset=utf-8"
+	contentTypeText        = "text/plain; version=0.0.4; charset=utf-8"
+)
+
+var listen string
+
+func NewLiveExporterCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "exporter <event...>",
+		Short: "serves live race metrics for Prometheus",
+		Long: `Subscribes to the live data of the events and serves the metrics
+of the cars and sessions at /metrics (OpenMetrics format).`,
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			return nil
+		},
+		Args: cobra.MinimumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			liveExporter(cmd.Context(), args)
+		},
+	}
+	cmd.Flags().StringVar(&listen, "listen", ":9109",
+		"listen address of the metrics endpoint")
+	return cmd
+}
+
+func liveExporter(ctx context.Context, events []string) {
+	conn, err := util.ConnectGrpc(config.DefaultCliArgs())
+	if err != nil {
+		log.Error("did not connect", log.ErrorField(err))
+		return
+	}
+	defer conn.Close()
+
+	ctx, cancel := signal.NotifyContext(ctx, os.Interrupt)
+	defer cancel()
+
+	events = uniqueEvents(events)
+	reg := newRegistry(events)
+	wg := sync.WaitGroup{}
+	for _, event := range events {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			subscribe(ctx, conn, event, reg)
+		}()
+	}
+	serve(ctx, reg)
+	cancel()
+	wg.Wait()
+}
+
+// uniqueEvents removes repeated event args, each event is subscribed once
+func uniqueEvents(events []string) []string {
+	ret := make([]string, 0, len(events))
+	for _, e := range events {
+		if !slices.Contains(ret, e) {
+			ret = append(ret, e)
+		}
+	}
+	return ret
+}
+
+// serve serves the metrics until the context is done
+func serve(ctx context.Context, reg *registry) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		openMetrics := strings.Contains(r.Header.Get("Accept"),
+			"application/openmetrics-text")
+		if openMetrics {
+			w.Header().Set("Content-Type", contentTypeOpenMetrics)
+		} else {
+			w.Header().Set("Content-Type", contentTypeText)
+		}
+		if err := reg.write(w, openMetrics); err != nil {
+			log.Debug("could not write metrics", log.ErrorField(err))
+		}
+	})
+	srv := &http.Server{
+		Addr:              listen,
+		Handler:           mux,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+	go func() {
+		<-ctx.Done()
+		//nolint:errcheck // by design
+		srv.Shutdown(context.Background())
+	}()
+	log.Info("serving metrics", log.String("addr", listen))
+	if err := srv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		log.Error("could not serve metrics", log.ErrorField(err))
+	}
+}
+
+// subscribe receives the live data of the event until the context is done
+//
+//nolint:whitespace // by design
+func subscribe(
+	ctx context.Context,
+	conn *grpc.ClientConn,
+	event string,
+	reg *registry,
+) {
+	c := livedatav1grpc.NewLiveDataServiceClient(conn)
+	sel := util.ResolveEvent(event)
+	opt := stream.WithCliArgs(config.DefaultCliArgs())
+	wg := sync.WaitGroup{}
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		err := stream.Subscribe(ctx, "state",
+			func(ctx context.Context, _ bool) (
+				grpc.ServerStreamingClient[livedatav1.LiveRaceStateResponse], error,
+			) {
+				return c.LiveRaceState(ctx, &livedatav1.LiveRaceStateRequest{Event: sel})
+			},
+			func(resp *livedatav1.LiveRaceStateResponse) {
+				reg.applyState(event, resp)
+			}, opt)
+		if err != nil {
+			log.Error("live stream failed", log.String("event", event),
+				log.ErrorField(err))
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		err := stream.Subscribe(ctx, "driver",
+			func(ctx context.Context, _ bool) (
+				grpc.ServerStreamingClient[livedatav1.LiveDriverDataResponse], error,
+			) {
+				return c.LiveDriverData(ctx, &livedatav1.LiveDriverDataRequest{Event: sel})
+			},
+			func(resp *livedatav1.LiveDriverDataResponse) {
+				reg.applyDriverData(event, resp)
+			}, opt)
+		if err != nil {
+			log.Error("live stream failed", log.String("event", event),
+				log.ErrorField(err))
+		}
+	}()
+	wg.Wait()
+}
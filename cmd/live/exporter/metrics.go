@@ -0,0 +1,178 @@
+package exporter
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	carv1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/car/v1"
+	livedatav1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/livedata/v1"
+	racestatev1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/racestate/v1"
+)
+
+const metricPrefix = "iracelog_"
+
+type (
+	carMetric struct {
+		name  string
+		help  string
+		value func(c *racestatev1.Car) float64
+	}
+	sessionMetric struct {
+		name  string
+		help  string
+		value func(s *racestatev1.Session) float64
+	}
+)
+
+//nolint:lll // better readability
+var carMetrics = []carMetric{
+	{"car_position", "Position of the car", func(c *racestatev1.Car) float64 { return float64(c.GetPos()) }},
+	{"car_class_position", "Position of the car in its class", func(c *racestatev1.Car) float64 { return float64(c.GetPic()) }},
+	{"car_lap", "Current lap of the car", func(c *racestatev1.Car) float64 { return float64(c.GetLap()) }},
+	{"car_last_lap_seconds", "Last lap time", func(c *racestatev1.Car) float64 { return float64(c.GetLast().GetTime()) }},
+	{"car_best_lap_seconds", "Best lap time", func(c *racestatev1.Car) float64 { return float64(c.GetBest().GetTime()) }},
+	{"car_gap_seconds", "Gap to the leader", func(c *racestatev1.Car) float64 { return float64(c.GetGap()) }},
+	{"car_interval_seconds", "Interval to the car ahead", func(c *racestatev1.Car) float64 { return float64(c.GetInterval()) }},
+	{"car_pit_stops", "Number of pit stops", func(c *racestatev1.Car) float64 { return float64(c.GetPitstops()) }},
+	{"car_stint_laps", "Laps in the current stint", func(c *racestatev1.Car) float64 { return float64(c.GetStintLap()) }},
+	{"car_speed", "Current speed of the car", func(c *racestatev1.Car) float64 { return float64(c.GetSpeed()) }},
+}
+
+//nolint:lll // better readability
+var sessionMetrics = []sessionMetric{
+	{"session_time_seconds", "Session time", func(s *racestatev1.Session) float64 { return float64(s.GetSessionTime()) }},
+	{"session_time_remaining_seconds", "Remaining session time", func(s *racestatev1.Session) float64 { return float64(s.GetTimeRemain()) }},
+	{"session_laps_remaining", "Remaining laps (-1 if unlimited)", func(s *racestatev1.Session) float64 { return float64(s.GetLapsRemain()) }},
+	{"session_track_temp_celsius", "Track temperature", func(s *racestatev1.Session) float64 { return float64(s.GetTrackTemp()) }},
+	{"session_air_temp_celsius", "Air temperature", func(s *racestatev1.Session) float64 { return float64(s.GetAirTemp()) }},
+}
+
+// eventData holds the latest live data of an event
+type eventData struct {
+	session *racestatev1.Session
+	cars    []*racestatev1.Car
+	entries map[int32]*carv1.CarEntry // key: carIdx
+	updated time.Time
+}
+
+// registry collects the live data of all exported events
+type registry struct {
+	mu     sync.Mutex
+	events map[string]*eventData
+}
+
+func newRegistry(events []string) *registry {
+	r := &registry{events: make(map[string]*eventData)}
+	for _, e := range events {
+		r.events[e] = &eventData{entries: make(map[int32]*carv1.CarEntry)}
+	}
+	return r
+}
+
+func (r *registry) applyState(event string, resp *livedatav1.LiveRaceStateResponse) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	e := r.events[event]
+	e.session = resp.GetSession()
+	e.cars = resp.GetCars()
+	e.updated = resp.GetTimestamp().AsTime()
+}
+
+//nolint:whitespace // by design
+func (r *registry) applyDriverData(
+	event string,
+	resp *livedatav1.LiveDriverDataResponse,
+) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	e := r.events[event]
+	clear(e.entries)
+	for _, entry := range resp.GetEntries() {
+		//nolint:gosec // carIdx is small
+		e.entries[int32(entry.GetCar().GetCarIdx())] = entry
+	}
+}
+
+// write writes the metrics in the text exposition format.
+// OpenMetrics requires the terminating # EOF line.
+func (r *registry) write(w io.Writer, openMetrics bool) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	b := &strings.Builder{}
+	names := make([]string, 0, len(r.events))
+	for name := range r.events {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	family(b, "last_update_timestamp_seconds", "Time of the last race state")
+	for _, name := range names {
+		if e := r.events[name]; !e.updated.IsZero() {
+			sample(b, "last_update_timestamp_seconds",
+				float64(e.updated.UnixMilli())/1000, "event", name)
+		}
+	}
+	for _, m := range sessionMetrics {
+		family(b, m.name, m.help)
+		for _, name := range names {
+			if s := r.events[name].session; s != nil {
+				sample(b, m.name, m.value(s), "event", name)
+			}
+		}
+	}
+	family(b, "session_flag", "Current flag state (1 for the active flag)")
+	for _, name := range names {
+		if s := r.events[name].session; s != nil {
+			sample(b, "session_flag", 1, "event", name, "flag", s.GetFlagState())
+		}
+	}
+	for _, m := range carMetrics {
+		family(b, m.name, m.help)
+		for _, name := range names {
+			e := r.events[name]
+			for _, c := range e.cars {
+				entry := e.entries[c.GetCarIdx()]
+				if entry == nil {
+					continue // no driver data yet
+				}
+				sample(b, m.name, m.value(c),
+					"event", name,
+					"car_num", entry.GetCar().GetCarNumber(),
+					"car_class", entry.GetCar().GetCarClassName())
+			}
+		}
+	}
+	if openMetrics {
+		b.WriteString("# EOF\n")
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func family(b *strings.Builder, name, help string) {
+	fmt.Fprintf(b, "# HELP %s%s %s\n", metricPrefix, name, help)
+	fmt.Fprintf(b, "# TYPE %s%s gauge\n", metricPrefix, name)
+}
+
+// sample writes a single sample. labels are given as name/value pairs.
+func sample(b *strings.Builder, name string, value float64, labels ...string) {
+	b.WriteString(metricPrefix + name + "{")
+	for i := 0; i+1 < len(labels); i += 2 {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(labels[i] + `="` + escapeLabel(labels[i+1]) + `"`)
+	}
+	b.WriteString("} " + strconv.FormatFloat(value, 'g', -1, 64) + "\n")
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabel(s string) string {
+	return labelEscaper.Replace(s)
+}
@@ -0,0 +1,85 @@
+package exporter
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	carv1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/car/v1"
+	livedatav1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/livedata/v1"
+	racestatev1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/racestate/v1"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+var update = flag.Bool("update", false, "update the golden files")
+
+// testRegistry contains an event with data and one without.
+// The names contain characters that have to be escaped in label values.
+func testRegistry() *registry {
+	reg := newRegistry([]string{`quote"event`, "waiting"})
+	reg.applyDriverData(`quote"event`, &livedatav1.LiveDriverDataResponse{
+		Entries: []*carv1.CarEntry{
+			{Car: &carv1.CarInfo{CarIdx: 0, CarNumber: "1", CarClassName: `GT3\Pro`}},
+			{Car: &carv1.CarInfo{CarIdx: 1, CarNumber: "22", CarClassName: "LMP\n2"}},
+		},
+	})
+	reg.applyState(`quote"event`, &livedatav1.LiveRaceStateResponse{
+		Timestamp: timestamppb.New(time.Date(2024, 5, 1, 12, 0, 0, 500e6, time.UTC)),
+		Session: &racestatev1.Session{
+			SessionTime: 3600.5, TimeRemain: 1800, LapsRemain: -1,
+			FlagState: "GREEN", AirTemp: 21.5, TrackTemp: 30,
+		},
+		Cars: []*racestatev1.Car{
+			{
+				CarIdx: 0, Pos: 1, Pic: 1, Lap: 10, Speed: 180.5,
+				Last: &racestatev1.TimeWithMarker{Time: 91.25},
+				Best: &racestatev1.TimeWithMarker{Time: 90.5},
+			},
+			{CarIdx: 1, Pos: 2, Pic: 1, Lap: 10, Gap: 1.5, Interval: 1.5, Pitstops: 1},
+			{CarIdx: 2, Pos: 3}, // no driver data
+		},
+	})
+	return reg
+}
+
+func Test_registryWrite(t *testing.T) {
+	tests := []struct {
+		golden      string
+		openMetrics bool
+	}{
+		{golden: "metrics.txt"},
+		{golden: "metrics.openmetrics", openMetrics: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.golden, func(t *testing.T) {
+			buf := bytes.Buffer{}
+			if err := testRegistry().write(&buf, tt.openMetrics); err != nil {
+				t.Fatalf("write() error = %v", err)
+			}
+			golden := filepath.Join("testdata", tt.golden)
+			if *update {
+				if err := os.WriteFile(golden, buf.Bytes(), 0o600); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := buf.String(); got != string(want) {
+				t.Errorf("write() =\n%s\nwant\n%s", got, want)
+			}
+		})
+	}
+}
+
+func Test_uniqueEvents(t *testing.T) {
+	got := uniqueEvents([]string{"b", "a", "b", "c", "a"})
+	if want := []string{"b", "a", "c"}; !slices.Equal(got, want) {
+		t.Errorf("uniqueEvents() = %v, want %v", got, want)
+	}
+}
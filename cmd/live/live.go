@@ -1,13 +1,21 @@
 package live
 
 import (
+	"time"
+
 	"github.com/spf13/cobra"
 
+	"github.com/mpapenbr/iracelog-cli/cmd/live/alert"
 	"github.com/mpapenbr/iracelog-cli/cmd/live/analysis"
+	"github.com/mpapenbr/iracelog-cli/cmd/live/board"
 	"github.com/mpapenbr/iracelog-cli/cmd/live/driver"
+	"github.com/mpapenbr/iracelog-cli/cmd/live/exporter"
+	"github.com/mpapenbr/iracelog-cli/cmd/live/play"
+	"github.com/mpapenbr/iracelog-cli/cmd/live/record"
 	"github.com/mpapenbr/iracelog-cli/cmd/live/snapshot"
 	"github.com/mpapenbr/iracelog-cli/cmd/live/speedmap"
 	"github.com/mpapenbr/iracelog-cli/cmd/live/state"
+	"github.com/mpapenbr/iracelog-cli/cmd/live/watchall"
 	"github.com/mpapenbr/iracelog-cli/cmd/live/webclient"
 	"github.com/mpapenbr/iracelog-cli/config"
 )
@@ -16,12 +24,30 @@ func NewLiveCmd() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "live",
 		Short: "Commands regarding live data.",
+		Long: `Commands regarding live data.
+
+The commands showing live data support '--output ndjson' and '--fields' to
+write each received message as one JSON line to stdout. Commands which don't
+show the received messages have no ndjson output:
+  alert      writes alerts via its actions, not the live data
+  board      interactive terminal view
+  exporter   serves metrics via HTTP
+  play       always writes JSON lines (same format as ndjson)
+  record     writes to a capture file
+  watch-all  logs the lifecycle of the events
+  webclient  simulates load, the data is only counted`,
 		PreRunE: func(cmd *cobra.Command, args []string) error {
 			return nil
 		},
 	}
 	cmd.PersistentFlags().StringVar(&config.DefaultCliArgs().Event,
 		"event", "", "event name")
+	cmd.PersistentFlags().IntVar(&config.DefaultCliArgs().LiveMaxRetries,
+		"max-retries", 0,
+		"reconnect attempts after stream errors (0: unlimited)")
+	cmd.PersistentFlags().DurationVar(&config.DefaultCliArgs().LiveMaxBackoff,
+		"max-backoff", 30*time.Second,
+		"max delay between reconnect attempts")
 	cmd.AddCommand(state.NewLiveStateCmd())
 	cmd.AddCommand(driver.NewLiveDriverCmd())
 	cmd.AddCommand(driver.NewSendEmptyDriverData())
@@ -31,6 +57,12 @@ func NewLiveCmd() *cobra.Command {
 	cmd.AddCommand(speedmap.NewLiveSpeedmapCmd())
 	cmd.AddCommand(snapshot.NewLiveSnapshotCmd())
 	cmd.AddCommand(webclient.NewLiveWebclientCmd())
+	cmd.AddCommand(board.NewLiveBoardCmd())
+	cmd.AddCommand(record.NewLiveRecordCmd())
+	cmd.AddCommand(play.NewLivePlayCmd())
+	cmd.AddCommand(alert.NewLiveAlertCmd())
+	cmd.AddCommand(exporter.NewLiveExporterCmd())
+	cmd.AddCommand(watchall.NewLiveWatchAllCmd())
 
 	return cmd
 }
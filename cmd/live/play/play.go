@@ -0,0 +1,115 @@
+package play
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"time"
+
+	// registers the message types of the capture file
+	_ "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/livedata/v1"
+	"github.com/spf13/cobra"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/mpapenbr/iracelog-cli/config"
+	"github.com/mpapenbr/iracelog-cli/log"
+	"github.com/mpapenbr/iracelog-cli/util/capture"
+	"github.com/mpapenbr/iracelog-cli/util/output/ndjson"
+)
+
+var (
+	speed  float64
+	fields []string
+)
+
+func NewLivePlayCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "play <capture file>",
+		Short: "shows the content of a capture file at original pace",
+		Long: `Shows the content of a capture file (see 'live record').
+Each message is written as JSON line to stdout (logs go to stderr):
+
+  {"received":"...","type":"iracelog.livedata.v1.LiveRaceStateResponse","data":{...}}
+
+The data has the same format as '--output ndjson' of the other live commands.`,
+		Annotations: map[string]string{config.AnnotationStdout: "ndjson"},
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			return nil
+		},
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			livePlay(cmd.Context(), args[0])
+		},
+	}
+	cmd.Flags().Float64Var(&speed, "speed", 1,
+		"speed multiplier (0: as fast as possible)")
+	cmd.Flags().StringSliceVar(&fields, "fields", []string{},
+		"fields of the data to include (dotted paths, e.g. session.flag_state)")
+	return cmd
+}
+
+type playRecord struct {
+	Received time.Time       `json:"received"`
+	Type     string          `json:"type"`
+	Data     json.RawMessage `json:"data"`
+}
+
+func livePlay(ctx context.Context, filename string) {
+	r, err := capture.Open(filename)
+	if err != nil {
+		log.Error("could not open capture file", log.ErrorField(err))
+		return
+	}
+	defer r.Close()
+	ctx, cancel := signal.NotifyContext(ctx, os.Interrupt)
+	defer cancel()
+
+	nd := ndjson.New(ndjson.WithFields(fields))
+	var start, first time.Time // wall clock time and receive time of first record
+	for {
+		rec, err := r.Next()
+		if errors.Is(err, io.EOF) {
+			return
+		}
+		if err != nil {
+			log.Error("could not read capture file", log.ErrorField(err))
+			return
+		}
+		if first.IsZero() {
+			start, first = time.Now(), rec.Received
+		}
+		if speed > 0 {
+			due := start.Add(time.Duration(float64(rec.Received.Sub(first)) / speed))
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(time.Until(due)):
+			}
+		}
+		if err := output(os.Stdout, nd, rec); err != nil {
+			log.Error("could not output record", log.ErrorField(err))
+			return
+		}
+	}
+}
+
+func output(w io.Writer, nd *ndjson.Writer, rec *capture.Record) error {
+	data, err := nd.Marshal(rec.Msg)
+	if err != nil {
+		return err
+	}
+	line, err := json.Marshal(&playRecord{
+		Received: rec.Received,
+		Type:     string(proto.MessageName(rec.Msg)),
+		Data:     data,
+	})
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(w, string(line))
+	return err
+}
@@ -0,0 +1,214 @@
+package record
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	livedatav1grpc "buf.build/gen/go/mpapenbr/iracelog/grpc/go/iracelog/livedata/v1/livedatav1grpc"
+	commonv1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/common/v1"
+	livedatav1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/livedata/v1"
+	"github.com/spf13/cobra"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/mpapenbr/iracelog-cli/config"
+	"github.com/mpapenbr/iracelog-cli/log"
+	"github.com/mpapenbr/iracelog-cli/util"
+	"github.com/mpapenbr/iracelog-cli/util/capture"
+	"github.com/mpapenbr/iracelog-cli/util/stream"
+)
+
+var (
+	outFile       string
+	withAnalysis  bool
+	flushInterval time.Duration
+)
+
+func NewLiveRecordCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "record",
+		Short: "records the live streams of an event to a capture file",
+		Long: `Records the live streams of an event to a capture file.
+Each message is stored with the time it was received.
+Use 'live play' to show the content of a capture file.`,
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			return nil
+		},
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			liveRecord(cmd.Context(), args[0])
+		},
+	}
+	cmd.Flags().StringVar(&outFile, "out", "",
+		"capture file to write")
+	//nolint:errcheck // by design
+	cmd.MarkFlagRequired("out")
+	cmd.Flags().BoolVar(&withAnalysis, "analysis", false,
+		"record the live analysis stream too")
+	cmd.Flags().DurationVar(&flushInterval, "flush-interval", 5*time.Second,
+		"interval for writing buffered data to the file")
+	return cmd
+}
+
+func liveRecord(ctx context.Context, eventArg string) {
+	conn, err := util.ConnectGrpc(config.DefaultCliArgs())
+	if err != nil {
+		log.Error("did not connect", log.ErrorField(err))
+		return
+	}
+	defer conn.Close()
+
+	w, err := capture.Create(outFile)
+	if err != nil {
+		log.Error("could not create capture file", log.ErrorField(err))
+		return
+	}
+	ctx, cancel := signal.NotifyContext(ctx, os.Interrupt)
+	defer cancel()
+	stopFlush := startFlush(w)
+
+	log.Info("recording live data", log.String("file", outFile))
+	var count atomic.Int64
+	record(ctx, conn, util.ResolveEvent(eventArg), func(msg proto.Message) {
+		if err := w.Write(time.Now(), msg); err != nil {
+			log.Error("could not write capture file", log.ErrorField(err))
+			cancel()
+			return
+		}
+		count.Add(1)
+	})
+	stopFlush()
+	if err := w.Close(); err != nil {
+		log.Error("could not close capture file", log.ErrorField(err))
+	}
+	log.Info("recording finished",
+		log.String("file", outFile),
+		log.Int64("messages", count.Load()))
+}
+
+// startFlush flushes the writer periodically until the returned func is called
+func startFlush(w *capture.Writer) (stop func()) {
+	done := make(chan struct{})
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		ticker := time.NewTicker(max(flushInterval, time.Second))
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if err := w.Flush(); err != nil {
+					log.Warn("could not flush capture file", log.ErrorField(err))
+				}
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		<-finished
+	}
+}
+
+// record subscribes to the live streams and calls write for each message
+// until all streams are done.
+//
+//nolint:whitespace,funlen // by design
+func record(
+	ctx context.Context,
+	conn *grpc.ClientConn,
+	sel *commonv1.EventSelector,
+	write func(msg proto.Message),
+) {
+	c := livedatav1grpc.NewLiveDataServiceClient(conn)
+	wg := sync.WaitGroup{}
+	wg.Add(3)
+	go func() {
+		defer wg.Done()
+		receive(ctx, "state",
+			func(ctx context.Context, _ bool) (
+				grpc.ServerStreamingClient[livedatav1.LiveRaceStateResponse], error,
+			) {
+				return c.LiveRaceState(ctx, &livedatav1.LiveRaceStateRequest{Event: sel})
+			},
+			write)
+	}()
+	go func() {
+		defer wg.Done()
+		receive(ctx, "driver",
+			func(ctx context.Context, _ bool) (
+				grpc.ServerStreamingClient[livedatav1.LiveDriverDataResponse], error,
+			) {
+				return c.LiveDriverData(ctx, &livedatav1.LiveDriverDataRequest{Event: sel})
+			},
+			write)
+	}()
+	go func() {
+		defer wg.Done()
+		receive(ctx, "speedmap",
+			func(ctx context.Context, _ bool) (
+				grpc.ServerStreamingClient[livedatav1.LiveSpeedmapResponse], error,
+			) {
+				return c.LiveSpeedmap(ctx, &livedatav1.LiveSpeedmapRequest{Event: sel})
+			},
+			write)
+	}()
+	if withAnalysis {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			req := &livedatav1.LiveAnalysisSelRequest{
+				Event: sel,
+				Selector: &livedatav1.AnalysisSelector{
+					Components: []livedatav1.AnalysisComponent{
+						livedatav1.AnalysisComponent_ANALYSIS_COMPONENT_CAR_COMPUTE_STATES,
+						livedatav1.AnalysisComponent_ANALYSIS_COMPONENT_CAR_LAPS,
+						livedatav1.AnalysisComponent_ANALYSIS_COMPONENT_CAR_OCCUPANCIES,
+						livedatav1.AnalysisComponent_ANALYSIS_COMPONENT_CAR_PITS,
+						livedatav1.AnalysisComponent_ANALYSIS_COMPONENT_CAR_STINTS,
+						livedatav1.AnalysisComponent_ANALYSIS_COMPONENT_RACE_GRAPH,
+						livedatav1.AnalysisComponent_ANALYSIS_COMPONENT_RACE_ORDER,
+					},
+				},
+			}
+			receive(ctx, "analysis",
+				func(ctx context.Context, _ bool) (
+					grpc.ServerStreamingClient[livedatav1.LiveAnalysisSelResponse], error,
+				) {
+					return c.LiveAnalysisSel(ctx, req)
+				},
+				write)
+		}()
+	}
+	wg.Wait()
+}
+
+// receive writes the messages of the stream. Broken streams are reopened.
+//
+//nolint:whitespace // by design
+func receive[T any, P interface {
+	*T
+	proto.Message
+}](
+	ctx context.Context,
+	name string,
+	open stream.OpenFunc[T],
+	write func(msg proto.Message),
+) {
+	err := stream.Subscribe(ctx, name, open,
+		func(resp *T) { write(P(resp)) },
+		stream.WithCliArgs(config.DefaultCliArgs()))
+	switch {
+	case ctx.Err() != nil:
+		// recording stopped
+	case err == nil:
+		log.Info("live stream ended", log.String("stream", name))
+	default:
+		log.Error("live stream failed", log.String("stream", name), log.ErrorField(err))
+	}
+}
@@ -2,16 +2,17 @@ package snapshot
 
 import (
 	"context"
-	"errors"
-	"io"
 
 	livedatav1grpc "buf.build/gen/go/mpapenbr/iracelog/grpc/go/iracelog/livedata/v1/livedatav1grpc"
 	livedatav1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/livedata/v1"
 	"github.com/spf13/cobra"
+	"google.golang.org/grpc"
 
 	"github.com/mpapenbr/iracelog-cli/config"
 	"github.com/mpapenbr/iracelog-cli/log"
 	"github.com/mpapenbr/iracelog-cli/util"
+	"github.com/mpapenbr/iracelog-cli/util/output/ndjson"
+	"github.com/mpapenbr/iracelog-cli/util/stream"
 )
 
 var startFrom string
@@ -31,10 +32,17 @@ func NewLiveSnapshotCmd() *cobra.Command {
 
 	cmd.Flags().StringVar(&startFrom,
 		"start-from", "current", "begin|current")
+	ndjson.AddFlags(cmd)
 	return cmd
 }
 
+//nolint:funlen // by design
 func liveSnapshot(eventArg string) {
+	nd, err := ndjson.NewFromCliArgs(config.DefaultCliArgs())
+	if err != nil {
+		log.Error("invalid output options", log.ErrorField(err))
+		return
+	}
 	sel := util.ResolveEvent(eventArg)
 	conn, err := util.ConnectGrpc(config.DefaultCliArgs())
 	if err != nil {
@@ -55,24 +63,27 @@ func liveSnapshot(eventArg string) {
 		}
 	}
 	c := livedatav1grpc.NewLiveDataServiceClient(conn)
-	r, err := c.LiveSnapshotData(context.Background(), &req)
-	if err != nil {
-		log.Error("could not get live data", log.ErrorField(err))
-		return
-	}
-
-	for {
-		resp, err := r.Recv()
-		if errors.Is(err, io.EOF) {
-			break
-		}
-		if err != nil {
-			log.Error("error fetching live speedmap", log.ErrorField(err))
-			break
-		} else {
+	err = stream.Subscribe(context.Background(), "snapshot",
+		func(ctx context.Context, resume bool) (
+			grpc.ServerStreamingClient[livedatav1.LiveSnapshotDataResponse], error,
+		) {
+			if resume {
+				// the snapshots before the interruption were already received
+				req.StartFrom = livedatav1.SnapshotStartMode_SNAPSHOT_START_MODE_CURRENT
+			}
+			return c.LiveSnapshotData(ctx, &req)
+		},
+		func(resp *livedatav1.LiveSnapshotDataResponse) {
+			if nd != nil {
+				nd.Line(resp)
+				return
+			}
 			log.Debug("got snapshot: ",
 				log.Time("ts", resp.Timestamp.AsTime()),
 				log.Time("recstamp", resp.SnapshotData.RecordStamp.AsTime()))
-		}
+		},
+		stream.WithCliArgs(config.DefaultCliArgs()))
+	if err != nil {
+		log.Error("error fetching live snapshots", log.ErrorField(err))
 	}
 }
@@ -2,16 +2,27 @@ package speedmap
 
 import (
 	"context"
-	"errors"
-	"io"
+	"maps"
+	"slices"
 
 	livedatav1grpc "buf.build/gen/go/mpapenbr/iracelog/grpc/go/iracelog/livedata/v1/livedatav1grpc"
 	livedatav1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/livedata/v1"
 	"github.com/spf13/cobra"
+	"google.golang.org/grpc"
 
 	"github.com/mpapenbr/iracelog-cli/config"
 	"github.com/mpapenbr/iracelog-cli/log"
 	"github.com/mpapenbr/iracelog-cli/util"
+	"github.com/mpapenbr/iracelog-cli/util/output"
+	"github.com/mpapenbr/iracelog-cli/util/output/ndjson"
+	"github.com/mpapenbr/iracelog-cli/util/output/speedmap"
+	"github.com/mpapenbr/iracelog-cli/util/stream"
+)
+
+var (
+	attrs   []string
+	format  string
+	classes []string
 )
 
 func NewLiveSpeedmapCmd() *cobra.Command {
@@ -26,10 +37,27 @@ func NewLiveSpeedmapCmd() *cobra.Command {
 			liveSpeedmap(args[0])
 		},
 	}
+	cmd.Flags().StringVar(&format, "format", "text",
+		"output format (text, json,csv)")
+	cmd.Flags().StringSliceVar(&attrs, "attrs", []string{},
+		"speedmap attributes to display")
+	cmd.Flags().StringSliceVar(&classes, "carclass", []string{},
+		"filter data for these car classes")
+	ndjson.AddFlags(cmd)
 	return cmd
 }
 
 func liveSpeedmap(eventArg string) {
+	nd, err := ndjson.NewFromCliArgs(config.DefaultCliArgs())
+	if err != nil {
+		log.Error("invalid output options", log.ErrorField(err))
+		return
+	}
+	out, err := speedmapOutput()
+	if err != nil {
+		log.Error("invalid output options", log.ErrorField(err))
+		return
+	}
 	sel := util.ResolveEvent(eventArg)
 	conn, err := util.ConnectGrpc(config.DefaultCliArgs())
 	if err != nil {
@@ -37,26 +65,64 @@ func liveSpeedmap(eventArg string) {
 		return
 	}
 	defer conn.Close()
-	req := livedatav1.LiveSpeedmapRequest{
-		Event: sel,
-	}
 	c := livedatav1grpc.NewLiveDataServiceClient(conn)
-	r, err := c.LiveSpeedmap(context.Background(), &req)
+
+	var handle func(resp *livedatav1.LiveSpeedmapResponse)
+	if nd != nil {
+		handle = func(resp *livedatav1.LiveSpeedmapResponse) { nd.Line(resp) }
+	} else {
+		out.Header()
+		defer out.Flush()
+		handle = speedmapLines(out)
+	}
+	err = stream.Subscribe(context.Background(), "speedmap",
+		func(ctx context.Context, _ bool) (
+			grpc.ServerStreamingClient[livedatav1.LiveSpeedmapResponse], error,
+		) {
+			return c.LiveSpeedmap(ctx, &livedatav1.LiveSpeedmapRequest{Event: sel})
+		},
+		func(resp *livedatav1.LiveSpeedmapResponse) {
+			log.Debug("got speedmap: ", log.Time("ts", resp.Timestamp.AsTime()))
+			handle(resp)
+		},
+		stream.WithCliArgs(config.DefaultCliArgs()))
 	if err != nil {
-		log.Error("could not get live data", log.ErrorField(err))
-		return
+		log.Error("error fetching live speedmap", log.ErrorField(err))
 	}
+}
 
-	for {
-		resp, err := r.Recv()
-		if errors.Is(err, io.EOF) {
-			break
+// speedmapLines returns a function to output the classes of the speedmap.
+// If --carclass is set only these classes are shown.
+func speedmapLines(out speedmap.Output) func(resp *livedatav1.LiveSpeedmapResponse) {
+	return func(resp *livedatav1.LiveSpeedmapResponse) {
+		for _, class := range slices.Sorted(maps.Keys(resp.Speedmap.GetData())) {
+			if len(classes) == 0 || slices.Contains(classes, class) {
+				out.Line(resp.Speedmap, class)
+			}
 		}
-		if err != nil {
-			log.Error("error fetching live speedmap", log.ErrorField(err))
-			break
-		} else {
-			log.Debug("got speedmap: ", log.Time("ts", resp.Timestamp.AsTime()))
+		out.Flush()
+	}
+}
+
+func speedmapOutput() (speedmap.Output, error) {
+	opts := []speedmap.Option{}
+	f, err := output.ParseFormat(format)
+	if err != nil {
+		return nil, err
+	}
+	opts = append(opts, speedmap.WithFormat(f))
+	if len(attrs) > 0 {
+		speedmapAttrs := []speedmap.SpeedmapAttr{}
+		for _, a := range attrs {
+			v, err := speedmap.ParseSpeedmapAttr(a)
+			if err != nil {
+				return nil, err
+			}
+			speedmapAttrs = append(speedmapAttrs, v)
 		}
+		opts = append(opts, speedmap.WithSpeedmapAttrs(speedmapAttrs))
+	} else {
+		opts = append(opts, speedmap.WithAllSpeedmapAttrs())
 	}
+	return speedmap.NewSpeedmapOutput(opts...), nil
 }
@@ -2,16 +2,31 @@ package state
 
 import (
 	"context"
-	"errors"
-	"io"
+	"slices"
 
+	"buf.build/gen/go/mpapenbr/iracelog/grpc/go/iracelog/event/v1/eventv1grpc"
 	livedatav1grpc "buf.build/gen/go/mpapenbr/iracelog/grpc/go/iracelog/livedata/v1/livedatav1grpc"
+	eventv1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/event/v1"
 	livedatav1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/livedata/v1"
+	racestatev1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/racestate/v1"
 	"github.com/spf13/cobra"
+	"google.golang.org/grpc"
 
 	"github.com/mpapenbr/iracelog-cli/config"
 	"github.com/mpapenbr/iracelog-cli/log"
 	"github.com/mpapenbr/iracelog-cli/util"
+	"github.com/mpapenbr/iracelog-cli/util/output"
+	"github.com/mpapenbr/iracelog-cli/util/output/car"
+	"github.com/mpapenbr/iracelog-cli/util/output/ndjson"
+	"github.com/mpapenbr/iracelog-cli/util/output/session"
+	"github.com/mpapenbr/iracelog-cli/util/stream"
+)
+
+var (
+	attrs       []string
+	format      string
+	carNums     []string
+	showSession bool
 )
 
 func NewLiveStateCmd() *cobra.Command {
@@ -26,9 +41,20 @@ func NewLiveStateCmd() *cobra.Command {
 			liveStateData(args[0])
 		},
 	}
+	cmd.Flags().StringVar(&format, "format", "text",
+		"output format (text, json,csv)")
+	cmd.Flags().StringSliceVar(&attrs, "attrs", []string{},
+		"car attributes to display (session attributes with --session)")
+	cmd.Flags().StringSliceVar(&carNums, "carnum", []string{},
+		"filter data for these cars")
+	cmd.Flags().BoolVar(&showSession, "session", false,
+		"show session data instead of car data")
+	cmd.MarkFlagsMutuallyExclusive("session", "carnum")
+	ndjson.AddFlags(cmd)
 	return cmd
 }
 
+//nolint:funlen // by design
 func liveStateData(eventArg string) {
 	sel := util.ResolveEvent(eventArg)
 	conn, err := util.ConnectGrpc(config.DefaultCliArgs())
@@ -37,26 +63,142 @@ func liveStateData(eventArg string) {
 		return
 	}
 	defer conn.Close()
-	req := livedatav1.LiveRaceStateRequest{
-		Event: sel,
+
+	nd, err := ndjson.NewFromCliArgs(config.DefaultCliArgs())
+	if err != nil {
+		log.Error("invalid output options", log.ErrorField(err))
+		return
+	}
+	var lineFunc func(resp *livedatav1.LiveRaceStateResponse)
+	var flush func()
+	switch {
+	case nd != nil:
+		lineFunc = func(resp *livedatav1.LiveRaceStateResponse) { nd.Line(resp) }
+		flush = func() {}
+	case showSession:
+		lineFunc, flush, err = sessionLines()
+	default:
+		lineFunc, flush, err = carLines(conn, eventArg)
 	}
-	c := livedatav1grpc.NewLiveDataServiceClient(conn)
-	r, err := c.LiveRaceState(context.Background(), &req)
 	if err != nil {
-		log.Error("could not get live data", log.ErrorField(err))
+		log.Error("invalid output options", log.ErrorField(err))
 		return
 	}
+	defer flush()
 
-	for {
-		resp, err := r.Recv()
-		if errors.Is(err, io.EOF) {
-			break
-		}
-		if err != nil {
-			log.Error("error fetching live state", log.ErrorField(err))
-			break
-		} else {
+	c := livedatav1grpc.NewLiveDataServiceClient(conn)
+	err = stream.Subscribe(context.Background(), "state",
+		func(ctx context.Context, _ bool) (
+			grpc.ServerStreamingClient[livedatav1.LiveRaceStateResponse], error,
+		) {
+			return c.LiveRaceState(ctx, &livedatav1.LiveRaceStateRequest{Event: sel})
+		},
+		func(resp *livedatav1.LiveRaceStateResponse) {
 			log.Debug("got state: ", log.Time("ts", resp.Timestamp.AsTime()))
+			lineFunc(resp)
+		},
+		stream.WithCliArgs(config.DefaultCliArgs()))
+	if err != nil {
+		log.Error("error fetching live state", log.ErrorField(err))
+	}
+}
+
+// carLines returns a function to output the cars of the state data.
+// If --carnum is set only these cars are shown.
+//
+//nolint:whitespace // by design
+func carLines(conn *grpc.ClientConn, eventArg string) (
+	lineFunc func(resp *livedatav1.LiveRaceStateResponse), flush func(), err error,
+) {
+	f, err := output.ParseFormat(format)
+	if err != nil {
+		return nil, nil, err
+	}
+	opts := []car.Option{car.WithFormat(f)}
+	if len(attrs) > 0 {
+		carAttrs := []car.CarAttr{}
+		for _, a := range attrs {
+			v, err := car.ParseCarAttr(a)
+			if err != nil {
+				return nil, nil, err
+			}
+			carAttrs = append(carAttrs, v)
 		}
+		opts = append(opts, car.WithCarAttrs(carAttrs))
+	} else {
+		opts = append(opts, car.WithAllCarAttrs())
+	}
+	var carIdx map[int32]bool
+	if len(carNums) > 0 {
+		if carIdx, err = resolveCarIdx(conn, eventArg); err != nil {
+			return nil, nil, err
+		}
+	}
+	out := car.NewCarOutput(opts...)
+	out.Header()
+	return func(resp *livedatav1.LiveRaceStateResponse) {
+		for _, c := range resp.Cars {
+			if carIdx == nil || carIdx[c.CarIdx] {
+				out.Line(resp.Session, c)
+			}
+		}
+		out.Flush()
+	}, out.Flush, nil
+}
+
+// sessionLines returns a function to output the session of the state data
+//
+//nolint:whitespace // by design
+func sessionLines() (
+	lineFunc func(resp *livedatav1.LiveRaceStateResponse), flush func(), err error,
+) {
+	f, err := output.ParseFormat(format)
+	if err != nil {
+		return nil, nil, err
+	}
+	opts := []session.Option{session.WithFormat(f)}
+	if len(attrs) > 0 {
+		sessionAttrs := []session.SessionAttr{}
+		for _, a := range attrs {
+			v, err := session.ParseSessionAttr(a)
+			if err != nil {
+				return nil, nil, err
+			}
+			sessionAttrs = append(sessionAttrs, v)
+		}
+		opts = append(opts, session.WithSessionAttrs(sessionAttrs))
+	} else {
+		opts = append(opts, session.WithAllSessionAttrs())
+	}
+	out := session.NewSessionOutput(opts...)
+	out.Header()
+	return func(resp *livedatav1.LiveRaceStateResponse) {
+		out.Line(&racestatev1.PublishStateRequest{
+			Timestamp: resp.Timestamp,
+			Session:   resp.Session,
+		})
+		out.Flush()
+	}, out.Flush, nil
+}
+
+// resolveCarIdx returns the carIdx of the cars given by --carnum.
+// The car entries are taken from the event data.
+func resolveCarIdx(conn *grpc.ClientConn, eventArg string) (map[int32]bool, error) {
+	c := eventv1grpc.NewEventServiceClient(conn)
+	resp, err := c.GetEvent(context.Background(), &eventv1.GetEventRequest{
+		EventSelector: util.ResolveEvent(eventArg),
+	})
+	if err != nil {
+		return nil, err
+	}
+	ret := make(map[int32]bool)
+	for _, ce := range resp.GetCar().GetEntries() {
+		if slices.Contains(carNums, ce.GetCar().GetCarNumber()) {
+			ret[int32(ce.GetCar().GetCarIdx())] = true //nolint:gosec // carIdx is small
+		}
+	}
+	if len(ret) == 0 {
+		log.Warn("none of the cars found in event", log.Any("carnum", carNums))
 	}
+	return ret, nil
 }
@@ -0,0 +1,210 @@
+package watchall
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	livedatav1grpc "buf.build/gen/go/mpapenbr/iracelog/grpc/go/iracelog/livedata/v1/livedatav1grpc"
+	livedatav1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/livedata/v1"
+	providerv1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/provider/v1"
+	"google.golang.org/grpc"
+
+	"github.com/mpapenbr/iracelog-cli/config"
+	"github.com/mpapenbr/iracelog-cli/log"
+	"github.com/mpapenbr/iracelog-cli/util"
+	"github.com/mpapenbr/iracelog-cli/util/stream"
+)
+
+type (
+	// monitor keeps one watcher per live event.
+	// The watchers map is only used by the goroutine running the poll loop.
+	monitor struct {
+		conn     *grpc.ClientConn
+		stale    time.Duration
+		watchers map[string]*watcher // key: event key
+	}
+	// watcher tracks the lifecycle of a single event subscription
+	watcher struct {
+		key        string
+		name       string
+		cancel     context.CancelFunc
+		done       chan struct{}
+		ended      string // why the subscription ended, set before done is closed
+		mu         sync.Mutex
+		registered time.Time
+		firstData  bool
+		stale      bool
+		lastMsg    time.Time
+		msgs       int64
+		reported   int64 // msgs at the time of the last report
+		lastReport time.Time
+	}
+)
+
+func newMonitor(conn *grpc.ClientConn, stale time.Duration) *monitor {
+	return &monitor{conn: conn, stale: stale, watchers: make(map[string]*watcher)}
+}
+
+// sync subscribes to new events and stops the subscriptions of events
+// that are no longer listed. Events whose subscription ended are subscribed
+// again.
+func (m *monitor) sync(ctx context.Context, events []*providerv1.LiveEventInfo) {
+	m.reap()
+	current := make(map[string]bool, len(events))
+	for _, e := range events {
+		key := e.GetEvent().GetKey()
+		current[key] = true
+		if _, ok := m.watchers[key]; !ok {
+			m.register(ctx, e)
+		}
+	}
+	for key := range m.watchers {
+		if !current[key] {
+			m.unregister(key, "event removed")
+		}
+	}
+}
+
+func (m *monitor) register(ctx context.Context, e *providerv1.LiveEventInfo) {
+	now := time.Now()
+	wCtx, cancel := context.WithCancel(ctx)
+	w := &watcher{
+		key:        e.GetEvent().GetKey(),
+		name:       e.GetEvent().GetName(),
+		cancel:     cancel,
+		done:       make(chan struct{}),
+		registered: now,
+		lastMsg:    now,
+		lastReport: now,
+	}
+	m.watchers[w.key] = w
+	log.Info("event registered",
+		log.String("event", w.key),
+		log.String("name", w.name),
+		log.Uint32("id", e.GetEvent().GetId()))
+	go func() {
+		defer close(w.done)
+		m.subscribe(wCtx, w)
+	}()
+}
+
+// reap unregisters the events whose subscription ended on its own
+func (m *monitor) reap() {
+	for key, w := range m.watchers {
+		select {
+		case <-w.done:
+			m.unregister(key, w.ended)
+		default:
+		}
+	}
+}
+
+// unregister stops the subscription of the event and waits for it to end
+func (m *monitor) unregister(key, reason string) {
+	w := m.watchers[key]
+	delete(m.watchers, key)
+	w.cancel()
+	<-w.done
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	d := time.Since(w.registered)
+	log.Info("event unregistered",
+		log.String("event", w.key),
+		log.String("reason", reason),
+		log.Duration("watched", d),
+		log.Int64("msgs", w.msgs),
+		log.Float64("rate", rate(w.msgs, d)))
+}
+
+// shutdown stops all subscriptions
+func (m *monitor) shutdown() {
+	for key := range m.watchers {
+		m.unregister(key, "shutdown")
+	}
+}
+
+func (m *monitor) subscribe(ctx context.Context, w *watcher) {
+	c := livedatav1grpc.NewLiveDataServiceClient(m.conn)
+	sel := util.ResolveEvent(w.key)
+	err := stream.Subscribe(ctx, w.key,
+		func(ctx context.Context, _ bool) (
+			grpc.ServerStreamingClient[livedatav1.LiveRaceStateResponse], error,
+		) {
+			return c.LiveRaceState(ctx, &livedatav1.LiveRaceStateRequest{Event: sel})
+		},
+		func(_ *livedatav1.LiveRaceStateResponse) {
+			w.received()
+		},
+		stream.WithCliArgs(config.DefaultCliArgs()))
+	if err != nil {
+		log.Error("live stream failed", log.String("event", w.key),
+			log.ErrorField(err))
+		w.ended = "stream failed"
+		return
+	}
+	if ctx.Err() == nil {
+		log.Info("live stream ended", log.String("event", w.key))
+	}
+	w.ended = "stream ended"
+}
+
+func (w *watcher) received() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	now := time.Now()
+	switch {
+	case !w.firstData:
+		w.firstData = true
+		log.Info("event first data",
+			log.String("event", w.key),
+			log.Duration("after", now.Sub(w.registered)))
+	case w.stale:
+		log.Info("event active again",
+			log.String("event", w.key),
+			log.Duration("stale", now.Sub(w.lastMsg)))
+	}
+	w.stale = false
+	w.lastMsg = now
+	w.msgs++
+}
+
+// checkStale marks events without data within the stale period
+func (m *monitor) checkStale(now time.Time) {
+	m.reap()
+	for _, w := range m.watchers {
+		w.mu.Lock()
+		if !w.stale && now.Sub(w.lastMsg) > m.stale {
+			w.stale = true
+			log.Warn("event stale",
+				log.String("event", w.key),
+				log.Bool("firstData", w.firstData),
+				log.Duration("noData", now.Sub(w.lastMsg)))
+		}
+		w.mu.Unlock()
+	}
+}
+
+// report logs the message rate of each event since the last report
+func (m *monitor) report(now time.Time) {
+	for _, w := range m.watchers {
+		w.mu.Lock()
+		n := w.msgs - w.reported
+		log.Info("event rate",
+			log.String("event", w.key),
+			log.Int64("msgs", n),
+			log.Float64("rate", rate(n, now.Sub(w.lastReport))),
+			log.Bool("stale", w.stale))
+		w.reported = w.msgs
+		w.lastReport = now
+		w.mu.Unlock()
+	}
+}
+
+// rate returns the messages per second
+func rate(n int64, d time.Duration) float64 {
+	if d <= 0 {
+		return 0
+	}
+	return float64(n) / d.Seconds()
+}
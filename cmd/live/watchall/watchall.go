@@ -0,0 +1,125 @@
+package watchall
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"time"
+
+	providerv1grpc "buf.build/gen/go/mpapenbr/iracelog/grpc/go/iracelog/provider/v1/providerv1grpc"
+	providerv1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/provider/v1"
+	"github.com/spf13/cobra"
+
+	"github.com/mpapenbr/iracelog-cli/config"
+	"github.com/mpapenbr/iracelog-cli/log"
+	"github.com/mpapenbr/iracelog-cli/util"
+)
+
+var (
+	externalID     string
+	name           string
+	pollInterval   time.Duration
+	staleTimeout   time.Duration
+	reportInterval time.Duration
+)
+
+type (
+	tenantParam struct{}
+)
+
+func (t tenantParam) ExternalID() string {
+	return externalID
+}
+
+func (t tenantParam) Name() string {
+	return name
+}
+
+func NewLiveWatchAllCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "watch-all",
+		Short: "follows all live events",
+		Long: `Polls the list of live events and subscribes to the state data of each
+event. Subscriptions are stopped once an event is no longer listed.
+Lifecycle transitions (registered, first data, stale, unregistered) and
+message rates are logged per event.
+The live data is not written, so there is no ndjson output.`,
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			return nil
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+			watchAll(cmd.Context())
+		},
+	}
+	cmd.Flags().StringVar(&externalID, "tenant-external-id", "",
+		"external id of the tenant")
+	cmd.Flags().StringVar(&name, "tenant-name", "",
+		"name of the tenant")
+	cmd.Flags().DurationVar(&pollInterval, "poll", 10*time.Second,
+		"interval for polling the live events")
+	cmd.Flags().DurationVar(&staleTimeout, "stale", 30*time.Second,
+		"mark an event as stale if no data was received within this period")
+	cmd.Flags().DurationVar(&reportInterval, "report", time.Minute,
+		"interval for logging the message rates (0: disabled)")
+	return cmd
+}
+
+func watchAll(ctx context.Context) {
+	conn, err := util.ConnectGrpc(config.DefaultCliArgs())
+	if err != nil {
+		log.Error("did not connect", log.ErrorField(err))
+		return
+	}
+	defer conn.Close()
+
+	ctx, cancel := signal.NotifyContext(ctx, os.Interrupt)
+	defer cancel()
+
+	m := newMonitor(conn, staleTimeout)
+	defer m.shutdown()
+
+	c := providerv1grpc.NewProviderServiceClient(conn)
+	req := &providerv1.ListLiveEventsRequest{
+		TenantSelector: util.ResolveTenant(tenantParam{}),
+	}
+	poll := func() {
+		resp, err := c.ListLiveEvents(ctx, req)
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Error("could not list live events", log.ErrorField(err))
+			}
+			return
+		}
+		m.sync(ctx, resp.GetEvents())
+	}
+	log.Info("watching live events", log.Duration("poll", pollInterval))
+	poll()
+	loop(ctx, m, poll)
+}
+
+// loop polls the live events and checks the watched events until the
+// context is done
+func loop(ctx context.Context, m *monitor, poll func()) {
+	pollTicker := time.NewTicker(pollInterval)
+	defer pollTicker.Stop()
+	staleTicker := time.NewTicker(max(staleTimeout/4, time.Second))
+	defer staleTicker.Stop()
+	var reportC <-chan time.Time
+	if reportInterval > 0 {
+		reportTicker := time.NewTicker(reportInterval)
+		defer reportTicker.Stop()
+		reportC = reportTicker.C
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-pollTicker.C:
+			poll()
+		case now := <-staleTicker.C:
+			m.checkStale(now)
+		case now := <-reportC:
+			m.report(now)
+		}
+	}
+}
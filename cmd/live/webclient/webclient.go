@@ -21,6 +21,8 @@ func NewLiveWebclientCmd() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "webclient",
 		Short: "simulates a webclient for live data",
+		Long: `Simulates a webclient subscribing to all live data of an event.
+The received data is only counted (see --stats), so there is no ndjson output.`,
 		PreRunE: func(cmd *cobra.Command, args []string) error {
 			return nil
 		},
@@ -0,0 +1,137 @@
+package racegraph
+
+import (
+	"cmp"
+	"maps"
+	"slices"
+	"sync"
+
+	analysisv1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/analysis/v1"
+)
+
+type (
+	// graph collects the race graph data per car class.
+	// Live data only contains the latest laps, so new data is merged.
+	graph struct {
+		mu      sync.Mutex
+		classes map[string]map[int32][]*analysisv1.GapInfo // class -> lap -> gaps
+	}
+	point struct {
+		lap int32
+		gap float64
+	}
+	series struct {
+		carNum string
+		points []point // ordered by lap
+	}
+	// chart contains the data of a single class
+	chart struct {
+		class          string
+		minLap, maxLap int32
+		maxGap         float64
+		series         []series // ordered by position in the latest lap
+	}
+)
+
+func newGraph() *graph {
+	return &graph{classes: make(map[string]map[int32][]*analysisv1.GapInfo)}
+}
+
+func (g *graph) add(data []*analysisv1.RaceGraph) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for _, rg := range data {
+		laps, ok := g.classes[rg.GetCarClass()]
+		if !ok {
+			laps = make(map[int32][]*analysisv1.GapInfo)
+			g.classes[rg.GetCarClass()] = laps
+		}
+		laps[rg.GetLapNo()] = rg.GetGaps()
+	}
+}
+
+// charts returns the charts of the requested classes (empty: all).
+// Each chart contains at most top cars (0: all) unless cars are given.
+func (g *graph) charts(classes, cars []string, top int, maxGap float64) []*chart {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	ret := []*chart{}
+	for _, class := range slices.Sorted(maps.Keys(g.classes)) {
+		if len(classes) > 0 && !slices.Contains(classes, class) {
+			continue
+		}
+		if c := newChart(class, g.classes[class], cars, top, maxGap); c != nil {
+			ret = append(ret, c)
+		}
+	}
+	return ret
+}
+
+//nolint:whitespace,cyclop // by design
+func newChart(
+	class string,
+	laps map[int32][]*analysisv1.GapInfo,
+	cars []string,
+	top int,
+	maxGap float64,
+) *chart {
+	lapNums := slices.Sorted(maps.Keys(laps))
+	if len(lapNums) == 0 {
+		return nil
+	}
+	c := &chart{class: class, minLap: lapNums[0], maxLap: lapNums[len(lapNums)-1]}
+	byCar := map[string]*series{}
+	last := map[string]*analysisv1.GapInfo{} // latest entry of the car
+	lastLap := map[string]int32{}
+	for _, lap := range lapNums {
+		for _, gi := range laps[lap] {
+			if len(cars) > 0 && !slices.Contains(cars, gi.GetCarNum()) {
+				continue
+			}
+			s, ok := byCar[gi.GetCarNum()]
+			if !ok {
+				s = &series{carNum: gi.GetCarNum()}
+				byCar[gi.GetCarNum()] = s
+			}
+			gap := float64(gi.GetGap())
+			if maxGap > 0 {
+				gap = min(gap, maxGap)
+			}
+			s.points = append(s.points, point{lap: lap, gap: gap})
+			c.maxGap = max(c.maxGap, gap)
+			last[gi.GetCarNum()] = gi
+			lastLap[gi.GetCarNum()] = lap
+		}
+	}
+	// cars with more laps first, then by gap
+	order := slices.SortedFunc(maps.Keys(byCar), func(a, b string) int {
+		if lastLap[a] != lastLap[b] {
+			return cmp.Compare(lastLap[b], lastLap[a])
+		}
+		return cmp.Compare(last[a].GetGap(), last[b].GetGap())
+	})
+	if top > 0 && len(cars) == 0 && len(order) > top {
+		order = order[:top]
+	}
+	for _, num := range order {
+		c.series = append(c.series, *byCar[num])
+	}
+	return c
+}
+
+// gapAt returns the gap at the (fractional) lap by linear interpolation.
+// ok is false if the car has no data around this lap.
+func (s *series) gapAt(lap float64) (gap float64, ok bool) {
+	i, found := slices.BinarySearchFunc(s.points, lap, func(p point, l float64) int {
+		return cmp.Compare(float64(p.lap), l)
+	})
+	if found {
+		return s.points[i].gap, true
+	}
+	if i == 0 || i == len(s.points) {
+		return 0, false
+	}
+	a, b := s.points[i-1], s.points[i]
+	f := (lap - float64(a.lap)) / float64(b.lap-a.lap)
+	return a.gap + f*(b.gap-a.gap), true
+}
@@ -0,0 +1,147 @@
+package racegraph
+
+import (
+	"reflect"
+	"testing"
+
+	analysisv1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/analysis/v1"
+)
+
+type carGap struct {
+	num string
+	gap float32
+}
+
+// lapData returns the race graph of a lap
+func lapData(class string, lap int32, gaps ...carGap) *analysisv1.RaceGraph {
+	ret := &analysisv1.RaceGraph{CarClass: class, LapNo: lap}
+	for _, g := range gaps {
+		ret.Gaps = append(ret.Gaps,
+			&analysisv1.GapInfo{CarNum: g.num, LapNo: lap, Gap: g.gap})
+	}
+	return ret
+}
+
+//nolint:funlen // table
+func Test_graphCharts(t *testing.T) {
+	// live data contains the latest laps only, lap 2 is updated by the second add
+	data := [][]*analysisv1.RaceGraph{
+		{
+			lapData("GT3", 1, carGap{"1", 0}, carGap{"2", 1.5}, carGap{"3", 3}),
+			lapData("GT3", 2, carGap{"1", 0}, carGap{"2", 2}),
+			lapData("LMP", 1, carGap{"9", 0}),
+		},
+		{
+			lapData("GT3", 2, carGap{"1", 0}, carGap{"2", 2.5}, carGap{"3", 4}),
+			lapData("GT3", 3, carGap{"2", 0}, carGap{"1", 1}),
+		},
+	}
+	tests := []struct {
+		name    string
+		classes []string
+		cars    []string
+		top     int
+		maxGap  float64
+		want    []*chart
+	}{
+		{
+			name: "merged",
+			want: []*chart{
+				{
+					class: "GT3", minLap: 1, maxLap: 3, maxGap: 4,
+					series: []series{
+						{carNum: "2", points: []point{{1, 1.5}, {2, 2.5}, {3, 0}}},
+						{carNum: "1", points: []point{{1, 0}, {2, 0}, {3, 1}}},
+						{carNum: "3", points: []point{{1, 3}, {2, 4}}},
+					},
+				},
+				{
+					class: "LMP", minLap: 1, maxLap: 1,
+					series: []series{{carNum: "9", points: []point{{1, 0}}}},
+				},
+			},
+		},
+		{
+			name:    "classes, top and max gap",
+			classes: []string{"GT3"},
+			top:     2,
+			maxGap:  2,
+			want: []*chart{
+				{
+					class: "GT3", minLap: 1, maxLap: 3, maxGap: 2,
+					series: []series{
+						{carNum: "2", points: []point{{1, 1.5}, {2, 2}, {3, 0}}},
+						{carNum: "1", points: []point{{1, 0}, {2, 0}, {3, 1}}},
+					},
+				},
+			},
+		},
+		{
+			name:    "cars ignore top",
+			classes: []string{"GT3"},
+			cars:    []string{"3", "1"},
+			top:     1,
+			want: []*chart{
+				{
+					class: "GT3", minLap: 1, maxLap: 3, maxGap: 4,
+					series: []series{
+						{carNum: "1", points: []point{{1, 0}, {2, 0}, {3, 1}}},
+						{carNum: "3", points: []point{{1, 3}, {2, 4}}},
+					},
+				},
+			},
+		},
+		{
+			name:    "unknown class",
+			classes: []string{"GTE"},
+			want:    []*chart{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := newGraph()
+			for _, d := range data {
+				g.add(d)
+			}
+			got := g.charts(tt.classes, tt.cars, tt.top, tt.maxGap)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("charts() = %v, want %v", dump(got), dump(tt.want))
+			}
+		})
+	}
+}
+
+func dump(charts []*chart) []chart {
+	ret := []chart{}
+	for _, c := range charts {
+		ret = append(ret, *c)
+	}
+	return ret
+}
+
+func Test_seriesGapAt(t *testing.T) {
+	// lap 3 is missing
+	s := &series{carNum: "1", points: []point{{1, 1}, {2, 3}, {4, 7}}}
+	tests := []struct {
+		name    string
+		lap     float64
+		wantGap float64
+		wantOk  bool
+	}{
+		{name: "first lap", lap: 1, wantGap: 1, wantOk: true},
+		{name: "between laps", lap: 1.25, wantGap: 1.5, wantOk: true},
+		{name: "missing lap", lap: 3, wantGap: 5, wantOk: true},
+		{name: "last lap", lap: 4, wantGap: 7, wantOk: true},
+		{name: "before first lap", lap: 0.5, wantOk: false},
+		{name: "after last lap", lap: 4.5, wantOk: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gap, ok := s.gapAt(tt.lap)
+			if gap != tt.wantGap || ok != tt.wantOk {
+				t.Errorf("gapAt(%v) = %v, %v, want %v, %v",
+					tt.lap, gap, ok, tt.wantGap, tt.wantOk)
+			}
+		})
+	}
+}
@@ -0,0 +1,169 @@
+package racegraph
+
+import (
+	"context"
+	"os"
+	"os/signal"
+
+	"buf.build/gen/go/mpapenbr/iracelog/grpc/go/iracelog/event/v1/eventv1grpc"
+	livedatav1grpc "buf.build/gen/go/mpapenbr/iracelog/grpc/go/iracelog/livedata/v1/livedatav1grpc"
+	commonv1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/common/v1"
+	eventv1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/event/v1"
+	livedatav1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/livedata/v1"
+	"github.com/spf13/cobra"
+	"google.golang.org/grpc"
+
+	"github.com/mpapenbr/iracelog-cli/config"
+	"github.com/mpapenbr/iracelog-cli/log"
+	"github.com/mpapenbr/iracelog-cli/util"
+	"github.com/mpapenbr/iracelog-cli/util/stream"
+)
+
+var (
+	live       bool
+	tailNum    int
+	carClasses []string
+	carNums    []string
+	top        int
+	maxGap     float64
+	width      int
+	height     int
+	svgFile    string
+	noColor    bool
+)
+
+func NewRaceGraphCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "racegraph",
+		Short: "shows the gaps to the leader over the laps",
+		Long: `Shows the gaps to the class leader over the laps as a line chart.
+Each car is drawn with its own symbol. With --svg the chart is also
+written to a standalone SVG file (one panel per car class).`,
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			return nil
+		},
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			raceGraph(cmd.Context(), args[0])
+		},
+	}
+	cmd.Flags().BoolVar(&live, "live", false,
+		"use the live data of a running event")
+	cmd.Flags().IntVar(&tailNum, "tail", 0,
+		"number of laps requested with each live update (0: all)")
+	cmd.Flags().StringSliceVar(&carClasses, "carclass", []string{},
+		"show only these car classes")
+	cmd.Flags().StringSliceVar(&carNums, "carnum", []string{},
+		"show only these cars")
+	cmd.Flags().IntVar(&top, "top", 10,
+		"show the leading cars of each class (0: all)")
+	cmd.Flags().Float64Var(&maxGap, "max-gap", 0,
+		"limit the gap axis to this value in seconds (0: auto)")
+	cmd.Flags().IntVar(&width, "width", 100,
+		"width of the terminal chart")
+	cmd.Flags().IntVar(&height, "height", 20,
+		"height of the terminal chart")
+	cmd.Flags().StringVar(&svgFile, "svg", "",
+		"write the chart to this SVG file")
+	cmd.Flags().BoolVar(&noColor, "no-color", false,
+		"draw the terminal chart without colors")
+	return cmd
+}
+
+func raceGraph(ctx context.Context, eventArg string) {
+	conn, err := util.ConnectGrpc(config.DefaultCliArgs())
+	if err != nil {
+		log.Error("did not connect", log.ErrorField(err))
+		return
+	}
+	defer conn.Close()
+
+	ctx, cancel := signal.NotifyContext(ctx, os.Interrupt)
+	defer cancel()
+
+	sel := util.ResolveEvent(eventArg)
+	g := newGraph()
+	if live {
+		err = liveRaceGraph(ctx, conn, sel, g)
+	} else {
+		err = storedRaceGraph(ctx, conn, sel, g)
+	}
+	if err != nil {
+		log.Error("could not get race graph", log.ErrorField(err))
+	}
+}
+
+//nolint:whitespace // by design
+func storedRaceGraph(
+	ctx context.Context,
+	conn *grpc.ClientConn,
+	sel *commonv1.EventSelector,
+	g *graph,
+) error {
+	c := eventv1grpc.NewEventServiceClient(conn)
+	resp, err := c.GetEvent(ctx, &eventv1.GetEventRequest{EventSelector: sel})
+	if err != nil {
+		return err
+	}
+	g.add(resp.GetAnalysis().GetRaceGraph())
+	return output(g)
+}
+
+// liveRaceGraph redraws the chart with each update
+//
+//nolint:whitespace // by design
+func liveRaceGraph(
+	ctx context.Context,
+	conn *grpc.ClientConn,
+	sel *commonv1.EventSelector,
+	g *graph,
+) error {
+	c := livedatav1grpc.NewLiveDataServiceClient(conn)
+	req := &livedatav1.LiveAnalysisSelRequest{
+		Event: sel,
+		Selector: &livedatav1.AnalysisSelector{
+			Components: []livedatav1.AnalysisComponent{
+				livedatav1.AnalysisComponent_ANALYSIS_COMPONENT_RACE_GRAPH,
+			},
+			RaceGraphNumTail: uint32(tailNum), //nolint:gosec // small value
+		},
+	}
+	return stream.Subscribe(ctx, "racegraph",
+		func(ctx context.Context, _ bool) (
+			grpc.ServerStreamingClient[livedatav1.LiveAnalysisSelResponse], error,
+		) {
+			return c.LiveAnalysisSel(ctx, req)
+		},
+		func(resp *livedatav1.LiveAnalysisSelResponse) {
+			log.Debug("got race graph", log.Int("entries", len(resp.GetRaceGraph())))
+			g.add(resp.GetRaceGraph())
+			if err := output(g); err != nil {
+				log.Error("could not write race graph", log.ErrorField(err))
+			}
+		},
+		stream.WithCliArgs(config.DefaultCliArgs()))
+}
+
+// output draws the terminal chart and writes the SVG file if requested
+func output(g *graph) error {
+	charts := g.charts(carClasses, carNums, top, maxGap)
+	if len(charts) == 0 {
+		log.Warn("no race graph data available")
+		return nil
+	}
+	for _, c := range charts {
+		c.renderText(os.Stdout, width, height, !noColor)
+	}
+	if svgFile == "" {
+		return nil
+	}
+	f, err := os.Create(svgFile)
+	if err != nil {
+		return err
+	}
+	if err := writeSVG(f, charts); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,129 @@
+package racegraph
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+)
+
+// layout of a chart panel in the SVG
+const (
+	svgWidth       = 1000
+	svgPanelHeight = 450
+	svgMarginLeft  = 60
+	svgMarginRight = 120 // legend
+	svgMarginTop   = 40
+	svgMarginBot   = 40
+)
+
+var svgColors = []string{
+	"#e6194b", "#3cb44b", "#4363d8", "#f58231", "#911eb4", "#46f0f0",
+	"#f032e6", "#bcf60c", "#008080", "#9a6324", "#800000", "#000075",
+	"#808000", "#fabebe", "#e6beff", "#aaffc3", "#ffd8b1", "#808080",
+}
+
+// writeSVG writes the charts as a standalone SVG document, one panel per chart
+func writeSVG(w io.Writer, charts []*chart) error {
+	b := &strings.Builder{}
+	height := max(len(charts), 1) * svgPanelHeight
+	fmt.Fprintf(b, `<svg xmlns="http://www.w3.org/2000/svg" `+
+		`width="%d" height="%d" viewBox="0 0 %d %d" `+
+		`font-family="sans-serif" font-size="12">`+"\n",
+		svgWidth, height, svgWidth, height)
+	fmt.Fprintf(b, `<rect width="100%%" height="100%%" fill="white"/>`+"\n")
+	for i, c := range charts {
+		fmt.Fprintf(b, `<g transform="translate(0,%d)">`+"\n", i*svgPanelHeight)
+		c.svgPanel(b)
+		b.WriteString("</g>\n")
+	}
+	b.WriteString("</svg>\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+//nolint:funlen // by design
+func (c *chart) svgPanel(b *strings.Builder) {
+	plotW := float64(svgWidth - svgMarginLeft - svgMarginRight)
+	plotH := float64(svgPanelHeight - svgMarginTop - svgMarginBot)
+	lapRange := max(float64(c.maxLap-c.minLap), 1)
+	maxGap := max(c.maxGap, 1)
+	x := func(lap float64) float64 {
+		return svgMarginLeft + (lap-float64(c.minLap))/lapRange*plotW
+	}
+	y := func(gap float64) float64 {
+		return svgMarginTop + gap/maxGap*plotH
+	}
+
+	fmt.Fprintf(b, `<text x="%d" y="%d" font-size="16" font-weight="bold">%s`+
+		` - gap to leader (s)</text>`+"\n",
+		svgMarginLeft, svgMarginTop-15, esc(c.class))
+	// grid and axis labels
+	for _, lap := range ticks(float64(c.minLap), float64(c.maxLap), 1) {
+		fmt.Fprintf(b, `<line x1="%.1f" y1="%d" x2="%.1f" y2="%.1f" stroke="#ddd"/>`+
+			`<text x="%.1f" y="%.1f" text-anchor="middle">%.4g</text>`+"\n",
+			x(lap), svgMarginTop, x(lap), y(maxGap),
+			x(lap), y(maxGap)+15, lap)
+	}
+	for _, gap := range ticks(0, maxGap, 0) {
+		fmt.Fprintf(b, `<line x1="%d" y1="%.1f" x2="%.1f" y2="%.1f" stroke="#ddd"/>`+
+			`<text x="%d" y="%.1f" text-anchor="end">%.4g</text>`+"\n",
+			svgMarginLeft, y(gap), x(float64(c.maxLap)), y(gap),
+			svgMarginLeft-5, y(gap)+4, gap)
+	}
+	fmt.Fprintf(b, `<text x="%.1f" y="%.1f" text-anchor="middle">lap</text>`+"\n",
+		svgMarginLeft+plotW/2, y(maxGap)+32)
+
+	for i := range c.series {
+		s := &c.series[i]
+		color := svgColors[i%len(svgColors)]
+		points := make([]string, 0, len(s.points))
+		for _, p := range s.points {
+			points = append(points,
+				fmt.Sprintf("%.1f,%.1f", x(float64(p.lap)), y(p.gap)))
+		}
+		fmt.Fprintf(b, `<polyline fill="none" stroke="%s" stroke-width="1.5" `+
+			`points="%s"><title>#%s</title></polyline>`+"\n",
+			color, strings.Join(points, " "), esc(s.carNum))
+		// legend
+		ly := svgMarginTop + 15*i
+		if ly > svgPanelHeight-svgMarginBot {
+			continue // no more space
+		}
+		lx := svgWidth - svgMarginRight + 15
+		fmt.Fprintf(b, `<line x1="%d" y1="%d" x2="%d" y2="%d" stroke="%s" `+
+			`stroke-width="3"/><text x="%d" y="%d">#%s</text>`+"\n",
+			lx, ly, lx+20, ly, color, lx+25, ly+4, esc(s.carNum))
+	}
+}
+
+// ticks returns about 10 rounded values between from and to.
+// The distance between the values is at least minStep.
+func ticks(from, to, minStep float64) []float64 {
+	if to <= from {
+		return []float64{from}
+	}
+	raw := (to - from) / 10
+	step := math.Pow(10, math.Floor(math.Log10(raw)))
+	for _, f := range []float64{1, 2, 5, 10} {
+		if f*step >= raw {
+			step *= f
+			break
+		}
+	}
+	step = max(step, minStep)
+	ret := []float64{}
+	for i := math.Ceil(from / step); i*step <= to; i++ {
+		ret = append(ret, i*step)
+	}
+	return ret
+}
+
+func esc(s string) string {
+	buf := bytes.Buffer{}
+	//nolint:errcheck // writing to a buffer
+	xml.EscapeText(&buf, []byte(s))
+	return buf.String()
+}
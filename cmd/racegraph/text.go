@@ -0,0 +1,90 @@
+package racegraph
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"strings"
+)
+
+const ansiReset = "\x1b[0m"
+
+var (
+	ansiColors = []string{
+		"\x1b[31m", "\x1b[32m", "\x1b[33m", "\x1b[34m", "\x1b[35m", "\x1b[36m",
+		"\x1b[91m", "\x1b[92m", "\x1b[93m", "\x1b[94m", "\x1b[95m", "\x1b[96m",
+	}
+	symbols = []rune("ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz")
+)
+
+// renderText draws the chart as lines of symbols, one symbol per car.
+// The leader is at the top, the x axis shows the laps.
+//
+//nolint:funlen // by design
+func (c *chart) renderText(w io.Writer, width, height int, color bool) {
+	width, height = max(width, 2), max(height, 2)
+	grid := make([][]int, height) // index of the series + 1
+	for i := range grid {
+		grid[i] = make([]int, width)
+	}
+	lapRange := float64(c.maxLap - c.minLap)
+	// draw the leading cars last to keep them visible
+	for idx := len(c.series) - 1; idx >= 0; idx-- {
+		s := &c.series[idx]
+		for x := range width {
+			lap := float64(c.minLap) + float64(x)*lapRange/float64(width-1)
+			gap, ok := s.gapAt(lap)
+			if !ok {
+				continue
+			}
+			grid[c.row(gap, height)][x] = idx + 1
+		}
+	}
+
+	b := &strings.Builder{}
+	fmt.Fprintf(b, "%s - gap to leader (s) over laps %d-%d\n",
+		c.class, c.minLap, c.maxLap)
+	for y, row := range grid {
+		label := ""
+		if y == 0 || y == height-1 || y == height/2 {
+			label = fmt.Sprintf("%.1f", c.maxGap*float64(y)/float64(height-1))
+		}
+		fmt.Fprintf(b, "%8s |", label)
+		for _, idx := range row {
+			if idx == 0 {
+				b.WriteByte(' ')
+				continue
+			}
+			b.WriteString(symbol(idx-1, color))
+		}
+		b.WriteString("\n")
+	}
+	fmt.Fprintf(b, "%8s +%s\n", "", strings.Repeat("-", width))
+	minLap := fmt.Sprintf("%d", c.minLap)
+	fmt.Fprintf(b, "%8s  %s%*d\n", "", minLap, width-len(minLap), c.maxLap)
+	for i := range c.series {
+		if i > 0 && i%10 == 0 {
+			b.WriteString("\n")
+		}
+		fmt.Fprintf(b, " %s #%-4s", symbol(i, color), c.series[i].carNum)
+	}
+	b.WriteString("\n\n")
+	//nolint:errcheck // by design
+	io.WriteString(w, b.String())
+}
+
+func (c *chart) row(gap float64, height int) int {
+	if c.maxGap <= 0 {
+		return 0
+	}
+	r := int(math.Round(gap / c.maxGap * float64(height-1)))
+	return min(max(r, 0), height-1)
+}
+
+func symbol(idx int, color bool) string {
+	s := string(symbols[idx%len(symbols)])
+	if !color {
+		return s
+	}
+	return ansiColors[idx%len(ansiColors)] + s + ansiReset
+}
@@ -19,6 +19,8 @@ import (
 	"github.com/mpapenbr/iracelog-cli/cmd/live"
 	"github.com/mpapenbr/iracelog-cli/cmd/predict"
 	"github.com/mpapenbr/iracelog-cli/cmd/provider"
+	"github.com/mpapenbr/iracelog-cli/cmd/racegraph"
+	"github.com/mpapenbr/iracelog-cli/cmd/speedmap"
 	"github.com/mpapenbr/iracelog-cli/cmd/stress"
 	"github.com/mpapenbr/iracelog-cli/cmd/tenant"
 	"github.com/mpapenbr/iracelog-cli/cmd/track"
@@ -52,6 +54,11 @@ var rootCmd = &cobra.Command{
 				log.Fatal("could not load log config", log.ErrorField(err))
 			}
 		}
+		if config.DefaultCliArgs().Output != "" ||
+			cmd.Annotations[config.AnnotationStdout] != "" {
+			// stdout is reserved for the data
+			logConfig.RedirectStdout()
+		}
 		l := log.NewWithConfig(logConfig, config.DefaultCliArgs().LogLevel)
 		cmd.SetContext(log.AddToContext(context.Background(), l))
 		log.ResetDefault(l)
@@ -122,6 +129,8 @@ func init() {
 	rootCmd.AddCommand(tenant.NewTenantCmd())
 	rootCmd.AddCommand(predict.NewPredictCmd())
 	rootCmd.AddCommand(demo.NewDemoCmd())
+	rootCmd.AddCommand(speedmap.NewSpeedmapCmd())
+	rootCmd.AddCommand(racegraph.NewRaceGraphCmd())
 
 	// add commands here
 	// e.g. rootCmd.AddCommand(sampleCmd.NewSampleCmd())
@@ -0,0 +1,255 @@
+package show
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	speedmapv1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/speedmap/v1"
+)
+
+const (
+	ansiSlow  = "\x1b[1;30;43m" // bold black on yellow
+	ansiPit   = "\x1b[36m"
+	ansiReset = "\x1b[0m"
+)
+
+// blocks visualize the speed relative to the fastest chunk of the class
+var blocks = []rune("▁▂▃▄▅▆▇█")
+
+// reference keeps the highest speed seen per class and chunk.
+// A chunk is slow if the current speed is well below this reference.
+type reference map[string][]float64
+
+func (r reference) update(sm *speedmapv1.Speedmap) {
+	for class, data := range sm.GetData() {
+		speeds := data.GetChunkSpeeds()
+		ref := r[class]
+		if len(ref) != len(speeds) {
+			ref = make([]float64, len(speeds))
+			r[class] = ref
+		}
+		for i, s := range speeds {
+			ref[i] = max(ref[i], s)
+		}
+	}
+}
+
+// pitLane is the part of the track (0-1) between pit entry and pit exit
+type pitLane struct {
+	entry, exit float32
+}
+
+func (p *pitLane) contains(pct float32) bool {
+	if p == nil {
+		return false
+	}
+	if p.entry <= p.exit {
+		return pct >= p.entry && pct <= p.exit
+	}
+	return pct >= p.entry || pct <= p.exit // pit lane crosses the line
+}
+
+type (
+	renderer struct {
+		w         io.Writer
+		table     bool
+		width     int
+		threshold float64
+		color     bool
+		classes   []string // empty: all classes
+		pit       *pitLane
+		ref       reference
+	}
+	// segment aggregates consecutive chunks to fit the output width.
+	// All classes share the same segments, so the segment i of each class
+	// covers the same part of the track.
+	segment struct {
+		speed float64 // lowest speed of the chunks
+		ratio float64 // lowest speed relative to the reference
+		frac  float64 // speed relative to the fastest chunk of the class
+		valid bool    // false if there is no data for the segment
+	}
+)
+
+func (r *renderer) render(sm *speedmapv1.Speedmap) {
+	b := &strings.Builder{}
+	fmt.Fprintf(b, "Session time %s  Track %.0fm  Chunk size %dm\n",
+		time.Duration(float64(sm.GetSessionTime())*float64(time.Second)),
+		sm.GetTrackLength(),
+		sm.GetChunkSize())
+	classes := slices.Sorted(maps.Keys(sm.GetData()))
+	if len(r.classes) > 0 {
+		classes = slices.DeleteFunc(classes, func(c string) bool {
+			return !slices.Contains(r.classes, c)
+		})
+	}
+	n := r.numSegments(sm, classes)
+	segs := make(map[string][]segment, len(classes))
+	for _, c := range classes {
+		segs[c] = r.segments(sm, c, n)
+	}
+	if r.table {
+		r.renderTable(b, n, classes, segs)
+	} else {
+		r.renderStrip(b, n, classes, segs)
+	}
+	//nolint:errcheck // by design
+	io.WriteString(r.w, b.String())
+}
+
+// numSegments returns the number of segments shared by the classes.
+// It is limited by the output width and the finest chunk resolution.
+//
+//nolint:whitespace // by design
+func (r *renderer) numSegments(
+	sm *speedmapv1.Speedmap,
+	classes []string,
+) int {
+	n := 0
+	for _, c := range classes {
+		n = max(n, len(sm.GetData()[c].GetChunkSpeeds()))
+	}
+	return min(r.width, n)
+}
+
+// segmentPct returns the track position (0-1) of the center of segment i
+func segmentPct(i, n int) float32 {
+	return (float32(i) + 0.5) / float32(n)
+}
+
+// segments maps the chunks of the class to n segments.
+// Segment i covers the chunks overlapping the track range [i/n, (i+1)/n).
+//
+//nolint:whitespace // by design
+func (r *renderer) segments(
+	sm *speedmapv1.Speedmap,
+	class string,
+	n int,
+) []segment {
+	speeds := sm.GetData()[class].GetChunkSpeeds()
+	ref := r.ref[class]
+	ret := make([]segment, n)
+	if len(speeds) == 0 {
+		return ret
+	}
+	top := slices.Max(speeds)
+	for i := range ret {
+		from := i * len(speeds) / n
+		to := min(max((i+1)*len(speeds)/n, from+1), len(speeds))
+		s := &ret[i]
+		s.ratio = 1
+		for j := from; j < to; j++ {
+			if speeds[j] <= 0 {
+				continue // no data
+			}
+			if !s.valid || speeds[j] < s.speed {
+				s.speed = speeds[j]
+			}
+			s.valid = true
+			if j < len(ref) && ref[j] > 0 {
+				s.ratio = min(s.ratio, speeds[j]/ref[j])
+			}
+		}
+		if s.valid && top > 0 {
+			s.frac = s.speed / top
+		}
+	}
+	return ret
+}
+
+func (r *renderer) slow(s *segment) bool {
+	return s.valid && s.ratio < r.threshold
+}
+
+// renderStrip shows one line per class, one character per segment
+//
+//nolint:whitespace // by design
+func (r *renderer) renderStrip(
+	b *strings.Builder,
+	n int,
+	classes []string,
+	segs map[string][]segment,
+) {
+	for _, c := range classes {
+		fmt.Fprintf(b, "%-12.12s |", c)
+		for i := range segs[c] {
+			s := &segs[c][i]
+			ch := ' '
+			if s.valid {
+				ch = blocks[min(int(s.frac*float64(len(blocks))), len(blocks)-1)]
+			}
+			switch {
+			case r.slow(s) && r.color:
+				b.WriteString(ansiSlow + string(ch) + ansiReset)
+			case r.slow(s):
+				b.WriteRune('!')
+			default:
+				b.WriteRune(ch)
+			}
+		}
+		b.WriteString("|\n")
+	}
+	if r.pit == nil || len(classes) == 0 {
+		return
+	}
+	fmt.Fprintf(b, "%-12s |", "pit lane")
+	for i := range n {
+		if !r.pit.contains(segmentPct(i, n)) {
+			b.WriteRune(' ')
+		} else if r.color {
+			b.WriteString(ansiPit + "P" + ansiReset)
+		} else {
+			b.WriteRune('P')
+		}
+	}
+	b.WriteString("|\n")
+}
+
+// renderTable shows one row per segment with the speed (km/h) of each class.
+// Slow segments are marked with *.
+//
+//nolint:whitespace // by design
+func (r *renderer) renderTable(
+	b *strings.Builder,
+	n int,
+	classes []string,
+	segs map[string][]segment,
+) {
+	if len(classes) == 0 {
+		return
+	}
+	fmt.Fprintf(b, "%5s %3s", "POS", "PIT")
+	for _, c := range classes {
+		fmt.Fprintf(b, " %10.10s", c)
+	}
+	b.WriteString("\n")
+	for i := range n {
+		pct := segmentPct(i, n)
+		pit := ""
+		if r.pit.contains(pct) {
+			pit = "P"
+		}
+		fmt.Fprintf(b, "%4.0f%% %3s", pct*100, pit)
+		for _, c := range classes {
+			if !segs[c][i].valid {
+				fmt.Fprintf(b, " %10s", "")
+				continue
+			}
+			s := &segs[c][i]
+			cell := fmt.Sprintf(" %9.1f", s.speed*3.6)
+			switch {
+			case r.slow(s) && r.color:
+				b.WriteString(ansiSlow + cell + "*" + ansiReset)
+			case r.slow(s):
+				b.WriteString(cell + "*")
+			default:
+				b.WriteString(cell + " ")
+			}
+		}
+		b.WriteString("\n")
+	}
+}
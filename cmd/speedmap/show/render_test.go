@@ -0,0 +1,151 @@
+package show
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	speedmapv1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/speedmap/v1"
+)
+
+//nolint:funlen // table
+func Test_renderTable(t *testing.T) {
+	sm := &speedmapv1.Speedmap{
+		ChunkSize:   500,
+		TrackLength: 2000,
+		SessionTime: 90.5,
+		Data: map[string]*speedmapv1.ClassSpeedmapData{
+			"A": {ChunkSpeeds: []float64{20, 5}},
+			"B": {ChunkSpeeds: []float64{10, 10, 10, 0}},
+		},
+	}
+	tests := []struct {
+		name  string
+		width int
+		want  string
+	}{
+		{
+			// the first class has the coarser chunks
+			name:  "common grid",
+			width: 100,
+			want: "Session time 1m30.5s  Track 2000m  Chunk size 500m\n" +
+				"  POS PIT          A          B\n" +
+				"  12%          72.0       36.0 \n" +
+				"  38%          72.0       36.0 \n" +
+				"  62%          18.0       36.0 \n" +
+				"  88%   P      18.0            \n",
+		},
+		{
+			name:  "limited width",
+			width: 2,
+			want: "Session time 1m30.5s  Track 2000m  Chunk size 500m\n" +
+				"  POS PIT          A          B\n" +
+				"  25%          72.0       36.0 \n" +
+				"  75%   P      18.0       36.0 \n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := &strings.Builder{}
+			r := &renderer{
+				w:         b,
+				table:     true,
+				width:     tt.width,
+				threshold: 0.7,
+				pit:       &pitLane{entry: 0.7, exit: 0.9},
+				ref:       reference{},
+			}
+			r.render(sm)
+			if got := b.String(); got != tt.want {
+				t.Errorf("render() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+// verifies the marking of chunks below threshold times the reference speed
+//
+//nolint:funlen // table
+func Test_renderSlow(t *testing.T) {
+	earlier := &speedmapv1.Speedmap{
+		Data: map[string]*speedmapv1.ClassSpeedmapData{
+			"A": {ChunkSpeeds: []float64{20, 20}},
+			"B": {ChunkSpeeds: []float64{10, 10, 10, 10}},
+		},
+	}
+	sm := &speedmapv1.Speedmap{
+		ChunkSize:   500,
+		TrackLength: 2000,
+		SessionTime: 90.5,
+		Data: map[string]*speedmapv1.ClassSpeedmapData{
+			"A": {ChunkSpeeds: []float64{20, 5}},
+			// 7 is exactly at the threshold, so it is not slow
+			"B": {ChunkSpeeds: []float64{10, 7, 6, 0}},
+		},
+	}
+	tests := []struct {
+		name  string
+		table bool
+		want  string
+	}{
+		{
+			name:  "table",
+			table: true,
+			want: "Session time 1m30.5s  Track 2000m  Chunk size 500m\n" +
+				"  POS PIT          A          B\n" +
+				"  12%          72.0       36.0 \n" +
+				"  38%          72.0       25.2 \n" +
+				"  62%          18.0*      21.6*\n" +
+				"  88%   P      18.0*           \n",
+		},
+		{
+			name: "strip",
+			want: "Session time 1m30.5s  Track 2000m  Chunk size 500m\n" +
+				"A            |██!!|\n" +
+				"B            |█▆! |\n" +
+				"pit lane     |   P|\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := &strings.Builder{}
+			r := &renderer{
+				w:         b,
+				table:     tt.table,
+				width:     100,
+				threshold: 0.7,
+				color:     false,
+				pit:       &pitLane{entry: 0.7, exit: 0.9},
+				ref:       reference{},
+			}
+			r.ref.update(earlier)
+			r.render(sm)
+			if got := b.String(); got != tt.want {
+				t.Errorf("render() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_referenceUpdate(t *testing.T) {
+	ref := reference{}
+	for _, speeds := range [][]float64{{10, 20, 0}, {15, 5, 0}} {
+		ref.update(&speedmapv1.Speedmap{
+			Data: map[string]*speedmapv1.ClassSpeedmapData{
+				"A": {ChunkSpeeds: speeds},
+			},
+		})
+	}
+	if want := []float64{15, 20, 0}; !slices.Equal(ref["A"], want) {
+		t.Errorf("reference = %v, want %v", ref["A"], want)
+	}
+	// a different chunk size starts a new reference
+	ref.update(&speedmapv1.Speedmap{
+		Data: map[string]*speedmapv1.ClassSpeedmapData{
+			"A": {ChunkSpeeds: []float64{12, 8}},
+		},
+	})
+	if want := []float64{12, 8}; !slices.Equal(ref["A"], want) {
+		t.Errorf("reference = %v, want %v", ref["A"], want)
+	}
+}
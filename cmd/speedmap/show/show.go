@@ -0,0 +1,227 @@
+package show
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"time"
+
+	"buf.build/gen/go/mpapenbr/iracelog/grpc/go/iracelog/event/v1/eventv1grpc"
+	livedatav1grpc "buf.build/gen/go/mpapenbr/iracelog/grpc/go/iracelog/livedata/v1/livedatav1grpc"
+	"buf.build/gen/go/mpapenbr/iracelog/grpc/go/iracelog/racestate/v1/racestatev1grpc"
+	commonv1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/common/v1"
+	eventv1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/event/v1"
+	livedatav1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/livedata/v1"
+	racestatev1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/racestate/v1"
+	speedmapv1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/speedmap/v1"
+	"github.com/spf13/cobra"
+	"google.golang.org/grpc"
+
+	"github.com/mpapenbr/iracelog-cli/config"
+	"github.com/mpapenbr/iracelog-cli/log"
+	"github.com/mpapenbr/iracelog-cli/util"
+	"github.com/mpapenbr/iracelog-cli/util/stream"
+)
+
+var (
+	live        bool
+	sessionTime time.Duration
+	sessionNum  int
+	window      time.Duration
+	view        string
+	width       int
+	threshold   float64
+	carClasses  []string
+	noColor     bool
+)
+
+func NewSpeedmapShowCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "show",
+		Short: "shows the speedmap of an event",
+		Long: `Shows the average speed per track chunk for each car class.
+
+A chunk is marked as slow if its speed is below the threshold of the
+highest speed seen for this chunk (for example yellow flag zones).
+With --live the reference speeds are collected while receiving the data,
+for stored events the speedmaps of --window before --session-time are used.
+
+Views:
+  strip   one line per class, one character per track segment (default)
+  table   one row per track segment with the speed (km/h) per class`,
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			if view != "strip" && view != "table" {
+				return fmt.Errorf("unsupported view: %s", view)
+			}
+			return nil
+		},
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			showSpeedmap(cmd.Context(), args[0])
+		},
+	}
+	cmd.Flags().BoolVar(&live, "live", false,
+		"show the live speedmap of a running event")
+	cmd.Flags().DurationVar(&sessionTime, "session-time", 0,
+		"session time of the stored speedmap (for example: 1h10m)")
+	cmd.Flags().IntVar(&sessionNum, "session-num", -1,
+		"session num of --session-time (default: session time of the event)")
+	cmd.Flags().DurationVar(&window, "window", 10*time.Minute,
+		"history before --session-time used as reference speeds")
+	cmd.Flags().StringVar(&view, "view", "strip",
+		"output view (strip, table)")
+	cmd.Flags().IntVar(&width, "width", 100,
+		"number of track segments")
+	cmd.Flags().Float64Var(&threshold, "threshold", 0.7,
+		"chunks below this fraction of the reference speed are slow")
+	cmd.Flags().StringSliceVar(&carClasses, "carclass", []string{},
+		"show only these car classes")
+	cmd.Flags().BoolVar(&noColor, "no-color", false,
+		"mark slow chunks without colors")
+	cmd.MarkFlagsMutuallyExclusive("live", "session-time")
+	cmd.MarkFlagsOneRequired("live", "session-time")
+	return cmd
+}
+
+func showSpeedmap(ctx context.Context, eventArg string) {
+	conn, err := util.ConnectGrpc(config.DefaultCliArgs())
+	if err != nil {
+		log.Error("did not connect", log.ErrorField(err))
+		return
+	}
+	defer conn.Close()
+
+	ctx, cancel := signal.NotifyContext(ctx, os.Interrupt)
+	defer cancel()
+
+	sel := util.ResolveEvent(eventArg)
+	r := &renderer{
+		w:         os.Stdout,
+		table:     view == "table",
+		width:     max(width, 1),
+		threshold: threshold,
+		color:     !noColor,
+		classes:   carClasses,
+		pit:       resolvePitLane(ctx, conn, sel),
+		ref:       reference{},
+	}
+	if live {
+		err = liveSpeedmap(ctx, conn, sel, r)
+	} else {
+		err = storedSpeedmap(ctx, conn, sel, r)
+	}
+	if err != nil {
+		log.Error("could not show speedmap", log.ErrorField(err))
+	}
+}
+
+// resolvePitLane returns the pit lane of the event track (nil if unknown)
+//
+//nolint:whitespace // by design
+func resolvePitLane(
+	ctx context.Context,
+	conn *grpc.ClientConn,
+	sel *commonv1.EventSelector,
+) *pitLane {
+	c := eventv1grpc.NewEventServiceClient(conn)
+	resp, err := c.GetEvent(ctx, &eventv1.GetEventRequest{EventSelector: sel})
+	if err != nil {
+		log.Warn("could not get event", log.ErrorField(err))
+		return nil
+	}
+	p := resp.GetTrack().GetPitInfo()
+	if p == nil || p.GetEntry() == p.GetExit() {
+		return nil
+	}
+	return &pitLane{entry: p.GetEntry(), exit: p.GetExit()}
+}
+
+// liveSpeedmap renders each received speedmap
+//
+//nolint:whitespace // by design
+func liveSpeedmap(
+	ctx context.Context,
+	conn *grpc.ClientConn,
+	sel *commonv1.EventSelector,
+	r *renderer,
+) error {
+	c := livedatav1grpc.NewLiveDataServiceClient(conn)
+	return stream.Subscribe(ctx, "speedmap",
+		func(ctx context.Context, _ bool) (
+			grpc.ServerStreamingClient[livedatav1.LiveSpeedmapResponse], error,
+		) {
+			return c.LiveSpeedmap(ctx, &livedatav1.LiveSpeedmapRequest{Event: sel})
+		},
+		func(resp *livedatav1.LiveSpeedmapResponse) {
+			r.ref.update(resp.GetSpeedmap())
+			r.render(resp.GetSpeedmap())
+		},
+		stream.WithCliArgs(config.DefaultCliArgs()))
+}
+
+// storedSpeedmap renders the last speedmap at or before --session-time.
+// The speedmaps of the window before are used as reference.
+//
+//nolint:whitespace // by design
+func storedSpeedmap(
+	ctx context.Context,
+	conn *grpc.ClientConn,
+	sel *commonv1.EventSelector,
+	r *renderer,
+) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel() // stops the stream once the session time is reached
+	start, err := util.ResolveStartSelector2(startParam{
+		sessionTime: max(sessionTime-window, 0),
+		sessionNum:  sessionNum,
+	})
+	if err != nil {
+		return err
+	}
+	c := racestatev1grpc.NewRaceStateServiceClient(conn)
+	s, err := c.GetSpeedmapStream(ctx, &racestatev1.GetSpeedmapStreamRequest{
+		Event: sel,
+		Start: start,
+	})
+	if err != nil {
+		return err
+	}
+	var last *speedmapv1.Speedmap
+	for {
+		resp, err := s.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		sm := resp.GetSpeedmap().GetSpeedmap()
+		if float64(sm.GetSessionTime()) > sessionTime.Seconds() {
+			break
+		}
+		if last != nil && sm.GetSessionTime() < last.GetSessionTime() {
+			break // the next session started
+		}
+		r.ref.update(sm)
+		last = sm
+	}
+	if last == nil {
+		return fmt.Errorf("no speedmap found before session time %s", sessionTime)
+	}
+	r.render(last)
+	return nil
+}
+
+// startParam selects the stored data by session time (within sessionNum)
+type startParam struct {
+	sessionTime time.Duration
+	sessionNum  int
+}
+
+func (p startParam) SessionTime() time.Duration { return p.sessionTime }
+func (p startParam) RecordStamp() string        { return "" }
+func (p startParam) SessionNum() int            { return p.sessionNum }
+func (p startParam) ID() int                    { return -1 }
@@ -0,0 +1,20 @@
+package speedmap
+
+import (
+	"github.com/spf13/cobra"
+
+	"github.com/mpapenbr/iracelog-cli/cmd/speedmap/show"
+)
+
+func NewSpeedmapCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "speedmap",
+		Short: "Commands regarding speedmaps. ",
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			return nil
+		},
+	}
+	cmd.AddCommand(show.NewSpeedmapShowCmd())
+
+	return cmd
+}
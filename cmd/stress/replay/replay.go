@@ -35,12 +35,15 @@ func NewStressReplayCmd() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "replay",
 		Short: "simulate a set of data providers by replaying events",
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			return utilReplay.ValidateSpeed(cfg.Speed)
+		},
 		Run: func(cmd *cobra.Command, args []string) {
 			replay(cmd.Context())
 		},
 	}
-	cmd.PersistentFlags().IntVar(&cfg.Speed, "speed", 1,
-		"Recording speed (0 means: go as fast as possible)")
+	cmd.PersistentFlags().Float64Var(&cfg.Speed, "speed", 1.0,
+		"Replay speed multiplier, e.g. 0.5 or 1.5 (0 means: go as fast as possible)")
 	cmd.PersistentFlags().StringVar(&cfg.SourceAddr,
 		"source-addr",
 		"",
@@ -50,6 +53,15 @@ func NewStressReplayCmd() *cobra.Command {
 		false,
 		"connect gRPC address without TLS (development only)")
 
+	cmd.Flags().StringVar(&cfg.SourceMode,
+		"source-mode", cfg.SourceMode,
+		"how data is loaded from source (stream, page)")
+	cmd.Flags().StringVar(&cfg.ChaosSpec,
+		"chaos", cfg.ChaosSpec,
+		"inject faults, e.g. \"drop=0.01,disconnect=0.001\" (see event replay)")
+	cmd.Flags().Int64Var(&cfg.ChaosSeed,
+		"chaos-seed", cfg.ChaosSeed,
+		"seed for fault injection, incremented per worker (0: random)")
 	cmd.PersistentFlags().StringVarP(&cfg.Token,
 		"token", "t", "", "authentication token")
 	cmd.Flags().DurationVar(&jobDuration,
@@ -77,8 +89,16 @@ func NewStressReplayCmd() *cobra.Command {
 func replay(ctx context.Context) {
 	logger := log.GetFromContext(ctx)
 	configOptions := config.CollectStandardJobProcessorOptions()
+	summary := utilReplay.NewStatsSummary(config.WorkerThreads, logger.Named("summary"))
 	configOptions = append(configOptions,
 		myStress.WithLogging(logger),
+		myStress.WithFinishHandler(func(jobsDone int, stats *[]myStress.WorkerStats) {
+			logger.Info("finish handler called",
+				log.Int("jobsDone", jobsDone),
+				log.Int("workerUsed", len(*stats)),
+			)
+			summary.Output(len(*stats))
+		}),
 		myStress.WithSourceClientProvider(func() *grpc.ClientConn {
 			c, err := util.NewClient(cfg.SourceAddr, util.WithTLSEnabled(!cfg.SourceInsecure))
 			if err != nil {
@@ -145,25 +165,30 @@ func replay(ctx context.Context) {
 
 			opts = append(opts, utilReplay.WithFastForward(cfg.FastForward))
 
-			if cfg.Speed > 0 {
-				opts = append(opts, utilReplay.WithSpeed(cfg.Speed))
-			}
+			opts = append(opts, utilReplay.WithSpeed(cfg.Speed))
 			opts = append(opts, utilReplay.WithContext(ctx))
 			if cfg.Token != "" {
 				opts = append(opts, utilReplay.WithTokenProvider(func() string {
 					return cfg.Token
 				}))
 			}
-			//nolint:gocritic // by design
-			// if config.WorkerProgressArg != "" {
-			// 	if d, err := time.ParseDuration(config.WorkerProgressArg); err == nil {
-			// 		opts = append(opts, utilReplay.WithStatsCallback(d, func(s *simulate.Stats) {
-			// 			log.Info("stats", log.Any("stats", s))
-			// 		}))
-			// 	}
-			// }
-
-			dp := utilReplay.NewDataProvider(j.SourceClient, e.Id,
+			chaos, err := cfg.NewChaos(int64(j.WorkerID))
+			if err != nil {
+				j.Logger.Error("invalid chaos settings", log.ErrorField(err))
+				return err
+			}
+			if chaos != nil {
+				j.Logger.Info("injecting faults", log.Int64("seed", chaos.Seed))
+				opts = append(opts, utilReplay.WithChaos(chaos))
+			}
+			if config.WorkerProgress > 0 {
+				opts = append(opts, utilReplay.WithStatsCallback(
+					config.WorkerProgress, func(s *utilReplay.Stats) {
+						j.Logger.Info("stats", log.String("progress", s.Progress()))
+					}))
+			}
+
+			dp, err := cfg.NewDataProvider(j.SourceClient, e.Id,
 				func() *providerv1.RegisterEventRequest {
 					recordingMode := func() providerv1.RecordingMode {
 						if cfg.DoNotPersist {
@@ -181,11 +206,20 @@ func replay(ctx context.Context) {
 						RecordingMode: recordingMode(),
 					}
 				})
+			if err != nil {
+				j.Logger.Error("could not create data provider", log.ErrorField(err))
+				return err
+			}
 
 			rt := utilReplay.NewReplayTask(j.TargetClient, dp, opts...)
 			if err := rt.Replay(e.Id); err != nil {
 				j.Logger.Error("error replaying event", log.ErrorField(err))
 			}
+			stats := rt.GetStats()
+			summary.AddStats(j.WorkerID, &stats)
+			if chaos != nil {
+				j.Logger.Info("injected faults", log.Any("chaos", rt.ChaosStats()))
+			}
 
 			return nil
 		}),
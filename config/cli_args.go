@@ -2,6 +2,7 @@ package config
 
 import (
 	"fmt"
+	"time"
 )
 
 type CliArgs struct {
@@ -19,6 +20,11 @@ type CliArgs struct {
 	TLSCert       string   // path to TLS certificate
 	TLSKey        string   // path to TLS key
 	TLSCa         string   // path to TLS CA
+
+	Output         string        // streaming output of live data (ndjson)
+	Fields         []string      // fields of the streaming output (dotted paths)
+	LiveMaxRetries int           // reconnect attempts for live streams (0: unlimited)
+	LiveMaxBackoff time.Duration // max delay between reconnect attempts
 }
 
 func (c *CliArgs) Dump() {
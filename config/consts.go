@@ -2,4 +2,6 @@ package config
 
 const (
 	APITokenHeader = "api-token"
+	// AnnotationStdout marks commands using stdout exclusively (logs go to stderr)
+	AnnotationStdout = "stdout"
 )
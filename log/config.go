@@ -31,6 +31,20 @@ func DefaultProdConfig() *Config {
 	}
 }
 
+// RedirectStdout replaces stdout by stderr in the output paths.
+// Used when stdout is reserved for data.
+func (c *Config) RedirectStdout() {
+	redirect := func(paths []string) {
+		for i, p := range paths {
+			if p == "stdout" {
+				paths[i] = "stderr"
+			}
+		}
+	}
+	redirect(c.Zap.OutputPaths)
+	redirect(c.Zap.ErrorOutputPaths)
+}
+
 func LoadConfig(filename string) (*Config, error) {
 	data, err := os.ReadFile(filename)
 	if err != nil {
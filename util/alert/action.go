@@ -0,0 +1,138 @@
+package alert
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+
+	"github.com/mpapenbr/iracelog-cli/log"
+)
+
+type (
+	Option     func(*Dispatcher)
+	Dispatcher struct {
+		out     io.Writer
+		timeout time.Duration
+		client  *http.Client
+		queue   chan fired
+		wg      sync.WaitGroup
+	}
+)
+
+// WithOutput sets the writer used by print actions (default: stdout)
+func WithOutput(w io.Writer) Option {
+	return func(d *Dispatcher) {
+		d.out = w
+	}
+}
+
+// WithTimeout limits the duration of exec and post actions
+func WithTimeout(t time.Duration) Option {
+	return func(d *Dispatcher) {
+		d.timeout = t
+	}
+}
+
+// NewDispatcher creates a dispatcher which runs the actions one after
+// another in the background. Call Close to wait for pending actions.
+func NewDispatcher(ctx context.Context, opts ...Option) *Dispatcher {
+	d := &Dispatcher{
+		out:     os.Stdout,
+		timeout: 10 * time.Second,
+		client:  &http.Client{},
+		queue:   make(chan fired, 100),
+	}
+	for _, opt := range opts {
+		opt(d)
+	}
+	d.wg.Add(1)
+	go func() {
+		defer d.wg.Done()
+		for f := range d.queue {
+			if err := d.run(ctx, f.rule, f.alert); err != nil {
+				log.Warn("alert action failed",
+					log.String("rule", f.rule.Name),
+					log.String("action", string(f.rule.Action.Type)),
+					log.ErrorField(err))
+			}
+		}
+	}()
+	return d
+}
+
+// Dispatch queues the action of the rule.
+// The alert is dropped if too many actions are pending.
+func (d *Dispatcher) Dispatch(rule *Rule, a *Alert) {
+	select {
+	case d.queue <- fired{rule: rule, alert: a}:
+	default:
+		log.Warn("too many pending alerts, dropping alert",
+			log.String("rule", rule.Name),
+			log.String("msg", a.Message))
+	}
+}
+
+// Close waits until the pending actions are done
+func (d *Dispatcher) Close() {
+	close(d.queue)
+	d.wg.Wait()
+}
+
+func (d *Dispatcher) run(ctx context.Context, rule *Rule, a *Alert) error {
+	log.Debug("alert", log.String("rule", rule.Name), log.String("msg", a.Message))
+	if rule.Action.Type == ActionPrint {
+		_, err := fmt.Fprintf(d.out, "%s [%s] %s\n",
+			a.Timestamp.Local().Format(time.TimeOnly), a.Rule, a.Message)
+		return err
+	}
+	data, err := json.Marshal(a)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, d.timeout)
+	defer cancel()
+	if rule.Action.Type == ActionExec {
+		return d.exec(ctx, rule.Action.Command, data)
+	}
+	return d.post(ctx, &rule.Action, data)
+}
+
+// exec runs the command with the alert as JSON on stdin
+func (d *Dispatcher) exec(ctx context.Context, command []string, data []byte) error {
+	//nolint:gosec // command is configured by the user
+	cmd := exec.CommandContext(ctx, command[0], command[1:]...)
+	cmd.Stdin = bytes.NewReader(data)
+	cmd.Stdout = d.out
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+func (d *Dispatcher) post(ctx context.Context, action *Action, data []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, action.URL,
+		bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range action.Headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	//nolint:errcheck // by design
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,288 @@
+package alert
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	livedatav1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/livedata/v1"
+	racestatev1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/racestate/v1"
+)
+
+// Alert is the data passed to the actions of a fired rule
+type Alert struct {
+	Rule        string    `json:"rule"`
+	Type        RuleType  `json:"type"`
+	Timestamp   time.Time `json:"timestamp"`
+	SessionTime float32   `json:"sessionTime"`
+	CarNum      string    `json:"carNum,omitempty"`
+	OtherCarNum string    `json:"otherCarNum,omitempty"`
+	Driver      string    `json:"driver,omitempty"`
+	PrevDriver  string    `json:"prevDriver,omitempty"`
+	Gap         float32   `json:"gap,omitempty"`
+	Flag        string    `json:"flag,omitempty"`
+	PrevFlag    string    `json:"prevFlag,omitempty"`
+	Message     string    `json:"message"`
+}
+
+type (
+	FireFunc func(rule *Rule, a *Alert)
+	// activeKey identifies a condition of a rule that is currently met.
+	// Such rules fire again only after the condition was cleared.
+	activeKey struct {
+		rule    *Rule
+		subject string
+	}
+)
+
+// Engine evaluates the rules on the live data
+type Engine struct {
+	mu          sync.Mutex
+	rules       []*Rule
+	fire        FireFunc
+	carNums     map[int32]string // key: carIdx
+	drivers     map[int32]string // current driver by carIdx
+	states      map[int32]racestatev1.CarState
+	moving      map[int32]bool // cars that moved since entering the track
+	flag        string
+	sessionTime float32
+	active      map[activeKey]bool
+	pending     []fired
+}
+
+type fired struct {
+	rule  *Rule
+	alert *Alert
+}
+
+func NewEngine(rules []*Rule, fire FireFunc) *Engine {
+	return &Engine{
+		rules:   rules,
+		fire:    fire,
+		carNums: make(map[int32]string),
+		drivers: make(map[int32]string),
+		states:  make(map[int32]racestatev1.CarState),
+		moving:  make(map[int32]bool),
+		active:  make(map[activeKey]bool),
+	}
+}
+
+// ApplyDriverData updates the car numbers and checks driver changes
+func (e *Engine) ApplyDriverData(resp *livedatav1.LiveDriverDataResponse) {
+	e.evaluate(func() {
+		ts := resp.GetTimestamp().AsTime()
+		for _, entry := range resp.GetEntries() {
+			//nolint:gosec // carIdx is small
+			e.carNums[int32(entry.GetCar().GetCarIdx())] = entry.GetCar().GetCarNumber()
+		}
+		for idx, name := range resp.GetCurrentDrivers() {
+			carIdx := int32(idx) //nolint:gosec // carIdx is small
+			prev, ok := e.drivers[carIdx]
+			e.drivers[carIdx] = name
+			if !ok || prev == name || name == "" {
+				continue
+			}
+			for _, r := range e.rulesFor(RuleDriverChange, e.carNums[carIdx]) {
+				e.add(r, &Alert{
+					Timestamp:  ts,
+					CarNum:     e.carNums[carIdx],
+					Driver:     name,
+					PrevDriver: prev,
+					Message: fmt.Sprintf("driver change #%s: %s -> %s",
+						e.carNums[carIdx], prev, name),
+				})
+			}
+		}
+	})
+}
+
+// ApplyState checks the rules on the race state
+func (e *Engine) ApplyState(resp *livedatav1.LiveRaceStateResponse) {
+	e.evaluate(func() {
+		ts := resp.GetTimestamp().AsTime()
+		e.sessionTime = resp.GetSession().GetSessionTime()
+		e.checkFlag(ts, resp.GetSession().GetFlagState())
+		cars := make(map[string]*racestatev1.Car, len(resp.GetCars()))
+		for _, c := range resp.GetCars() {
+			num, ok := e.carNums[c.GetCarIdx()]
+			if !ok {
+				continue // no driver data yet
+			}
+			cars[num] = c
+			e.checkPit(ts, num, c)
+			e.checkStopped(ts, num, c)
+		}
+		e.checkGaps(ts, cars)
+	})
+}
+
+// evaluate runs f with the lock held and fires the collected alerts
+func (e *Engine) evaluate(f func()) {
+	e.mu.Lock()
+	f()
+	pending := e.pending
+	e.pending = nil
+	e.mu.Unlock()
+	for _, p := range pending {
+		e.fire(p.rule, p.alert)
+	}
+}
+
+func (e *Engine) add(r *Rule, a *Alert) {
+	a.Rule = r.Name
+	a.Type = r.Type
+	a.SessionTime = e.sessionTime
+	if a.Driver == "" && a.CarNum != "" {
+		a.Driver = e.driverOf(a.CarNum)
+	}
+	e.pending = append(e.pending, fired{rule: r, alert: a})
+}
+
+// setActive tracks the condition of a rule and reports
+// whether the condition just became true
+func (e *Engine) setActive(r *Rule, subject string, cond bool) bool {
+	key := activeKey{rule: r, subject: subject}
+	if !cond {
+		delete(e.active, key)
+		return false
+	}
+	if e.active[key] {
+		return false
+	}
+	e.active[key] = true
+	return true
+}
+
+func (e *Engine) rulesFor(t RuleType, carNum string) []*Rule {
+	ret := []*Rule{}
+	for _, r := range e.rules {
+		if r.Type == t && (len(r.Cars) == 0 || slices.Contains(r.Cars, carNum)) {
+			ret = append(ret, r)
+		}
+	}
+	return ret
+}
+
+func (e *Engine) driverOf(carNum string) string {
+	for idx, num := range e.carNums {
+		if num == carNum {
+			return e.drivers[idx]
+		}
+	}
+	return ""
+}
+
+func (e *Engine) checkFlag(ts time.Time, flag string) {
+	prev := e.flag
+	e.flag = flag
+	if prev == "" || prev == flag {
+		return
+	}
+	for _, r := range e.rulesFor(RuleFlag, "") {
+		if len(r.Flags) > 0 && !slices.ContainsFunc(r.Flags, func(f string) bool {
+			return strings.EqualFold(f, flag)
+		}) {
+			continue
+		}
+		e.add(r, &Alert{
+			Timestamp: ts,
+			Flag:      flag,
+			PrevFlag:  prev,
+			Message:   fmt.Sprintf("flag changed: %s -> %s", prev, flag),
+		})
+	}
+}
+
+func (e *Engine) checkPit(ts time.Time, num string, c *racestatev1.Car) {
+	prev, ok := e.states[c.GetCarIdx()]
+	e.states[c.GetCarIdx()] = c.GetState()
+	if !ok || prev == racestatev1.CarState_CAR_STATE_PIT ||
+		c.GetState() != racestatev1.CarState_CAR_STATE_PIT {
+		return
+	}
+	for _, r := range e.rulesFor(RulePit, num) {
+		e.add(r, &Alert{
+			Timestamp: ts,
+			CarNum:    num,
+			Message:   fmt.Sprintf("#%s entered the pit", num),
+		})
+	}
+}
+
+// checkStopped detects cars with speed 0 while running on track.
+// Only cars that were moving before count as stopped, so the field
+// waiting on the grid doesn't fire the rule.
+func (e *Engine) checkStopped(ts time.Time, num string, c *racestatev1.Car) {
+	running := c.GetState() == racestatev1.CarState_CAR_STATE_RUN
+	if !running {
+		delete(e.moving, c.GetCarIdx())
+	} else if c.GetSpeed() > 0 {
+		e.moving[c.GetCarIdx()] = true
+	}
+	stopped := running && c.GetSpeed() <= 0 && e.moving[c.GetCarIdx()]
+	for _, r := range e.rulesFor(RuleStopped, num) {
+		if e.setActive(r, num, stopped) {
+			e.add(r, &Alert{
+				Timestamp: ts,
+				CarNum:    num,
+				Message:   fmt.Sprintf("#%s stopped on track", num),
+			})
+		}
+	}
+}
+
+// hasTiming reports if the gap data of the car is set.
+// The gap can't be used for this, it is always 0 for the leader.
+func hasTiming(c *racestatev1.Car) bool {
+	return c.GetPos() > 0 && c.GetLap() > 0
+}
+
+// checkGaps checks the gap between two cars or the interval to the car ahead
+func (e *Engine) checkGaps(ts time.Time, cars map[string]*racestatev1.Car) {
+	for _, r := range e.rules {
+		if r.Type != RuleGap {
+			continue
+		}
+		if len(r.Cars) == 2 {
+			a, b := cars[r.Cars[0]], cars[r.Cars[1]]
+			if a == nil || b == nil || !hasTiming(a) || !hasTiming(b) {
+				continue
+			}
+			gap := a.GetGap() - b.GetGap()
+			if gap < 0 {
+				gap = -gap
+			}
+			subject := r.Cars[0] + "/" + r.Cars[1]
+			if e.setActive(r, subject, gap < r.Seconds) {
+				e.add(r, &Alert{
+					Timestamp:   ts,
+					CarNum:      r.Cars[0],
+					OtherCarNum: r.Cars[1],
+					Gap:         gap,
+					Message: fmt.Sprintf("gap #%s - #%s: %.1fs",
+						r.Cars[0], r.Cars[1], gap),
+				})
+			}
+			continue
+		}
+		for _, num := range slices.Sorted(maps.Keys(cars)) {
+			if len(r.Cars) > 0 && !slices.Contains(r.Cars, num) {
+				continue
+			}
+			c := cars[num]
+			interval := c.GetInterval()
+			if e.setActive(r, num, interval > 0 && interval < r.Seconds) {
+				e.add(r, &Alert{
+					Timestamp: ts,
+					CarNum:    num,
+					Gap:       interval,
+					Message: fmt.Sprintf("#%s is %.1fs behind the car ahead",
+						num, interval),
+				})
+			}
+		}
+	}
+}
@@ -0,0 +1,169 @@
+package alert
+
+import (
+	"slices"
+	"testing"
+
+	carv1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/car/v1"
+	livedatav1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/livedata/v1"
+	racestatev1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/racestate/v1"
+)
+
+const (
+	run = racestatev1.CarState_CAR_STATE_RUN
+	pit = racestatev1.CarState_CAR_STATE_PIT
+)
+
+// snapshot is a simplified race state for the test cases
+type snapshot struct {
+	flag string
+	cars []*racestatev1.Car
+}
+
+// car returns a car on its first lap
+func car(idx int32, state racestatev1.CarState, speed, gap float32) *racestatev1.Car {
+	return &racestatev1.Car{
+		CarIdx: idx, State: state, Speed: speed, Gap: gap, Pos: idx + 1, Lap: 1,
+	}
+}
+
+// grid removes the lap of the car, there is no timing data before the start
+func grid(c *racestatev1.Car) *racestatev1.Car {
+	c.Lap = 0
+	return c
+}
+
+func driverData(drivers map[uint32]string) *livedatav1.LiveDriverDataResponse {
+	return &livedatav1.LiveDriverDataResponse{
+		Entries: []*carv1.CarEntry{
+			{Car: &carv1.CarInfo{CarIdx: 0, CarNumber: "1"}},
+			{Car: &carv1.CarInfo{CarIdx: 1, CarNumber: "2"}},
+		},
+		CurrentDrivers: drivers,
+	}
+}
+
+//nolint:funlen // table
+func Test_Engine(t *testing.T) {
+	stopped := &Rule{Name: "stopped", Type: RuleStopped}
+	gap := &Rule{Name: "gap", Type: RuleGap, Cars: []string{"1", "2"}, Seconds: 1}
+	tests := []struct {
+		name  string
+		rules []*Rule
+		data  []snapshot
+		want  []string
+	}{
+		{
+			name:  "stopped on grid",
+			rules: []*Rule{stopped},
+			data: []snapshot{
+				{cars: []*racestatev1.Car{car(0, run, 0, 0), car(1, run, 0, 0)}},
+				{cars: []*racestatev1.Car{car(0, run, 0, 0), car(1, run, 0, 0)}},
+			},
+			want: []string{},
+		},
+		{
+			name:  "stopped after moving",
+			rules: []*Rule{stopped},
+			data: []snapshot{
+				{cars: []*racestatev1.Car{car(0, run, 0, 0), car(1, run, 0, 0)}},
+				{cars: []*racestatev1.Car{car(0, run, 50, 0), car(1, run, 50, 0)}},
+				{cars: []*racestatev1.Car{car(0, run, 0, 0), car(1, run, 50, 0)}},
+				{cars: []*racestatev1.Car{car(0, run, 0, 0), car(1, run, 50, 0)}},
+			},
+			want: []string{"#1 stopped on track"},
+		},
+		{
+			name:  "stopped after pit exit",
+			rules: []*Rule{stopped},
+			data: []snapshot{
+				{cars: []*racestatev1.Car{car(0, run, 50, 0)}},
+				{cars: []*racestatev1.Car{car(0, pit, 0, 0)}},
+				{cars: []*racestatev1.Car{car(0, run, 0, 0)}},
+			},
+			want: []string{},
+		},
+		{
+			name:  "gap unset",
+			rules: []*Rule{gap},
+			data: []snapshot{
+				{cars: []*racestatev1.Car{grid(car(0, run, 0, 0)), grid(car(1, run, 0, 0))}},
+				{cars: []*racestatev1.Car{car(0, run, 50, 0.5), grid(car(1, run, 50, 0))}},
+			},
+			want: []string{},
+		},
+		{
+			name:  "gap to leader",
+			rules: []*Rule{gap},
+			data: []snapshot{
+				{cars: []*racestatev1.Car{car(0, run, 50, 0), car(1, run, 50, 2)}},
+				{cars: []*racestatev1.Car{car(0, run, 50, 0), car(1, run, 50, 0.8)}},
+			},
+			want: []string{"gap #1 - #2: 0.8s"},
+		},
+		{
+			name:  "gap below threshold",
+			rules: []*Rule{gap},
+			data: []snapshot{
+				{cars: []*racestatev1.Car{car(0, run, 50, 10), car(1, run, 50, 12)}},
+				{cars: []*racestatev1.Car{car(0, run, 50, 10), car(1, run, 50, 10.5)}},
+				{cars: []*racestatev1.Car{car(0, run, 50, 10), car(1, run, 50, 10.4)}},
+			},
+			want: []string{"gap #1 - #2: 0.5s"},
+		},
+		{
+			name:  "pit entry",
+			rules: []*Rule{{Name: "pit", Type: RulePit, Cars: []string{"2"}}},
+			data: []snapshot{
+				{cars: []*racestatev1.Car{car(0, run, 50, 0), car(1, run, 50, 0)}},
+				{cars: []*racestatev1.Car{car(0, pit, 0, 0), car(1, pit, 0, 0)}},
+				{cars: []*racestatev1.Car{car(0, pit, 0, 0), car(1, pit, 0, 0)}},
+			},
+			want: []string{"#2 entered the pit"},
+		},
+		{
+			name:  "flag change",
+			rules: []*Rule{{Name: "flag", Type: RuleFlag, Flags: []string{"yellow"}}},
+			data: []snapshot{
+				{flag: "GREEN"},
+				{flag: "YELLOW"},
+				{flag: "GREEN"},
+			},
+			want: []string{"flag changed: GREEN -> YELLOW"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := []string{}
+			e := NewEngine(tt.rules, func(_ *Rule, a *Alert) {
+				got = append(got, a.Message)
+			})
+			e.ApplyDriverData(driverData(nil))
+			for _, s := range tt.data {
+				e.ApplyState(&livedatav1.LiveRaceStateResponse{
+					Session: &racestatev1.Session{FlagState: s.flag},
+					Cars:    s.cars,
+				})
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("fired = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_EngineDriverChange(t *testing.T) {
+	var got []*Alert
+	e := NewEngine(
+		[]*Rule{{Name: "driver", Type: RuleDriverChange}},
+		func(_ *Rule, a *Alert) { got = append(got, a) })
+	e.ApplyDriverData(driverData(map[uint32]string{0: "Alice", 1: "Bob"}))
+	e.ApplyDriverData(driverData(map[uint32]string{0: "Alice", 1: "Bob"}))
+	e.ApplyDriverData(driverData(map[uint32]string{0: "Carol", 1: "Bob"}))
+	if len(got) != 1 {
+		t.Fatalf("fired %d alerts, want 1", len(got))
+	}
+	if got[0].CarNum != "1" || got[0].Driver != "Carol" || got[0].PrevDriver != "Alice" {
+		t.Errorf("alert = %+v, want #1 Alice -> Carol", got[0])
+	}
+}
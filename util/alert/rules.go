@@ -0,0 +1,110 @@
+// Package alert evaluates rules over the live streams and runs the
+// configured actions when a rule fires.
+package alert
+
+import (
+	"fmt"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+type (
+	RuleType   string
+	ActionType string
+)
+
+const (
+	RulePit          RuleType = "pit"          // car enters the pit
+	RuleGap          RuleType = "gap"          // gap below threshold
+	RuleFlag         RuleType = "flag"         // flag state changes
+	RuleDriverChange RuleType = "driverchange" // driver of a car changes
+	RuleStopped      RuleType = "stopped"      // car stopped on track
+)
+
+const (
+	ActionPrint ActionType = "print" // print a line to stdout
+	ActionExec  ActionType = "exec"  // run a command, the alert is passed on stdin
+	ActionPost  ActionType = "post"  // POST the alert to an URL
+)
+
+type (
+	RuleFile struct {
+		Rules []*Rule `yaml:"rules"`
+	}
+	Rule struct {
+		Name string   `yaml:"name"`
+		Type RuleType `yaml:"type"`
+		// car numbers the rule applies to (empty: all cars).
+		// gap rules with two cars check the gap between these cars,
+		// otherwise the interval to the car ahead.
+		Cars []string `yaml:"cars"`
+		// threshold for gap rules
+		Seconds float32 `yaml:"seconds"`
+		// flag rules fire only for these flags (empty: all changes)
+		Flags  []string `yaml:"flags"`
+		Action Action   `yaml:"action"`
+	}
+	Action struct {
+		Type    ActionType        `yaml:"type"`
+		Command []string          `yaml:"command"` // exec: command and args
+		URL     string            `yaml:"url"`     // post: target URL
+		Headers map[string]string `yaml:"headers"` // post: extra headers
+	}
+)
+
+// LoadRules reads the rules from a yaml file
+func LoadRules(filename string) ([]*Rule, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	var rf RuleFile
+	if err := yaml.Unmarshal(data, &rf); err != nil {
+		return nil, err
+	}
+	if len(rf.Rules) == 0 {
+		return nil, fmt.Errorf("no rules found in %s", filename)
+	}
+	for i, r := range rf.Rules {
+		if r.Name == "" {
+			r.Name = fmt.Sprintf("%s-%d", r.Type, i+1)
+		}
+		if err := r.validate(); err != nil {
+			return nil, fmt.Errorf("rule %s: %w", r.Name, err)
+		}
+	}
+	return rf.Rules, nil
+}
+
+//nolint:cyclop // by design
+func (r *Rule) validate() error {
+	switch r.Type {
+	case RulePit, RuleFlag, RuleDriverChange, RuleStopped:
+	case RuleGap:
+		if r.Seconds <= 0 {
+			return fmt.Errorf("gap rule requires seconds > 0")
+		}
+		if len(r.Cars) > 2 {
+			return fmt.Errorf("gap rule accepts at most two cars")
+		}
+	default:
+		return fmt.Errorf("unknown rule type: %q", r.Type)
+	}
+	switch r.Action.Type {
+	case "":
+		r.Action.Type = ActionPrint
+	case ActionPrint:
+	case ActionExec:
+		if len(r.Action.Command) == 0 {
+			return fmt.Errorf("exec action requires a command")
+		}
+	case ActionPost:
+		if r.Action.URL == "" {
+			return fmt.Errorf("post action requires an url")
+		}
+	default:
+		return fmt.Errorf("unknown action type: %q", r.Action.Type)
+	}
+	return nil
+}
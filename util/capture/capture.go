@@ -0,0 +1,136 @@
+// Package capture reads and writes capture files of live streams.
+//
+// A capture file is a gzip compressed sequence of records. Each record consists
+// of two length-delimited protobuf messages: the receive time of the message
+// (google.protobuf.Timestamp) followed by the message (google.protobuf.Any).
+package capture
+
+import (
+	"bufio"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protodelim"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// max size of a single message in a capture file
+const maxMessageSize = 64 * 1024 * 1024
+
+var ErrTruncated = errors.New("capture file is truncated")
+
+type Record struct {
+	Received time.Time
+	Msg      proto.Message
+}
+
+// Writer writes records to a capture file. It is safe for concurrent use.
+type Writer struct {
+	mu   sync.Mutex
+	file *os.File
+	gz   *gzip.Writer
+	buf  *bufio.Writer
+}
+
+func Create(filename string) (*Writer, error) {
+	f, err := os.Create(filename)
+	if err != nil {
+		return nil, err
+	}
+	gz := gzip.NewWriter(f)
+	return &Writer{file: f, gz: gz, buf: bufio.NewWriter(gz)}, nil
+}
+
+func (w *Writer) Write(received time.Time, msg proto.Message) error {
+	a, err := anypb.New(msg)
+	if err != nil {
+		return err
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if _, err := protodelim.MarshalTo(w.buf, timestamppb.New(received)); err != nil {
+		return err
+	}
+	_, err = protodelim.MarshalTo(w.buf, a)
+	return err
+}
+
+// Flush writes the buffered records, so they are readable even if the
+// writer is not closed properly.
+func (w *Writer) Flush() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if err := w.buf.Flush(); err != nil {
+		return err
+	}
+	return w.gz.Flush()
+}
+
+func (w *Writer) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return errors.Join(w.buf.Flush(), w.gz.Close(), w.file.Close())
+}
+
+// Reader reads the records of a capture file.
+// The message types have to be registered (by importing their packages).
+type Reader struct {
+	file *os.File
+	gz   *gzip.Reader
+	r    *bufio.Reader
+}
+
+func Open(filename string) (*Reader, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &Reader{file: f, gz: gz, r: bufio.NewReader(gz)}, nil
+}
+
+// Next returns the next record, io.EOF if there are no more records.
+// ErrTruncated is returned if the file ends within a record (for example
+// if the recording was killed).
+func (r *Reader) Next() (*Record, error) {
+	ts := &timestamppb.Timestamp{}
+	if err := r.unmarshal(ts); err != nil {
+		return nil, err
+	}
+	a := &anypb.Any{}
+	if err := r.unmarshal(a); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, ErrTruncated
+		}
+		return nil, err
+	}
+	msg, err := a.UnmarshalNew()
+	if err != nil {
+		return nil, fmt.Errorf("invalid record: %w", err)
+	}
+	return &Record{Received: ts.AsTime(), Msg: msg}, nil
+}
+
+func (r *Reader) unmarshal(msg proto.Message) error {
+	opts := protodelim.UnmarshalOptions{MaxSize: maxMessageSize}
+	err := opts.UnmarshalFrom(r.r, msg)
+	if errors.Is(err, io.ErrUnexpectedEOF) {
+		return ErrTruncated
+	}
+	return err
+}
+
+func (r *Reader) Close() error {
+	return errors.Join(r.gz.Close(), r.file.Close())
+}
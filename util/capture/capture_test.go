@@ -0,0 +1,204 @@
+package capture
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protodelim"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+var testRecords = []Record{
+	{
+		Received: time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+		Msg:      wrapperspb.String("first"),
+	},
+	{
+		Received: time.Date(2024, 5, 1, 12, 0, 1, 500, time.UTC),
+		Msg:      wrapperspb.Int32(42),
+	},
+	{
+		Received: time.Date(2024, 5, 1, 12, 0, 2, 0, time.UTC),
+		Msg:      timestamppb.New(time.Date(2024, 5, 1, 11, 0, 0, 0, time.UTC)),
+	},
+}
+
+// writeCapture writes the records to a new capture file
+func writeCapture(t *testing.T, records []Record) string {
+	t.Helper()
+	filename := filepath.Join(t.TempDir(), "test.capture")
+	w, err := Create(filename)
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	for _, rec := range records {
+		if err := w.Write(rec.Received, rec.Msg); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	return filename
+}
+
+// readCapture reads the records until an error occurs
+func readCapture(t *testing.T, filename string) ([]*Record, error) {
+	t.Helper()
+	r, err := Open(filename)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer r.Close()
+	ret := []*Record{}
+	for {
+		rec, err := r.Next()
+		if err != nil {
+			return ret, err
+		}
+		ret = append(ret, rec)
+	}
+}
+
+func checkRecords(t *testing.T, got []*Record, want []Record) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("got %d records, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if !got[i].Received.Equal(want[i].Received) ||
+			!proto.Equal(got[i].Msg, want[i].Msg) {
+			t.Errorf("record %d = %v %v, want %v %v", i,
+				got[i].Received, got[i].Msg, want[i].Received, want[i].Msg)
+		}
+	}
+}
+
+func Test_roundTrip(t *testing.T) {
+	got, err := readCapture(t, writeCapture(t, testRecords))
+	if !errors.Is(err, io.EOF) {
+		t.Errorf("Next() error = %v, want %v", err, io.EOF)
+	}
+	checkRecords(t, got, testRecords)
+}
+
+// recordSize returns the size of the uncompressed record
+func recordSize(t *testing.T, rec Record) int {
+	t.Helper()
+	a, err := anypb.New(rec.Msg)
+	if err != nil {
+		t.Fatalf("anypb.New() error = %v", err)
+	}
+	return len(protodelimBytes(t, timestamppb.New(rec.Received))) +
+		len(protodelimBytes(t, a))
+}
+
+//nolint:funlen // table
+func Test_truncated(t *testing.T) {
+	first := recordSize(t, testRecords[0])
+	tsSize := len(protodelimBytes(t, timestamppb.New(testRecords[1].Received)))
+	tests := []struct {
+		name    string
+		size    int // size of the uncompressed data
+		want    int // number of records read
+		wantErr error
+	}{
+		{name: "empty", size: 0, want: 0, wantErr: io.EOF},
+		{name: "record boundary", size: first, want: 1, wantErr: io.EOF},
+		{name: "within timestamp", size: first + 2, want: 1, wantErr: ErrTruncated},
+		{
+			name:    "after timestamp",
+			size:    first + tsSize,
+			want:    1,
+			wantErr: ErrTruncated,
+		},
+		{
+			name:    "within message",
+			size:    first + tsSize + 3,
+			want:    1,
+			wantErr: ErrTruncated,
+		},
+	}
+	data := uncompressed(t, writeCapture(t, testRecords))
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filename := filepath.Join(t.TempDir(), "truncated.capture")
+			buf := bytes.Buffer{}
+			gz := gzip.NewWriter(&buf)
+			if _, err := gz.Write(data[:tt.size]); err != nil {
+				t.Fatalf("Write() error = %v", err)
+			}
+			if err := gz.Close(); err != nil {
+				t.Fatalf("Close() error = %v", err)
+			}
+			if err := os.WriteFile(filename, buf.Bytes(), 0o600); err != nil {
+				t.Fatalf("WriteFile() error = %v", err)
+			}
+			got, err := readCapture(t, filename)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Next() error = %v, want %v", err, tt.wantErr)
+			}
+			checkRecords(t, got, testRecords[:tt.want])
+		})
+	}
+}
+
+// verifies that flushed records are readable if the writer wasn't closed
+func Test_flushedNotClosed(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "test.capture")
+	w, err := Create(filename)
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	defer w.Close()
+	for _, rec := range testRecords[:2] {
+		if err := w.Write(rec.Received, rec.Msg); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatalf("Flush() error = %v", err)
+	}
+	got, err := readCapture(t, filename)
+	if !errors.Is(err, ErrTruncated) {
+		t.Errorf("Next() error = %v, want %v", err, ErrTruncated)
+	}
+	checkRecords(t, got, testRecords[:2])
+}
+
+func protodelimBytes(t *testing.T, msg proto.Message) []byte {
+	t.Helper()
+	buf := bytes.Buffer{}
+	if _, err := protodelim.MarshalTo(&buf, msg); err != nil {
+		t.Fatalf("MarshalTo() error = %v", err)
+	}
+	return buf.Bytes()
+}
+
+// uncompressed returns the uncompressed content of the capture file
+func uncompressed(t *testing.T, filename string) []byte {
+	t.Helper()
+	f, err := os.Open(filename)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatalf("NewReader() error = %v", err)
+	}
+	data, err := io.ReadAll(gz)
+	if err != nil {
+		t.Fatalf("ReadAll() error = %v", err)
+	}
+	return data
+}
@@ -44,12 +44,13 @@ func NewCarOutput(opts ...Option) Output {
 	for _, opt := range opts {
 		opt(cfg)
 	}
-	//nolint:exhaustive // by design
 	switch cfg.format {
 	case output.FormatCSV:
 		return &carOutput{outputter: newCarCsv(cfg)}
 	case output.FormatJSON:
 		return &carOutput{outputter: &carJSON{config: cfg}}
+	case output.FormatText:
+		return &carOutput{outputter: newCarText(cfg)}
 	}
 	return &carOutput{outputter: &carEmpty{config: cfg}}
 }
@@ -0,0 +1,42 @@
+package car
+
+import (
+	racestatev1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/racestate/v1"
+
+	"github.com/mpapenbr/iracelog-cli/util/output"
+)
+
+type (
+	// carText renders the attributes as aligned columns
+	carText struct {
+		writer *output.RowWriter[CarAttr]
+	}
+	carRow struct {
+		session *racestatev1.Session
+		car     *racestatev1.Car
+	}
+)
+
+func newCarText(cfg *OutputConfig) *carText {
+	return &carText{writer: output.NewRowWriter(output.FormatText, cfg.attrs, cfg.writer)}
+}
+
+func (c *carText) header() {
+	c.writer.Header()
+}
+
+func (c *carText) line(session *racestatev1.Session, car *racestatev1.Car) {
+	c.writer.Line(&carRow{session: session, car: car})
+}
+
+func (c *carText) flush() {
+	c.writer.Flush()
+}
+
+func (r *carRow) Text(attr CarAttr) string {
+	return getCarAttrValue(r.session, r.car, attr)
+}
+
+func (r *carRow) Value(attr CarAttr) any {
+	return r.Text(attr)
+}
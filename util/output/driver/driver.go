@@ -0,0 +1,103 @@
+package driver
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	carv1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/car/v1"
+
+	"github.com/mpapenbr/iracelog-cli/util/output"
+)
+
+type (
+	Option       func(*OutputConfig)
+	OutputConfig struct {
+		format output.Format
+		attrs  []DriverAttr
+		writer io.Writer
+	}
+	Output interface {
+		Header()
+		// Line outputs a car entry. driver is the name of the current driver
+		Line(entry *carv1.CarEntry, driver string)
+		Flush()
+	}
+
+	driverOutput struct {
+		*output.RowWriter[DriverAttr]
+	}
+	driverRow struct {
+		entry  *carv1.CarEntry
+		driver string
+	}
+)
+
+func NewDriverOutput(opts ...Option) Output {
+	cfg := &OutputConfig{
+		format: output.FormatText,
+		attrs:  []DriverAttr{},
+		writer: os.Stdout,
+	}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	return &driverOutput{output.NewRowWriter(cfg.format, cfg.attrs, cfg.writer)}
+}
+
+func WithFormat(f output.Format) Option {
+	return func(cfg *OutputConfig) {
+		cfg.format = f
+	}
+}
+
+func WithWriter(w io.Writer) Option {
+	return func(cfg *OutputConfig) {
+		cfg.writer = w
+	}
+}
+
+func WithDriverAttrs(attrs []DriverAttr) Option {
+	return func(cfg *OutputConfig) {
+		cfg.attrs = attrs
+	}
+}
+
+func WithAllDriverAttrs() Option {
+	return func(cfg *OutputConfig) {
+		cfg.attrs = SupportedDriverAttrs()
+	}
+}
+
+func (s *driverOutput) Line(entry *carv1.CarEntry, driver string) {
+	s.RowWriter.Line(&driverRow{entry: entry, driver: driver})
+}
+
+//nolint:cyclop // by design
+func (r *driverRow) Text(attr DriverAttr) string {
+	switch attr {
+	case DriverCarIdx:
+		return fmt.Sprintf("%d", r.entry.GetCar().GetCarIdx())
+	case DriverCarNum:
+		return r.entry.GetCar().GetCarNumber()
+	case DriverCarClass:
+		return r.entry.GetCar().GetCarClassName()
+	case DriverCarName:
+		return r.entry.GetCar().GetName()
+	case DriverTeam:
+		return r.entry.GetTeam().GetName()
+	case DriverName:
+		return r.driver
+	case DriverUndefined:
+		return "undefined"
+	default:
+		return "unknown"
+	}
+}
+
+func (r *driverRow) Value(attr DriverAttr) any {
+	if attr == DriverCarIdx {
+		return r.entry.GetCar().GetCarIdx()
+	}
+	return r.Text(attr)
+}
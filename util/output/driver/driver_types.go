@@ -0,0 +1,95 @@
+package driver
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/mpapenbr/iracelog-cli/util/output"
+)
+
+const (
+	DriverUndefined DriverAttr = iota
+	DriverCarIdx
+	DriverCarNum
+	DriverCarClass
+	DriverCarName
+	DriverTeam
+	DriverName
+)
+
+type (
+	DriverAttr int8
+)
+
+func ParseDriverAttr(text string) (DriverAttr, error) {
+	var f DriverAttr
+	err := f.UnmarshalText([]byte(text))
+	return f, err
+}
+
+func SupportedDriverAttrs() []DriverAttr {
+	return []DriverAttr{
+		DriverCarIdx,
+		DriverCarNum,
+		DriverCarClass,
+		DriverCarName,
+		DriverTeam,
+		DriverName,
+	}
+}
+
+//nolint:exhaustive,cyclop // by design
+func (f DriverAttr) String() string {
+	switch f {
+	case DriverCarIdx:
+		return "idx"
+	case DriverCarNum:
+		return "carnum"
+	case DriverCarClass:
+		return "carclass"
+	case DriverCarName:
+		return "car"
+	case DriverTeam:
+		return "team"
+	case DriverName:
+		return "driver"
+	default:
+		return output.Unknown
+	}
+}
+
+func (f DriverAttr) MarshalText() ([]byte, error) {
+	return []byte(f.String()), nil
+}
+
+func (f *DriverAttr) UnmarshalText(text []byte) error {
+	if f == nil {
+		return output.ErrUnmarshalNil
+	}
+	if !f.unmarshalText(text) && !f.unmarshalText(bytes.ToLower(text)) {
+		return fmt.Errorf("unrecognized driver attr: %q", text)
+	}
+	return nil
+}
+
+//nolint:cyclop // by design
+func (f *DriverAttr) unmarshalText(text []byte) bool {
+	switch strings.ToLower(string(text)) {
+	case "idx":
+		*f = DriverCarIdx
+	case "carnum":
+		*f = DriverCarNum
+	case "carclass":
+		*f = DriverCarClass
+	case "car":
+		*f = DriverCarName
+	case "team":
+		*f = DriverTeam
+	case "driver":
+		*f = DriverName
+	default:
+		return false
+	}
+	return true
+}
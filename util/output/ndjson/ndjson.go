@@ -0,0 +1,178 @@
+// Package ndjson writes protobuf messages as newline delimited JSON.
+// Field names are the proto field names (snake_case) and unpopulated
+// fields are included, so the output has the same shape for every message.
+package ndjson
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/spf13/cobra"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/mpapenbr/iracelog-cli/config"
+	"github.com/mpapenbr/iracelog-cli/log"
+)
+
+const OutputNdjson = "ndjson"
+
+type (
+	Option func(*Writer)
+	Writer struct {
+		mu     sync.Mutex
+		w      io.Writer
+		fields [][]string
+		mo     protojson.MarshalOptions
+	}
+)
+
+// WithFields restricts the output to the given fields.
+// Nested fields are separated by dots (for example session.flag_state).
+// Fields of repeated messages apply to each element (for example cars.pos).
+func WithFields(fields []string) Option {
+	return func(w *Writer) {
+		for _, f := range fields {
+			if f != "" {
+				w.fields = append(w.fields, strings.Split(f, "."))
+			}
+		}
+	}
+}
+
+func WithWriter(out io.Writer) Option {
+	return func(w *Writer) {
+		w.w = out
+	}
+}
+
+func New(opts ...Option) *Writer {
+	ret := &Writer{
+		w:  os.Stdout,
+		mo: protojson.MarshalOptions{UseProtoNames: true, EmitUnpopulated: true},
+	}
+	for _, opt := range opts {
+		opt(ret)
+	}
+	return ret
+}
+
+// AddFlags adds the --output and --fields flags to cmd
+func AddFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&config.DefaultCliArgs().Output, "output", "",
+		"write each received message as one JSON line to stdout (ndjson)")
+	cmd.Flags().StringSliceVar(&config.DefaultCliArgs().Fields, "fields", []string{},
+		"fields to include in ndjson output (dotted paths, e.g. session.flag_state)")
+}
+
+// NewFromCliArgs returns the writer configured by --output and --fields.
+// Returns nil if ndjson output is not requested.
+func NewFromCliArgs(args *config.CliArgs) (*Writer, error) {
+	switch args.Output {
+	case "":
+		return nil, nil
+	case OutputNdjson:
+		return New(WithFields(args.Fields)), nil
+	default:
+		return nil, fmt.Errorf("unsupported output: %s", args.Output)
+	}
+}
+
+// Write writes msg as a single line
+func (w *Writer) Write(msg proto.Message) error {
+	data, err := w.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	_, err = w.w.Write(append(data, '\n'))
+	return err
+}
+
+// Marshal returns the single line JSON of msg (without newline).
+// It can be used to embed the message into other JSON data.
+func (w *Writer) Marshal(msg proto.Message) ([]byte, error) {
+	data, err := w.mo.Marshal(msg)
+	if err != nil {
+		return nil, err
+	}
+	buf := bytes.Buffer{}
+	if len(w.fields) == 0 {
+		// protojson output is not stable regarding whitespace
+		if err := json.Compact(&buf, data); err != nil {
+			return nil, err
+		}
+	} else if err := w.project(&buf, data); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (w *Writer) project(buf *bytes.Buffer, data []byte) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return err
+	}
+	enc := json.NewEncoder(buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(project(v, w.fields)); err != nil {
+		return err
+	}
+	buf.Truncate(buf.Len() - 1) // Encode appends a newline
+	return nil
+}
+
+// project keeps the fields of v given by paths.
+// Arrays are projected element by element.
+func project(v any, paths [][]string) any {
+	switch val := v.(type) {
+	case []any:
+		ret := make([]any, len(val))
+		for i, item := range val {
+			ret[i] = project(item, paths)
+		}
+		return ret
+	case map[string]any:
+		sub := map[string][][]string{}
+		for _, p := range paths {
+			if _, ok := val[p[0]]; ok {
+				sub[p[0]] = append(sub[p[0]], p[1:])
+			}
+		}
+		ret := make(map[string]any, len(sub))
+		for k, rest := range sub {
+			if containsEmpty(rest) {
+				ret[k] = val[k]
+			} else {
+				ret[k] = project(val[k], rest)
+			}
+		}
+		return ret
+	default:
+		return v
+	}
+}
+
+func containsEmpty(paths [][]string) bool {
+	for _, p := range paths {
+		if len(p) == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Line writes msg, errors are logged
+func (w *Writer) Line(msg proto.Message) {
+	if err := w.Write(msg); err != nil {
+		log.Error("could not write ndjson output", log.ErrorField(err))
+	}
+}
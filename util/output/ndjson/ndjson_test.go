@@ -0,0 +1,113 @@
+package ndjson
+
+import (
+	"bytes"
+	"testing"
+
+	"google.golang.org/protobuf/types/known/structpb"
+
+	"github.com/mpapenbr/iracelog-cli/config"
+)
+
+//nolint:funlen // table
+func Test_WriterFields(t *testing.T) {
+	msg, err := structpb.NewStruct(map[string]any{
+		"session": map[string]any{"flag_state": "GREEN", "session_time": 1.5},
+		"cars": []any{
+			map[string]any{"car_idx": 0, "pos": 2, "laps": []any{"a", "b"}},
+			map[string]any{"car_idx": 1, "pos": 1},
+		},
+		"messages": []any{},
+		"note":     "<b>",
+	})
+	if err != nil {
+		t.Fatalf("NewStruct() error = %v", err)
+	}
+	tests := []struct {
+		name   string
+		fields []string
+		want   string
+	}{
+		{
+			name: "all fields",
+			want: `{"cars":[{"car_idx":0,"laps":["a","b"],"pos":2},` +
+				`{"car_idx":1,"pos":1}],"messages":[],"note":"<b>",` +
+				`"session":{"flag_state":"GREEN","session_time":1.5}}`,
+		},
+		{
+			name:   "top level",
+			fields: []string{"session"},
+			want:   `{"session":{"flag_state":"GREEN","session_time":1.5}}`,
+		},
+		{
+			name:   "nested",
+			fields: []string{"session.flag_state"},
+			want:   `{"session":{"flag_state":"GREEN"}}`,
+		},
+		{
+			name:   "repeated",
+			fields: []string{"cars.pos", "cars.laps"},
+			want:   `{"cars":[{"laps":["a","b"],"pos":2},{"pos":1}]}`,
+		},
+		{
+			name:   "whole field wins over nested",
+			fields: []string{"session.flag_state", "session"},
+			want:   `{"session":{"flag_state":"GREEN","session_time":1.5}}`,
+		},
+		{
+			name:   "empty repeated",
+			fields: []string{"messages.msg"},
+			want:   `{"messages":[]}`,
+		},
+		{
+			name:   "path below scalar",
+			fields: []string{"session.flag_state.value"},
+			want:   `{"session":{"flag_state":"GREEN"}}`,
+		},
+		{
+			name:   "unknown and empty fields",
+			fields: []string{"unknown", "", "session.unknown"},
+			want:   `{"session":{}}`,
+		},
+		{
+			name:   "no html escaping",
+			fields: []string{"note"},
+			want:   `{"note":"<b>"}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buf := bytes.Buffer{}
+			w := New(WithWriter(&buf), WithFields(tt.fields))
+			if err := w.Write(msg); err != nil {
+				t.Fatalf("Write() error = %v", err)
+			}
+			if got := buf.String(); got != tt.want+"\n" {
+				t.Errorf("Write() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_NewFromCliArgs(t *testing.T) {
+	tests := []struct {
+		output     string
+		wantWriter bool
+		wantErr    bool
+	}{
+		{output: "", wantWriter: false},
+		{output: OutputNdjson, wantWriter: true},
+		{output: "csv", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.output, func(t *testing.T) {
+			w, err := NewFromCliArgs(&config.CliArgs{Output: tt.output})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("NewFromCliArgs() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if (w != nil) != tt.wantWriter {
+				t.Errorf("NewFromCliArgs() = %v, want writer %v", w, tt.wantWriter)
+			}
+		})
+	}
+}
@@ -0,0 +1,102 @@
+package output
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+type (
+	// Row provides the attribute values of a row
+	Row[A any] interface {
+		// Text returns the formatted value (text and csv output)
+		Text(attr A) string
+		// Value returns the value for json output
+		Value(attr A) any
+	}
+	// RowWriter writes rows of attribute values in the given format.
+	// Text output aligns the values in columns below a header, csv output
+	// writes a header line and json output writes an object per row.
+	RowWriter[A fmt.Stringer] struct {
+		format Format
+		attrs  []A
+		writer io.Writer
+		csv    *csv.Writer
+	}
+)
+
+// minimum width of a text column
+const minColWidth = 8
+
+func NewRowWriter[A fmt.Stringer](format Format, attrs []A, w io.Writer) *RowWriter[A] {
+	ret := &RowWriter[A]{format: format, attrs: attrs, writer: w}
+	if format == FormatCSV {
+		ret.csv = csv.NewWriter(w)
+	}
+	return ret
+}
+
+func (r *RowWriter[A]) Header() {
+	names := make([]string, len(r.attrs))
+	for i, attr := range r.attrs {
+		names[i] = attr.String()
+	}
+	//nolint:exhaustive // by design
+	switch r.format {
+	case FormatText:
+		r.writeText(names)
+	case FormatCSV:
+		r.writeCsv(names)
+	}
+}
+
+// Line writes a row. Csv output is flushed, so the rows can be followed live.
+func (r *RowWriter[A]) Line(row Row[A]) {
+	if r.format == FormatJSON {
+		r.writeJSON(row)
+		return
+	}
+	values := make([]string, len(r.attrs))
+	for i, attr := range r.attrs {
+		values[i] = row.Text(attr)
+	}
+	if r.format == FormatCSV {
+		r.writeCsv(values)
+		return
+	}
+	r.writeText(values)
+}
+
+func (r *RowWriter[A]) Flush() {
+	if r.csv != nil {
+		r.csv.Flush()
+	}
+}
+
+//nolint:errcheck // by design
+func (r *RowWriter[A]) writeText(values []string) {
+	out := make([]string, len(values))
+	for i, v := range values {
+		out[i] = fmt.Sprintf("%*s", max(len(r.attrs[i].String()), minColWidth), v)
+	}
+	io.WriteString(r.writer, strings.Join(out, " ")+"\n")
+}
+
+//nolint:errcheck // by design
+func (r *RowWriter[A]) writeCsv(values []string) {
+	r.csv.Write(values)
+	r.csv.Flush()
+}
+
+//nolint:errcheck // by design
+func (r *RowWriter[A]) writeJSON(row Row[A]) {
+	out := make(map[string]any, len(r.attrs))
+	for _, attr := range r.attrs {
+		out[attr.String()] = row.Value(attr)
+	}
+	if data, err := json.Marshal(out); err == nil {
+		r.writer.Write(append(data, '\n'))
+	}
+}
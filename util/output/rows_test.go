@@ -0,0 +1,57 @@
+package output
+
+import (
+	"bytes"
+	"testing"
+)
+
+type testAttr string
+
+func (a testAttr) String() string { return string(a) }
+
+type testRow map[testAttr]int
+
+func (r testRow) Text(attr testAttr) string {
+	return map[int]string{1: "one", 22: "twenty-two"}[r[attr]]
+}
+
+func (r testRow) Value(attr testAttr) any { return r[attr] }
+
+func Test_RowWriter(t *testing.T) {
+	attrs := []testAttr{"idx", "description"}
+	rows := []testRow{{"idx": 1, "description": 22}, {"idx": 22}}
+	tests := []struct {
+		format Format
+		want   string
+	}{
+		{
+			format: FormatText,
+			want: "     idx description\n" +
+				"     one  twenty-two\n" +
+				"twenty-two            \n",
+		},
+		{
+			format: FormatCSV,
+			want:   "idx,description\none,twenty-two\ntwenty-two,\n",
+		},
+		{
+			format: FormatJSON,
+			want: `{"description":22,"idx":1}` + "\n" +
+				`{"description":0,"idx":22}` + "\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format.String(), func(t *testing.T) {
+			buf := bytes.Buffer{}
+			w := NewRowWriter(tt.format, attrs, &buf)
+			w.Header()
+			for _, row := range rows {
+				w.Line(row)
+			}
+			w.Flush()
+			if got := buf.String(); got != tt.want {
+				t.Errorf("output =\n%q\nwant\n%q", got, tt.want)
+			}
+		})
+	}
+}
@@ -44,12 +44,13 @@ func NewSessionOutput(opts ...Option) Output {
 	for _, opt := range opts {
 		opt(cfg)
 	}
-	//nolint:exhaustive // by design
 	switch cfg.format {
 	case output.FormatCSV:
 		return &sessionOutput{outputter: newSessionCsv(cfg)}
 	case output.FormatJSON:
 		return &sessionOutput{outputter: &sessionJSON{config: cfg}}
+	case output.FormatText:
+		return &sessionOutput{outputter: newSessionText(cfg)}
 	}
 	return &sessionOutput{outputter: &sessionEmpty{config: cfg}}
 }
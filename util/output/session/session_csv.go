@@ -31,45 +31,47 @@ func (s *sessionCsv) header() {
 	s.writer.Write(data)
 }
 
-//nolint:cyclop,funlen // by design
 func (s *sessionCsv) line(data *racestatev1.PublishStateRequest) {
 	out := []string{}
 	for _, attr := range s.config.attrs {
-		var valueString string
-		switch attr {
-		case SessionTime:
-			valueString = fmt.Sprintf("%.3f", data.Session.GetSessionTime())
-		case SessionNum:
-			valueString = fmt.Sprintf("%d", data.Session.GetSessionNum())
-		case SessionTimeOfDay:
-			valueString = fmt.Sprintf("%d", data.Session.GetTimeOfDay())
-		case SessionLapsRemain:
-			valueString = fmt.Sprintf("%d", data.Session.GetLapsRemain())
-		case SessionTimeRemain:
-			valueString = fmt.Sprintf("%.3f", data.Session.GetTimeRemain())
-		case SessionTrackTemp:
-			valueString = fmt.Sprintf("%.2f", data.Session.GetTrackTemp())
-		case SessionAirTemp:
-			valueString = fmt.Sprintf("%.2f", data.Session.GetTrackTemp())
-		case SessionTrackWetness:
-			valueString = data.Session.GetTrackWetness().String()
-		case SessionPrecipitation:
-			valueString = fmt.Sprintf("%.4f", data.Session.GetPrecipitation())
-		case SessionFlagState:
-			valueString = data.Session.GetFlagState()
-		case SessionTimestamp:
-			valueString = data.Timestamp.AsTime().String()
-		case SessionUndefined:
-			valueString = "undefined"
-		default:
-			valueString = "unknown"
-		}
-		out = append(out, valueString)
+		out = append(out, sessionAttrValue(data, attr))
 	}
 	//nolint:errcheck // by design
 	s.writer.Write(out)
 }
 
+//nolint:cyclop // by design
+func sessionAttrValue(data *racestatev1.PublishStateRequest, attr SessionAttr) string {
+	switch attr {
+	case SessionTime:
+		return fmt.Sprintf("%.3f", data.Session.GetSessionTime())
+	case SessionNum:
+		return fmt.Sprintf("%d", data.Session.GetSessionNum())
+	case SessionTimeOfDay:
+		return fmt.Sprintf("%d", data.Session.GetTimeOfDay())
+	case SessionLapsRemain:
+		return fmt.Sprintf("%d", data.Session.GetLapsRemain())
+	case SessionTimeRemain:
+		return fmt.Sprintf("%.3f", data.Session.GetTimeRemain())
+	case SessionTrackTemp:
+		return fmt.Sprintf("%.2f", data.Session.GetTrackTemp())
+	case SessionAirTemp:
+		return fmt.Sprintf("%.2f", data.Session.GetAirTemp())
+	case SessionTrackWetness:
+		return data.Session.GetTrackWetness().String()
+	case SessionPrecipitation:
+		return fmt.Sprintf("%.4f", data.Session.GetPrecipitation())
+	case SessionFlagState:
+		return data.Session.GetFlagState()
+	case SessionTimestamp:
+		return data.Timestamp.AsTime().String()
+	case SessionUndefined:
+		return "undefined"
+	default:
+		return "unknown"
+	}
+}
+
 func (s *sessionCsv) flush() {
 	s.writer.Flush()
 }
@@ -34,7 +34,7 @@ func (s *sessionJSON) line(data *racestatev1.PublishStateRequest) {
 		case SessionTrackTemp:
 			out[attr.String()] = data.Session.GetTrackTemp()
 		case SessionAirTemp:
-			out[attr.String()] = data.Session.GetTrackTemp()
+			out[attr.String()] = data.Session.GetAirTemp()
 		case SessionTrackWetness:
 			out[attr.String()] = data.Session.GetTrackWetness().String()
 		case SessionPrecipitation:
@@ -0,0 +1,43 @@
+package session
+
+import (
+	racestatev1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/racestate/v1"
+
+	"github.com/mpapenbr/iracelog-cli/util/output"
+)
+
+type (
+	// sessionText renders the attributes as aligned columns
+	sessionText struct {
+		writer *output.RowWriter[SessionAttr]
+	}
+	sessionRow struct {
+		data *racestatev1.PublishStateRequest
+	}
+)
+
+func newSessionText(cfg *OutputConfig) *sessionText {
+	return &sessionText{
+		writer: output.NewRowWriter(output.FormatText, cfg.attrs, cfg.writer),
+	}
+}
+
+func (s *sessionText) header() {
+	s.writer.Header()
+}
+
+func (s *sessionText) line(data *racestatev1.PublishStateRequest) {
+	s.writer.Line(&sessionRow{data: data})
+}
+
+func (s *sessionText) flush() {
+	s.writer.Flush()
+}
+
+func (r *sessionRow) Text(attr SessionAttr) string {
+	return sessionAttrValue(r.data, attr)
+}
+
+func (r *sessionRow) Value(attr SessionAttr) any {
+	return r.Text(attr)
+}
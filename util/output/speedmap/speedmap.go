@@ -0,0 +1,127 @@
+package speedmap
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+
+	speedmapv1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/speedmap/v1"
+
+	"github.com/mpapenbr/iracelog-cli/util/output"
+)
+
+type (
+	Option       func(*OutputConfig)
+	OutputConfig struct {
+		format output.Format
+		attrs  []SpeedmapAttr
+		writer io.Writer
+	}
+	Output interface {
+		Header()
+		// Line outputs the speedmap data of a car class
+		Line(data *speedmapv1.Speedmap, class string)
+		Flush()
+	}
+
+	speedmapOutput struct {
+		*output.RowWriter[SpeedmapAttr]
+	}
+	speedmapRow struct {
+		data  *speedmapv1.Speedmap
+		class string
+	}
+)
+
+func NewSpeedmapOutput(opts ...Option) Output {
+	cfg := &OutputConfig{
+		format: output.FormatText,
+		attrs:  []SpeedmapAttr{},
+		writer: os.Stdout,
+	}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	return &speedmapOutput{output.NewRowWriter(cfg.format, cfg.attrs, cfg.writer)}
+}
+
+func WithFormat(f output.Format) Option {
+	return func(cfg *OutputConfig) {
+		cfg.format = f
+	}
+}
+
+func WithWriter(w io.Writer) Option {
+	return func(cfg *OutputConfig) {
+		cfg.writer = w
+	}
+}
+
+func WithSpeedmapAttrs(attrs []SpeedmapAttr) Option {
+	return func(cfg *OutputConfig) {
+		cfg.attrs = attrs
+	}
+}
+
+func WithAllSpeedmapAttrs() Option {
+	return func(cfg *OutputConfig) {
+		cfg.attrs = SupportedSpeedmapAttrs()
+	}
+}
+
+func (s *speedmapOutput) Line(data *speedmapv1.Speedmap, class string) {
+	s.RowWriter.Line(&speedmapRow{data: data, class: class})
+}
+
+//nolint:cyclop // by design
+func (r *speedmapRow) Text(attr SpeedmapAttr) string {
+	speeds := r.data.GetData()[r.class].GetChunkSpeeds()
+	switch attr {
+	case SpeedmapSessionTime:
+		return fmt.Sprintf("%.0f", r.data.GetSessionTime())
+	case SpeedmapClass:
+		return r.class
+	case SpeedmapLaptime:
+		return fmt.Sprintf("%.3f", r.data.GetData()[r.class].GetLaptime())
+	case SpeedmapChunkSize:
+		return fmt.Sprintf("%d", r.data.GetChunkSize())
+	case SpeedmapMinSpeed:
+		if len(speeds) == 0 {
+			return ""
+		}
+		return fmt.Sprintf("%.1f", slices.Min(speeds))
+	case SpeedmapMaxSpeed:
+		if len(speeds) == 0 {
+			return ""
+		}
+		return fmt.Sprintf("%.1f", slices.Max(speeds))
+	case SpeedmapSpeeds:
+		out := make([]string, len(speeds))
+		for i, v := range speeds {
+			out[i] = fmt.Sprintf("%.1f", v)
+		}
+		return strings.Join(out, " ")
+	case SpeedmapUndefined:
+		return "undefined"
+	default:
+		return "unknown"
+	}
+}
+
+//nolint:exhaustive // by design
+func (r *speedmapRow) Value(attr SpeedmapAttr) any {
+	switch attr {
+	case SpeedmapSessionTime:
+		return r.data.GetSessionTime()
+	case SpeedmapLaptime:
+		return r.data.GetData()[r.class].GetLaptime()
+	case SpeedmapChunkSize:
+		return r.data.GetChunkSize()
+	case SpeedmapSpeeds:
+		return r.data.GetData()[r.class].GetChunkSpeeds()
+	default:
+		return r.Text(attr)
+	}
+}
@@ -0,0 +1,101 @@
+package speedmap
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/mpapenbr/iracelog-cli/util/output"
+)
+
+const (
+	SpeedmapUndefined SpeedmapAttr = iota
+	SpeedmapSessionTime
+	SpeedmapClass
+	SpeedmapLaptime
+	SpeedmapChunkSize
+	SpeedmapMinSpeed
+	SpeedmapMaxSpeed
+	SpeedmapSpeeds
+)
+
+type (
+	SpeedmapAttr int8
+)
+
+func ParseSpeedmapAttr(text string) (SpeedmapAttr, error) {
+	var f SpeedmapAttr
+	err := f.UnmarshalText([]byte(text))
+	return f, err
+}
+
+// SupportedSpeedmapAttrs returns all attributes except the chunk speeds
+func SupportedSpeedmapAttrs() []SpeedmapAttr {
+	return []SpeedmapAttr{
+		SpeedmapSessionTime,
+		SpeedmapClass,
+		SpeedmapLaptime,
+		SpeedmapChunkSize,
+		SpeedmapMinSpeed,
+		SpeedmapMaxSpeed,
+	}
+}
+
+//nolint:exhaustive,cyclop // by design
+func (f SpeedmapAttr) String() string {
+	switch f {
+	case SpeedmapSessionTime:
+		return "sessiontime"
+	case SpeedmapClass:
+		return "class"
+	case SpeedmapLaptime:
+		return "laptime"
+	case SpeedmapChunkSize:
+		return "chunksize"
+	case SpeedmapMinSpeed:
+		return "minspeed"
+	case SpeedmapMaxSpeed:
+		return "maxspeed"
+	case SpeedmapSpeeds:
+		return "speeds"
+	default:
+		return output.Unknown
+	}
+}
+
+func (f SpeedmapAttr) MarshalText() ([]byte, error) {
+	return []byte(f.String()), nil
+}
+
+func (f *SpeedmapAttr) UnmarshalText(text []byte) error {
+	if f == nil {
+		return output.ErrUnmarshalNil
+	}
+	if !f.unmarshalText(text) && !f.unmarshalText(bytes.ToLower(text)) {
+		return fmt.Errorf("unrecognized speedmap attr: %q", text)
+	}
+	return nil
+}
+
+//nolint:cyclop // by design
+func (f *SpeedmapAttr) unmarshalText(text []byte) bool {
+	switch strings.ToLower(string(text)) {
+	case "sessiontime":
+		*f = SpeedmapSessionTime
+	case "class":
+		*f = SpeedmapClass
+	case "laptime":
+		*f = SpeedmapLaptime
+	case "chunksize":
+		*f = SpeedmapChunkSize
+	case "minspeed":
+		*f = SpeedmapMinSpeed
+	case "maxspeed":
+		*f = SpeedmapMaxSpeed
+	case "speeds":
+		*f = SpeedmapSpeeds
+	default:
+		return false
+	}
+	return true
+}
@@ -0,0 +1,312 @@
+package replay
+
+import (
+	"fmt"
+	"maps"
+	"math/rand"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mpapenbr/iracelog-cli/log"
+)
+
+// Chaos injects faults into the published data. It is used to test how the
+// server and the frontend handle bad provider data.
+// Each fault is applied with its probability per message. Using the same seed
+// (and the same data) the same faults are injected.
+type Chaos struct {
+	Seed       int64
+	Faults     map[providerType]*Faults
+	Disconnect float64       // probability to unregister and re-register the event
+	MaxDelay   time.Duration // upper limit for delayed messages (data time)
+	rnd        *rand.Rand
+	held       map[providerType]peek // messages held back for reordering
+	delayed    []delayedMsg          // ordered by release
+	stats      ChaosStats
+}
+
+// delayedMsg is held back until data with a timestamp after release is sent
+type delayedMsg struct {
+	release time.Time
+	p       peek
+}
+
+// Faults contains the probabilities (0..1) of the faults for a provider
+type Faults struct {
+	Drop      float64 // message is not sent
+	Duplicate float64 // message is sent twice
+	Delay     float64 // message is sent after data up to MaxDelay later
+	Reorder   float64 // message is sent after the next message of the provider
+	Truncate  float64 // a random part of the payload is removed
+}
+
+// ChaosStats counts the injected faults
+type ChaosStats struct {
+	Dropped     int
+	Duplicated  int
+	Delayed     int
+	Reordered   int
+	Truncated   int
+	Disconnects int
+}
+
+var chaosProviderNames = map[string]providerType{
+	"state":    StateData,
+	"driver":   DriverData,
+	"speedmap": SpeedmapData,
+}
+
+func WithChaos(c *Chaos) ReplayOption {
+	return func(r *ReplayTask) {
+		r.chaos = c
+	}
+}
+
+// ParseChaos creates a Chaos from a spec like "drop=0.01,state.dup=0.05".
+// Keys are drop, dup, delay, reorder, truncate, disconnect and max-delay.
+// Fault keys may be prefixed with a provider (state, driver, speedmap),
+// otherwise they apply to all providers.
+//
+//nolint:funlen,cyclop // by design
+func ParseChaos(spec string, seed int64) (*Chaos, error) {
+	ret := &Chaos{
+		Seed:     seed,
+		Faults:   make(map[providerType]*Faults),
+		MaxDelay: 5 * time.Second,
+	}
+	for _, p := range chaosProviderNames {
+		ret.Faults[p] = &Faults{}
+	}
+	for _, item := range strings.Split(spec, ",") {
+		if item = strings.TrimSpace(item); item == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(item, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid chaos setting: %s", item)
+		}
+		if key == "max-delay" {
+			d, err := time.ParseDuration(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid chaos max-delay: %w", err)
+			}
+			ret.MaxDelay = d
+			continue
+		}
+		prob, err := strconv.ParseFloat(value, 64)
+		if err != nil || prob < 0 || prob > 1 {
+			return nil, fmt.Errorf("invalid chaos probability: %s", item)
+		}
+		if key == "disconnect" {
+			ret.Disconnect = prob
+			continue
+		}
+		providers := ret.Faults
+		if name, fault, found := strings.Cut(key, "."); found {
+			pt, ok := chaosProviderNames[name]
+			if !ok {
+				return nil, fmt.Errorf("unknown chaos provider: %s", name)
+			}
+			providers = map[providerType]*Faults{pt: ret.Faults[pt]}
+			key = fault
+		}
+		for _, f := range providers {
+			switch key {
+			case "drop":
+				f.Drop = prob
+			case "dup":
+				f.Duplicate = prob
+			case "delay":
+				f.Delay = prob
+			case "reorder":
+				f.Reorder = prob
+			case "truncate":
+				f.Truncate = prob
+			default:
+				return nil, fmt.Errorf("unknown chaos fault: %s", key)
+			}
+		}
+	}
+	return ret, nil
+}
+
+// ChaosStats returns the faults injected so far
+func (r *ReplayTask) ChaosStats() ChaosStats {
+	r.statsMu.Lock()
+	defer r.statsMu.Unlock()
+	if r.chaos == nil {
+		return ChaosStats{}
+	}
+	return r.chaos.stats
+}
+
+func (c *Chaos) hit(prob float64) bool {
+	if prob <= 0 {
+		return false
+	}
+	if c.rnd == nil {
+		c.rnd = rand.New(rand.NewSource(c.Seed)) //nolint:gosec // no security here
+	}
+	return c.rnd.Float64() < prob
+}
+
+func (r *ReplayTask) updateChaosStats(f func(s *ChaosStats)) {
+	r.statsMu.Lock()
+	defer r.statsMu.Unlock()
+	f(&r.chaos.stats)
+}
+
+// publishWithChaos publishes the data and injects the configured faults
+//
+//nolint:cyclop,funlen // by design
+func (r *ReplayTask) publishWithChaos(p peek) error {
+	c := r.chaos
+	if c == nil {
+		return r.publish(p)
+	}
+	if err := r.localCtx.Err(); err != nil {
+		return err
+	}
+	if err := r.releaseDelayed(p.stamp().ts); err != nil {
+		return err
+	}
+	if c.hit(c.Disconnect) {
+		if err := r.reconnect(); err != nil {
+			return err
+		}
+	}
+	f := c.Faults[p.provider()]
+	if f == nil {
+		f = &Faults{}
+	}
+	if c.hit(f.Drop) {
+		r.myLog.Debug("chaos: dropping message", log.String("provider", string(p.provider())))
+		r.updateChaosStats(func(s *ChaosStats) { s.Dropped++ })
+		return nil
+	}
+	if c.hit(f.Reorder) && c.held[p.provider()] == nil {
+		if c.held == nil {
+			c.held = make(map[providerType]peek)
+		}
+		c.held[p.provider()] = p.clone()
+		r.updateChaosStats(func(s *ChaosStats) { s.Reordered++ })
+		return nil
+	}
+	if c.hit(f.Truncate) {
+		c.truncate(p)
+		r.updateChaosStats(func(s *ChaosStats) { s.Truncated++ })
+	}
+	if c.hit(f.Delay) && c.MaxDelay > 0 {
+		delay := time.Duration(c.rnd.Int63n(int64(c.MaxDelay)))
+		c.delay(p.clone(), p.stamp().ts.Add(delay))
+		r.updateChaosStats(func(s *ChaosStats) { s.Delayed++ })
+		return nil
+	}
+	if err := r.publish(p); err != nil {
+		return err
+	}
+	if c.hit(f.Duplicate) {
+		r.updateChaosStats(func(s *ChaosStats) { s.Duplicated++ })
+		if err := r.send(p); err != nil {
+			return err
+		}
+	}
+	return r.releaseHeld(p.provider())
+}
+
+// releaseHeld publishes the message held back for reordering (if any)
+func (r *ReplayTask) releaseHeld(pt providerType) error {
+	if r.chaos == nil || r.chaos.held[pt] == nil {
+		return nil
+	}
+	held := r.chaos.held[pt]
+	delete(r.chaos.held, pt)
+	return r.publish(held)
+}
+
+// delay holds back the message until data after release is sent
+func (c *Chaos) delay(p peek, release time.Time) {
+	idx, _ := slices.BinarySearchFunc(c.delayed, release,
+		func(d delayedMsg, t time.Time) int {
+			if d.release.After(t) {
+				return 1
+			}
+			return -1 // keeps the order of messages with the same release
+		})
+	c.delayed = slices.Insert(c.delayed, idx, delayedMsg{release: release, p: p})
+}
+
+// releaseDelayed publishes the delayed messages which are due before ts
+func (r *ReplayTask) releaseDelayed(ts time.Time) error {
+	c := r.chaos
+	for len(c.delayed) > 0 && !c.delayed[0].release.After(ts) {
+		d := c.delayed[0]
+		c.delayed = c.delayed[1:]
+		if err := r.publish(d.p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// flushChaos publishes all messages held back for delaying and reordering
+func (r *ReplayTask) flushChaos() error {
+	if r.chaos == nil {
+		return nil
+	}
+	for _, d := range r.chaos.delayed {
+		if err := r.publish(d.p); err != nil {
+			return err
+		}
+	}
+	r.chaos.delayed = nil
+	for _, pt := range []providerType{StateData, DriverData, SpeedmapData} {
+		if err := r.releaseHeld(pt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// reconnect simulates a provider disconnect
+func (r *ReplayTask) reconnect() error {
+	r.myLog.Info("chaos: simulating provider disconnect")
+	r.updateChaosStats(func(s *ChaosStats) { s.Disconnects++ })
+	r.syncTargets() // the targets get the data sent before the disconnect
+	if err := r.unregisterEvents(); err != nil {
+		return err
+	}
+	// the server may keep the event registered for a while
+	return r.registerEvents(r.registerReq, true)
+}
+
+// truncate removes a random tail of the repeated payload data
+func (c *Chaos) truncate(p peek) {
+	keep := func(n int) int {
+		if n == 0 {
+			return 0
+		}
+		return c.rnd.Intn(n)
+	}
+	switch v := p.(type) {
+	case *peekStateData:
+		v.dataReq.Cars = v.dataReq.Cars[:keep(len(v.dataReq.Cars))]
+	case *peekDriverData:
+		v.dataReq.Entries = v.dataReq.Entries[:keep(len(v.dataReq.Entries))]
+	case *peekSpeedmapData:
+		if v.dataReq.Speedmap == nil {
+			return
+		}
+		data := v.dataReq.Speedmap.Data
+		// sorted, so the result only depends on the seed
+		for _, class := range slices.Sorted(maps.Keys(data)) {
+			d := data[class]
+			if d == nil {
+				continue
+			}
+			d.ChunkSpeeds = d.ChunkSpeeds[:keep(len(d.ChunkSpeeds))]
+		}
+	}
+}
@@ -0,0 +1,190 @@
+package replay
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"buf.build/gen/go/mpapenbr/iracelog/grpc/go/iracelog/provider/v1/providerv1grpc"
+	providerv1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/provider/v1"
+	speedmapv1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/speedmap/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// chaosStates returns the data timestamps (seconds) of the published states
+func chaosStates(records []*fileRecord) []float64 {
+	ret := []float64{}
+	for _, rec := range records {
+		if rec.Type == RecordState {
+			ret = append(ret, rec.DataTS.Sub(testTime(0).AsTime()).Seconds())
+		}
+	}
+	return ret
+}
+
+//nolint:funlen // table
+func Test_chaos(t *testing.T) {
+	states := []float64{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}
+	tests := []struct {
+		name       string
+		spec       string
+		wantStates []float64 // nil: all states in any order
+		wantStats  ChaosStats
+	}{
+		{
+			name:       "no faults",
+			spec:       "",
+			wantStates: states,
+		},
+		{
+			name:       "drop",
+			spec:       "state.drop=1",
+			wantStates: []float64{},
+			wantStats:  ChaosStats{Dropped: 10},
+		},
+		{
+			name:       "reorder",
+			spec:       "state.reorder=1",
+			wantStates: []float64{1, 0, 3, 2, 5, 4, 7, 6, 9, 8},
+			wantStats:  ChaosStats{Reordered: 5},
+		},
+		{
+			name:      "delay",
+			spec:      "state.delay=1,max-delay=3s",
+			wantStats: ChaosStats{Delayed: 10},
+		},
+		{
+			name:       "delay without max delay",
+			spec:       "state.delay=1,max-delay=0s",
+			wantStates: states,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := ParseChaos(tt.spec, 1)
+			if err != nil {
+				t.Fatalf("ParseChaos() error = %v", err)
+			}
+			provider := newTestProvider(states, []float64{0.5, 4.5, 8.5}, nil)
+			records := dryRunRecords(t, provider, WithChaos(c))
+			got := chaosStates(records)
+			want := tt.wantStates
+			if want == nil {
+				got = slices.Sorted(slices.Values(got))
+				want = states
+			}
+			if !slices.Equal(got, want) {
+				t.Errorf("states = %v, want %v", got, want)
+			}
+			if c.stats != tt.wantStats {
+				t.Errorf("stats = %+v, want %+v", c.stats, tt.wantStats)
+			}
+			checkReleased(t, records, c.MaxDelay)
+		})
+	}
+}
+
+// checkReleased verifies that delayed data was published before data
+// maxDelay after it
+func checkReleased(t *testing.T, records []*fileRecord, maxDelay time.Duration) {
+	t.Helper()
+	latest := time.Time{}
+	for i, rec := range records {
+		if rec.DataTS == nil {
+			continue
+		}
+		if rec.DataTS.Add(maxDelay).Before(latest) {
+			t.Errorf("record %d (%s) released after data at %s",
+				i, rec.DataTS, latest)
+		}
+		if rec.DataTS.After(latest) {
+			latest = *rec.DataTS
+		}
+	}
+}
+
+// registerOnceService accepts the first registration of an event only
+type registerOnceService struct {
+	providerv1grpc.ProviderServiceClient
+	registered   int
+	unregistered int
+}
+
+//nolint:whitespace // by design
+func (s *registerOnceService) RegisterEvent(
+	_ context.Context,
+	req *providerv1.RegisterEventRequest,
+	_ ...grpc.CallOption,
+) (*providerv1.RegisterEventResponse, error) {
+	s.registered++
+	if s.registered > 1 {
+		return nil, status.Error(codes.AlreadyExists, "event already registered")
+	}
+	return &providerv1.RegisterEventResponse{Event: req.Event}, nil
+}
+
+//nolint:whitespace // by design
+func (s *registerOnceService) UnregisterEvent(
+	_ context.Context,
+	_ *providerv1.UnregisterEventRequest,
+	_ ...grpc.CallOption,
+) (*providerv1.UnregisterEventResponse, error) {
+	s.unregistered++
+	return &providerv1.UnregisterEventResponse{}, nil
+}
+
+// verifies that a simulated disconnect re-attaches to a still registered event
+func Test_chaosReconnectAlreadyExists(t *testing.T) {
+	c, err := ParseChaos("disconnect=1", 1)
+	if err != nil {
+		t.Fatalf("ParseChaos() error = %v", err)
+	}
+	service := &registerOnceService{}
+	r := newTestTargets("server")
+	defer r.stopTargets()
+	r.ctx = context.Background()
+	r.chaos = c
+	r.targets[0].providerService = service
+	r.registerReq = newTestProvider(nil, nil, nil).eventReq
+	if err := r.registerEvents(r.registerReq, false); err != nil {
+		t.Fatalf("registerEvents() error = %v", err)
+	}
+	if err := r.reconnect(); err != nil {
+		t.Fatalf("reconnect() error = %v", err)
+	}
+	if service.registered != 2 || service.unregistered != 1 {
+		t.Errorf("registered %d, unregistered %d times, want 2, 1",
+			service.registered, service.unregistered)
+	}
+	if err := r.targets[0].failed(); err != nil {
+		t.Errorf("failed() = %v, want nil", err)
+	}
+	if r.event.GetKey() != "test" {
+		t.Errorf("event key = %v, want test", r.event.GetKey())
+	}
+}
+
+// verifies that truncating skips classes without data
+func Test_chaosTruncateNilClass(t *testing.T) {
+	c, err := ParseChaos("speedmap.truncate=1", 1)
+	if err != nil {
+		t.Fatalf("ParseChaos() error = %v", err)
+	}
+	c.hit(1) // initializes the random source
+	req := testSpeedmap(0)
+	req.Speedmap = &speedmapv1.Speedmap{
+		Data: map[string]*speedmapv1.ClassSpeedmapData{
+			"A": {ChunkSpeeds: []float64{10, 20, 30}},
+			"B": nil,
+		},
+	}
+	p := &peekSpeedmapData{}
+	p.dataReq = req
+	c.truncate(p)
+	if n := len(req.Speedmap.Data["A"].ChunkSpeeds); n >= 3 {
+		t.Errorf("class A has %d chunk speeds, want less than 3", n)
+	}
+}
@@ -0,0 +1,230 @@
+package replay
+
+import (
+	"encoding/json"
+	"errors"
+	"maps"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/mpapenbr/iracelog-cli/log"
+)
+
+var ErrReplayCompleted = errors.New("replay already completed")
+
+// Checkpoint records the progress of a replay, so that an interrupted replay
+// can be resumed with the same event key.
+type Checkpoint struct {
+	EventKey   string `json:"eventKey"`
+	Event      string `json:"event,omitempty"`      // source event
+	SourceFile string `json:"sourceFile,omitempty"` // replay file
+	// last published data per provider
+	Providers map[providerType]ProviderCheckpoint `json:"providers"`
+	// offset used by RetimeTransformer
+	RetimeOffset *time.Duration `json:"retimeOffset,omitempty"`
+	// generic name numbers used by AnonymizeTransformer (key: real name)
+	AnonymizedDrivers map[string]int `json:"anonymizedDrivers,omitempty"`
+	AnonymizedTeams   map[string]int `json:"anonymizedTeams,omitempty"`
+	Updated           time.Time      `json:"updated"`
+	Completed         bool           `json:"completed"`
+}
+
+// ProviderCheckpoint contains the last published data of a provider.
+// Count is the number of published items with timestamp LastTS.
+type ProviderCheckpoint struct {
+	LastTS time.Time `json:"lastTs"`
+	Count  int       `json:"count"`
+}
+
+type checkpointWriter struct {
+	filename string
+	interval time.Duration
+	mu       sync.Mutex
+	cp       Checkpoint
+}
+
+// WithCheckpoint writes the checkpoint to the file in the given interval and
+// when the replay is done. cp provides the source of the replayed event.
+//
+//nolint:whitespace // by design
+func WithCheckpoint(
+	filename string,
+	interval time.Duration,
+	cp *Checkpoint,
+) ReplayOption {
+	return func(r *ReplayTask) {
+		w := &checkpointWriter{filename: filename, interval: interval, cp: *cp}
+		if w.cp.Providers == nil {
+			w.cp.Providers = make(map[providerType]ProviderCheckpoint)
+		}
+		r.checkpoint = w
+	}
+}
+
+// WithResume continues the replay after the position of the checkpoint.
+// Data up to that position is not published.
+func WithResume(cp *Checkpoint) ReplayOption {
+	return func(r *ReplayTask) {
+		r.resume = cp
+	}
+}
+
+func ReadCheckpoint(filename string) (*Checkpoint, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	ret := &Checkpoint{}
+	if err := json.Unmarshal(data, ret); err != nil {
+		return nil, err
+	}
+	if ret.Completed {
+		return ret, ErrReplayCompleted
+	}
+	return ret, nil
+}
+
+// Write stores the checkpoint. The file is replaced atomically.
+func (cp *Checkpoint) Write(filename string) error {
+	data, err := json.MarshalIndent(cp, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(filename), filepath.Base(filename)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), filename)
+}
+
+// skipResumed reports if the data was already published before the checkpoint.
+// must be called with the data in the order of publishing.
+func (r *ReplayTask) skipResumed(p providerType, s *stampInfo) bool {
+	if r.resume == nil {
+		return false
+	}
+	last, ok := r.resume.Providers[p]
+	if !ok || s.ts.After(last.LastTS) {
+		return false
+	}
+	if s.ts.Equal(last.LastTS) {
+		if last.Count == 0 {
+			return false
+		}
+		last.Count--
+		r.resume.Providers[p] = last
+	}
+	return true
+}
+
+// restoreTransformers continues the transformers with the state of the
+// resumed replay
+func (r *ReplayTask) restoreTransformers() {
+	if r.resume == nil {
+		return
+	}
+	for _, t := range r.transformers {
+		if t.RestoreState != nil {
+			t.RestoreState(r.resume)
+		}
+	}
+}
+
+// saveTransformers stores the state of the transformers in the checkpoint.
+// must be called with the lock of the checkpoint writer held.
+func (r *ReplayTask) saveTransformers() {
+	for _, t := range r.transformers {
+		if t.SaveState != nil {
+			t.SaveState(&r.checkpoint.cp)
+		}
+	}
+}
+
+// initCheckpoint sets the event key of the registered event and continues
+// the checkpoint of a resumed replay
+func (r *ReplayTask) initCheckpoint() {
+	if r.checkpoint == nil {
+		return
+	}
+	r.checkpoint.cp.EventKey = r.registerReq.Key
+	if r.resume != nil {
+		maps.Copy(r.checkpoint.cp.Providers, r.resume.Providers)
+	}
+	r.saveTransformers()
+}
+
+// recordCheckpoint updates the checkpoint after data was published
+func (r *ReplayTask) recordCheckpoint(p providerType, s *stampInfo) {
+	if r.checkpoint == nil {
+		return
+	}
+	w := r.checkpoint
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	cur := w.cp.Providers[p]
+	if s.ts.Equal(cur.LastTS) {
+		cur.Count++
+	} else {
+		cur = ProviderCheckpoint{LastTS: s.ts, Count: 1}
+	}
+	w.cp.Providers[p] = cur
+	r.saveTransformers()
+}
+
+// writeCheckpoint writes the current checkpoint to the file
+func (r *ReplayTask) writeCheckpoint(completed bool) {
+	w := r.checkpoint
+	w.mu.Lock()
+	w.cp.Updated = time.Now()
+	w.cp.Completed = completed
+	cp := w.cp
+	cp.Providers = maps.Clone(w.cp.Providers)
+	w.mu.Unlock()
+	if err := cp.Write(w.filename); err != nil {
+		r.myLog.Warn("could not write checkpoint",
+			log.String("file", w.filename),
+			log.ErrorField(err))
+	}
+}
+
+// startCheckpoints writes the checkpoint periodically until the replay is done.
+// The checkpoint is written a final time when done.
+func (r *ReplayTask) startCheckpoints() (stop func(completed bool)) {
+	if r.checkpoint == nil {
+		return func(bool) {}
+	}
+	done := make(chan struct{})
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		if r.checkpoint.interval <= 0 {
+			<-done
+			return
+		}
+		ticker := time.NewTicker(r.checkpoint.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				r.writeCheckpoint(false)
+			}
+		}
+	}()
+	return func(completed bool) {
+		close(done)
+		<-finished
+		r.writeCheckpoint(completed)
+	}
+}
@@ -0,0 +1,147 @@
+package replay
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	racestatev1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/racestate/v1"
+)
+
+func readTestCheckpoint(t *testing.T, filename string) *Checkpoint {
+	t.Helper()
+	cp, err := ReadCheckpoint(filename)
+	if err != nil && !errors.Is(err, ErrReplayCompleted) {
+		t.Fatalf("ReadCheckpoint() error = %v", err)
+	}
+	return cp
+}
+
+// verifies the checkpoint is written when the replay is canceled
+func Test_checkpointCanceled(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "replay.checkpoint")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stop := &Transformer{
+		State: func(req *racestatev1.PublishStateRequest) {
+			if req.Session.SessionTime == 2 {
+				cancel()
+			}
+		},
+	}
+	// no interval: only the final checkpoint is written
+	records := dryRunRecords(t, newTestProvider([]float64{0, 1, 2, 3, 4}, nil, nil),
+		WithContext(ctx),
+		WithTransformer(stop),
+		WithCheckpoint(filename, 0, &Checkpoint{}))
+	cp := readTestCheckpoint(t, filename)
+	if cp.Completed {
+		t.Errorf("Completed = true, want false")
+	}
+	var last time.Time
+	for _, rec := range records {
+		if rec.Type == RecordState {
+			last = *rec.DataTS
+		}
+	}
+	if got := cp.Providers[StateData]; !got.LastTS.Equal(last) || got.Count != 1 {
+		t.Errorf("checkpoint = %+v, want last published state %v", got, last)
+	}
+}
+
+// verifies a resumed replay uses the retime offset of the interrupted replay
+func Test_checkpointRetime(t *testing.T) {
+	dir := t.TempDir()
+	first := filepath.Join(dir, "first.checkpoint")
+	dryRunRecords(t, newTestProvider([]float64{0, 1}, nil, nil),
+		WithTransformer(RetimeTransformer()),
+		WithCheckpoint(first, 0, &Checkpoint{}))
+	cp := readTestCheckpoint(t, first)
+	if cp.RetimeOffset == nil {
+		t.Fatalf("RetimeOffset = nil, want offset of first replay")
+	}
+	offset := *cp.RetimeOffset
+
+	second := filepath.Join(dir, "second.checkpoint")
+	records := dryRunRecords(t, newTestProvider([]float64{0, 1, 2, 3}, nil, nil),
+		WithTransformer(RetimeTransformer()),
+		WithResume(cp),
+		WithCheckpoint(second, 0, &Checkpoint{}))
+	want := []time.Time{}
+	for _, sec := range []float64{2, 3} {
+		want = append(want, testTime(sec).AsTime().Add(offset))
+	}
+	got := []time.Time{}
+	for _, rec := range records {
+		if rec.Type == RecordState {
+			got = append(got, *rec.DataTS)
+		}
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d states, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if !got[i].Equal(want[i]) {
+			t.Errorf("state %d at %v, want %v", i, got[i], want[i])
+		}
+	}
+	if cp := readTestCheckpoint(t, second); cp.RetimeOffset == nil ||
+		*cp.RetimeOffset != offset {
+		t.Errorf("RetimeOffset = %v, want %v", cp.RetimeOffset, offset)
+	}
+}
+
+// verifies a resumed replay uses the generic names of the interrupted replay
+func Test_checkpointAnonymize(t *testing.T) {
+	newProvider := func() *memDataProvider {
+		provider := newTestProvider(nil, nil, nil)
+		provider.states = []*racestatev1.PublishStateRequest{
+			messageState(0, "Alice Real entered the pits"),
+			messageState(7, "Bob Real entered the pits"),
+			messageState(10, "Bob Real passed Carol Real"),
+		}
+		// Carol is listed before Bob once she joins
+		provider.drivers = []*racestatev1.PublishDriverDataRequest{
+			namedDriver(0, "Alice Real"),
+			namedDriver(5, "Alice Real", "Bob Real"),
+			namedDriver(10, "Alice Real", "Carol Real", "Bob Real"),
+		}
+		return provider
+	}
+	dir := t.TempDir()
+	first := filepath.Join(dir, "first.checkpoint")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stop := &Transformer{
+		State: func(req *racestatev1.PublishStateRequest) {
+			if req.Session.SessionTime == 7 {
+				cancel()
+			}
+		},
+	}
+	dryRunRecords(t, newProvider(),
+		WithContext(ctx),
+		WithTransformer(AnonymizeTransformer(), stop),
+		WithCheckpoint(first, 0, &Checkpoint{}))
+	cp := readTestCheckpoint(t, first)
+	if cp.AnonymizedDrivers["Alice Real"] != 1 || cp.AnonymizedDrivers["Bob Real"] != 2 {
+		t.Fatalf("AnonymizedDrivers = %v, want Alice 1, Bob 2", cp.AnonymizedDrivers)
+	}
+
+	got := []string{}
+	recorder := &Transformer{
+		State: func(req *racestatev1.PublishStateRequest) {
+			for _, m := range req.Messages {
+				got = append(got, m.Msg)
+			}
+		},
+	}
+	dryRunRecords(t, newProvider(),
+		WithTransformer(AnonymizeTransformer(), recorder),
+		WithResume(cp))
+	if len(got) == 0 || got[len(got)-1] != "Driver 2 passed Driver 3" {
+		t.Errorf("messages = %q, want last %q", got, "Driver 2 passed Driver 3")
+	}
+}
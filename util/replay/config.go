@@ -1,27 +1,196 @@
 package replay
 
-import "time"
+import (
+	"fmt"
+	"time"
+
+	"google.golang.org/grpc"
+)
+
+// source modes for loading the data from the source server
+const (
+	SourceModeStream = "stream" // use server-side streaming calls
+	SourceModePage   = "page"   // request the data page by page
+)
 
 type Config struct {
-	Speed          int
-	SourceAddr     string // grpc server address providing the data
-	SourceInsecure bool   // connect to gRPC server without TLS
+	Speed          float64 // speed multiplier (values < 1 slow down the replay)
+	SourceAddr     string  // grpc server address providing the data
+	SourceInsecure bool    // connect to gRPC server without TLS
+	SourceFile     string  // replay file providing the data (instead of SourceAddr)
 	Token          string
 	EventKey       string
 	DoNotPersist   bool
 	FastForward    time.Duration
 	FFPreRace      bool
+	CatchUpLimit   time.Duration // see WithCatchUpLimit
+	// session window (see SessionWindow)
+	FromSessionTime *time.Duration // nil: use the event's ReplayInfo
+	ToSessionTime   *time.Duration // nil: use the event's ReplayInfo
+	SessionNum      int            // -1: use race session
+	// filters (see SessionFilter, CarFilterTransformer)
+	SessionTypes []string // practice, qualify, race
+	SessionNums  []uint
+	CarNums      []string
+	CarClasses   []string
+	// fault injection (see Chaos)
+	ChaosSpec string
+	ChaosSeed int64 // 0: random seed
+	// checkpoints (see Checkpoint)
+	Checkpoint         string // file to write the checkpoint to
+	CheckpointInterval time.Duration
+	Resume             string // checkpoint file of the replay to resume
+	DryRun             string // file to write the requests to instead of sending them
+	// error policy (see ErrorPolicy)
+	MaxRetries      int
+	RetryBackoff    time.Duration
+	RetryMaxBackoff time.Duration
+	MaxFailures     int
+	// source data fetching (see DataProviderOption)
+	SourceMode   string // see SourceModeStream, SourceModePage
+	PageSize     int
+	Prefetch     bool
+	FetchRetries int
+	// transformers (see Transformer)
+	Anonymize bool
+	Retime    bool
+	EventName string
 }
 
 func DefaultConfig() *Config {
 	return &Config{
-		Speed:          1,
+		Speed:          1.0,
 		SourceAddr:     "",
 		SourceInsecure: false,
+		SourceFile:     "",
 		Token:          "",
 		EventKey:       "",
 		DoNotPersist:   false,
 		FastForward:    time.Duration(0),
 		FFPreRace:      true,
+		CatchUpLimit:   time.Duration(0),
+
+		FromSessionTime: nil,
+		ToSessionTime:   nil,
+		SessionNum:      -1,
+
+		SessionTypes: []string{},
+		SessionNums:  []uint{},
+		CarNums:      []string{},
+		CarClasses:   []string{},
+
+		ChaosSpec: "",
+		ChaosSeed: 0,
+
+		Checkpoint:         "",
+		CheckpointInterval: 30 * time.Second,
+		Resume:             "",
+
+		MaxRetries:      5,
+		RetryBackoff:    500 * time.Millisecond,
+		RetryMaxBackoff: 30 * time.Second,
+		MaxFailures:     10,
+
+		SourceMode:   SourceModePage,
+		PageSize:     100,
+		Prefetch:     true,
+		FetchRetries: 3,
+
+		Anonymize: false,
+		Retime:    false,
+		EventName: "",
+	}
+}
+
+// DataProviderOptions creates the options for the source data provider
+func (c *Config) DataProviderOptions() []DataProviderOption {
+	return []DataProviderOption{
+		WithPageSize(c.PageSize),
+		WithPrefetch(c.Prefetch),
+		WithFetchRetries(c.FetchRetries, time.Second),
+	}
+}
+
+// NewDataProvider creates the data provider for the configured source mode
+//
+//nolint:whitespace // by design
+func (c *Config) NewDataProvider(
+	source *grpc.ClientConn,
+	eventID uint32,
+	eventRequestProvider ProvideEventRequest,
+) (ReplayDataProvider, error) {
+	switch c.SourceMode {
+	case SourceModeStream:
+		return NewStreamDataProvider(source, eventID, eventRequestProvider,
+			c.DataProviderOptions()...), nil
+	case SourceModePage:
+		return NewDataProvider(source, eventID, eventRequestProvider,
+			c.DataProviderOptions()...), nil
+	default:
+		return nil, fmt.Errorf("unknown source mode: %s", c.SourceMode)
+	}
+}
+
+// Transformers creates the transformers requested by the config
+func (c *Config) Transformers() []*Transformer {
+	ret := []*Transformer{}
+	// filter first, so other transformers only see the remaining cars
+	if len(c.CarNums) > 0 || len(c.CarClasses) > 0 {
+		ret = append(ret, CarFilterTransformer(c.CarNums, c.CarClasses))
+	}
+	if c.EventName != "" {
+		ret = append(ret, RenameEventTransformer(c.EventName))
+	}
+	if c.Anonymize {
+		ret = append(ret, AnonymizeTransformer())
+	}
+	if c.Retime {
+		ret = append(ret, RetimeTransformer())
+	}
+	return ret
+}
+
+// SessionFilter creates the session filter from the config values.
+// Returns nil if no session filter is configured.
+func (c *Config) SessionFilter() (*SessionFilter, error) {
+	if len(c.SessionTypes) == 0 && len(c.SessionNums) == 0 {
+		return nil, nil
 	}
+	ret := &SessionFilter{}
+	for _, name := range c.SessionTypes {
+		t, err := ParseSessionType(name)
+		if err != nil {
+			return nil, err
+		}
+		ret.Types = append(ret.Types, t)
+	}
+	for _, num := range c.SessionNums {
+		ret.Nums = append(ret.Nums, uint32(num)) //nolint:gosec // session nums are small
+	}
+	return ret, nil
+}
+
+// NewChaos creates the fault injection from the config values.
+// The offset is added to the seed, so that parallel replays get different
+// but reproducible faults. Without a configured seed a random one is used.
+// Returns nil if no fault injection is configured.
+func (c *Config) NewChaos(seedOffset int64) (*Chaos, error) {
+	if c.ChaosSpec == "" {
+		return nil, nil
+	}
+	seed := c.ChaosSeed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	return ParseChaos(c.ChaosSpec, seed+seedOffset)
+}
+
+// ErrorPolicy creates the error policy from the config values
+func (c *Config) ErrorPolicy() *ErrorPolicy {
+	ret := DefaultErrorPolicy()
+	ret.MaxRetries = c.MaxRetries
+	ret.InitialBackoff = c.RetryBackoff
+	ret.MaxBackoff = c.RetryMaxBackoff
+	ret.MaxConsecutiveFailures = c.MaxFailures
+	return ret
 }
@@ -0,0 +1,142 @@
+package replay
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/mpapenbr/iracelog-cli/log"
+)
+
+// The methods in this file may be called from other goroutines while
+// the replay is running.
+
+var (
+	ErrSeekBackwards = errors.New("cannot seek backwards")
+	ErrInvalidSpeed  = errors.New("speed must be a number >= 0")
+)
+
+// ValidateSpeed checks a speed for WithSpeed and SetSpeed
+func ValidateSpeed(speed float64) error {
+	if math.IsNaN(speed) || math.IsInf(speed, 0) || speed < 0 {
+		return fmt.Errorf("%w: %g", ErrInvalidSpeed, speed)
+	}
+	return nil
+}
+
+// Pause suspends sending data until Resume is called
+func (r *ReplayTask) Pause() {
+	r.ctrlMu.Lock()
+	defer r.ctrlMu.Unlock()
+	if !r.paused {
+		r.paused = true
+		r.pausedAt = r.now()
+		r.myLog.Info("replay paused")
+		r.notifyControlChange()
+	}
+}
+
+// Resume continues a paused replay
+func (r *ReplayTask) Resume() {
+	r.ctrlMu.Lock()
+	defer r.ctrlMu.Unlock()
+	if r.paused {
+		r.paused = false
+		r.sched.shift(r.now().Sub(r.pausedAt))
+		r.myLog.Info("replay resumed")
+		r.notifyControlChange()
+	}
+}
+
+func (r *ReplayTask) IsPaused() bool {
+	r.ctrlMu.Lock()
+	defer r.ctrlMu.Unlock()
+	return r.paused
+}
+
+// SetSpeed changes the replay speed. 0 means: go as fast as possible.
+// An error is returned if the speed is invalid (see ValidateSpeed).
+func (r *ReplayTask) SetSpeed(speed float64) error {
+	if err := ValidateSpeed(speed); err != nil {
+		return err
+	}
+	r.ctrlMu.Lock()
+	defer r.ctrlMu.Unlock()
+	r.speed = speed
+	// while paused the position doesn't move, Resume skips the paused time
+	at := r.now()
+	if r.paused {
+		at = r.pausedAt
+	}
+	r.sched.changeSpeed(at, speed)
+	r.myLog.Info("replay speed changed", log.Float64("speed", speed))
+	r.notifyControlChange()
+	return nil
+}
+
+func (r *ReplayTask) Speed() float64 {
+	r.ctrlMu.Lock()
+	defer r.ctrlMu.Unlock()
+	return r.speed
+}
+
+// SeekTo publishes all data up to the given session time (in seconds) of the
+// current session without waiting. Data is still sent to the server, so the
+// server state stays consistent. Seeking stops when the session changes.
+// Since data is consumed as a stream, seeking backwards is not supported.
+func (r *ReplayTask) SeekTo(sessionTime float64) error {
+	r.ctrlMu.Lock()
+	defer r.ctrlMu.Unlock()
+	if sessionTime < r.curSessionTime {
+		return ErrSeekBackwards
+	}
+	r.seeking = true
+	r.seekTime = sessionTime
+	r.seekSessionNum = r.curSessionNum
+	r.myLog.Info("seeking",
+		log.Uint32("sessionNum", r.curSessionNum),
+		log.Float64("sessionTime", sessionTime))
+	r.notifyControlChange()
+	return nil
+}
+
+// Position returns the session num and session time of the last published data
+//
+//nolint:whitespace // by design
+func (r *ReplayTask) Position() (
+	sessionNum uint32, sessionTime float64,
+) {
+	r.ctrlMu.Lock()
+	defer r.ctrlMu.Unlock()
+	return r.curSessionNum, r.curSessionTime
+}
+
+// notifyControlChange wakes up sendData if it is waiting.
+// must be called with ctrlMu held
+func (r *ReplayTask) notifyControlChange() {
+	close(r.ctrlChanged)
+	r.ctrlChanged = make(chan struct{})
+}
+
+// controlChanged returns the channel which is closed on the next control change
+func (r *ReplayTask) controlChanged() <-chan struct{} {
+	r.ctrlMu.Lock()
+	defer r.ctrlMu.Unlock()
+	return r.ctrlChanged
+}
+
+// updatePosition records the position of the data just published.
+// Seeking is finished once the target is reached or the session changed.
+func (r *ReplayTask) updatePosition(s *stampInfo) {
+	r.ctrlMu.Lock()
+	defer r.ctrlMu.Unlock()
+	r.curSessionNum = s.sessionNum
+	r.curSessionTime = s.sessionTime
+	if r.seeking &&
+		(s.sessionNum != r.seekSessionNum || s.sessionTime >= r.seekTime) {
+		r.seeking = false
+		r.myLog.Info("seek done",
+			log.Uint32("sessionNum", s.sessionNum),
+			log.Float64("sessionTime", s.sessionTime))
+	}
+}
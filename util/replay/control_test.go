@@ -0,0 +1,106 @@
+package replay
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"testing"
+	"time"
+)
+
+// verifies the position of the schedule after pause, speed change and resume
+func Test_controlSchedule(t *testing.T) {
+	ts := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	sec := func(v float64) time.Duration { return time.Duration(v * float64(time.Second)) }
+	tests := []struct {
+		name     string
+		running  time.Duration // replay time before the pause
+		paused   time.Duration // 0: not paused
+		speed    float64       // new speed, 0: unchanged
+		wantPos  time.Duration // position relative to ts after resume
+		wantRate float64       // speed of the schedule after resume
+	}{
+		{name: "pause", running: sec(10), paused: sec(5), wantPos: sec(10), wantRate: 1},
+		{
+			name: "speed change while paused", running: sec(10), paused: sec(5),
+			speed: 2, wantPos: sec(10), wantRate: 2,
+		},
+		{
+			name: "speed change while running", running: sec(10),
+			speed: 0.5, wantPos: sec(10), wantRate: 0.5,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewReplayTask(nil, nil)
+			now := time.Now()
+			r.sched = schedule{wall: now.Add(-tt.running - tt.paused), ts: ts, speed: 1}
+			if tt.paused > 0 {
+				r.Pause()
+				r.pausedAt = now.Add(-tt.paused)
+			}
+			if tt.speed > 0 {
+				if err := r.SetSpeed(tt.speed); err != nil {
+					t.Fatalf("SetSpeed() error = %v", err)
+				}
+			}
+			r.Resume()
+			now = time.Now()
+			if got := r.sched.position(now).Sub(ts); (got - tt.wantPos).Abs() > sec(0.1) {
+				t.Errorf("position = %v, want %v", got, tt.wantPos)
+			}
+			if r.sched.speed != tt.wantRate {
+				t.Errorf("speed = %v, want %v", r.sched.speed, tt.wantRate)
+			}
+		})
+	}
+}
+
+// verifies that pause and speed changes use the virtual clock of a dry-run
+func Test_controlDryRunClock(t *testing.T) {
+	ts := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	r := NewReplayTask(nil, nil, WithDryRun(&bytes.Buffer{}, testWallStart))
+	r.sched = schedule{wall: testWallStart, ts: ts, speed: 1}
+	r.dryRun.advance(testWallStart.Add(10 * time.Second))
+	r.Pause()
+	r.dryRun.advance(testWallStart.Add(15 * time.Second))
+	if err := r.SetSpeed(2); err != nil {
+		t.Fatalf("SetSpeed() error = %v", err)
+	}
+	r.Resume()
+	// the paused virtual time is skipped, the wall clock isn't used
+	got := r.sched.position(testWallStart.Add(17 * time.Second)).Sub(ts)
+	if want := 14 * time.Second; got != want {
+		t.Errorf("position = %v, want %v", got, want)
+	}
+}
+
+func Test_SetSpeedInvalid(t *testing.T) {
+	r := NewReplayTask(nil, nil)
+	if err := r.SetSpeed(-1); !errors.Is(err, ErrInvalidSpeed) {
+		t.Errorf("SetSpeed(-1) error = %v, want %v", err, ErrInvalidSpeed)
+	}
+	if r.Speed() != 1 {
+		t.Errorf("Speed() = %v, want 1", r.Speed())
+	}
+}
+
+func Test_ValidateSpeed(t *testing.T) {
+	tests := []struct {
+		speed   float64
+		wantErr bool
+	}{
+		{speed: 1},
+		{speed: 0.5},
+		{speed: 0},
+		{speed: -1, wantErr: true},
+		{speed: math.NaN(), wantErr: true},
+		{speed: math.Inf(1), wantErr: true},
+	}
+	for _, tt := range tests {
+		err := ValidateSpeed(tt.speed)
+		if (err != nil) != tt.wantErr || (err != nil && !errors.Is(err, ErrInvalidSpeed)) {
+			t.Errorf("ValidateSpeed(%g) error = %v, wantErr %v", tt.speed, err, tt.wantErr)
+		}
+	}
+}
@@ -2,6 +2,7 @@ package replay
 
 import (
 	"context"
+	"sync"
 	"time"
 
 	"buf.build/gen/go/mpapenbr/iracelog/grpc/go/iracelog/racestate/v1/racestatev1grpc"
@@ -17,18 +18,16 @@ func initDriverDataFetcher(
 	service racestatev1grpc.RaceStateServiceClient,
 	logger *log.Logger,
 	eventID uint32,
-	lastTS time.Time,
-	limit int,
+	cfg *fetchConfig,
 ) myFetcher[racestatev1.PublishDriverDataRequest] {
 	df := &commonFetcher[racestatev1.PublishDriverDataRequest]{
-		lastTS: lastTS,
-
-		loader: func(startTs time.Time) ([]*racestatev1.PublishDriverDataRequest, time.Time, error) {
-			if resp, err := service.GetDriverData(context.Background(),
+		cfg: cfg,
+		loader: func(ctx context.Context, startTs time.Time) ([]*racestatev1.PublishDriverDataRequest, time.Time, error) {
+			if resp, err := service.GetDriverData(ctx,
 				&racestatev1.GetDriverDataRequest{
 					Event: buildEventSelector(eventID),
 					Start: buildStartSelector(startTs),
-					Num:   int32(limit),
+					Num:   int32(cfg.pageSize),
 				}); err == nil {
 				logger.Debug("loaded driver data",
 					log.Int("count", len(resp.DriverData)),
@@ -38,7 +37,7 @@ func initDriverDataFetcher(
 				return resp.DriverData, resp.LastTs.AsTime().Add(time.Millisecond), nil
 			} else {
 				logger.Error("failed to load driver data", log.ErrorField(err))
-				return nil, lastTS, err
+				return nil, startTs, err
 			}
 		},
 	}
@@ -50,17 +49,16 @@ func initStateDataFetcher(
 	service racestatev1grpc.RaceStateServiceClient,
 	logger *log.Logger,
 	eventID uint32,
-	lastTS time.Time,
-	limit int,
+	cfg *fetchConfig,
 ) myFetcher[racestatev1.PublishStateRequest] {
 	df := &commonFetcher[racestatev1.PublishStateRequest]{
-		lastTS: lastTS,
-		loader: func(startTs time.Time) ([]*racestatev1.PublishStateRequest, time.Time, error) {
-			if resp, err := service.GetStates(context.Background(),
+		cfg: cfg,
+		loader: func(ctx context.Context, startTs time.Time) ([]*racestatev1.PublishStateRequest, time.Time, error) {
+			if resp, err := service.GetStates(ctx,
 				&racestatev1.GetStatesRequest{
 					Event: buildEventSelector(eventID),
 					Start: buildStartSelector(startTs),
-					Num:   int32(limit),
+					Num:   int32(cfg.pageSize),
 				}); err == nil {
 				logger.Debug("loaded state data",
 					log.Int("count", len(resp.States)),
@@ -70,7 +68,7 @@ func initStateDataFetcher(
 				return resp.States, resp.LastTs.AsTime().Add(time.Millisecond), nil
 			} else {
 				logger.Error("failed to load state data", log.ErrorField(err))
-				return nil, lastTS, err
+				return nil, startTs, err
 			}
 		},
 	}
@@ -83,17 +81,16 @@ func initSpeedmapDataFetcher(
 	service racestatev1grpc.RaceStateServiceClient,
 	logger *log.Logger,
 	eventID uint32,
-	lastTS time.Time,
-	limit int,
+	cfg *fetchConfig,
 ) myFetcher[racestatev1.PublishSpeedmapRequest] {
 	df := &commonFetcher[racestatev1.PublishSpeedmapRequest]{
-		lastTS: lastTS,
-		loader: func(startTs time.Time) ([]*racestatev1.PublishSpeedmapRequest, time.Time, error) {
-			if resp, err := service.GetSpeedmaps(context.Background(),
+		cfg: cfg,
+		loader: func(ctx context.Context, startTs time.Time) ([]*racestatev1.PublishSpeedmapRequest, time.Time, error) {
+			if resp, err := service.GetSpeedmaps(ctx,
 				&racestatev1.GetSpeedmapsRequest{
 					Event: buildEventSelector(eventID),
 					Start: buildStartSelector(startTs),
-					Num:   int32(limit),
+					Num:   int32(cfg.pageSize),
 				}); err == nil {
 				logger.Debug("loaded speedmap data",
 					log.Int("count", len(resp.Speedmaps)),
@@ -103,7 +100,7 @@ func initSpeedmapDataFetcher(
 				return resp.Speedmaps, resp.LastTs.AsTime().Add(time.Millisecond), nil
 			} else {
 				logger.Error("failed to load speedmap data", log.ErrorField(err))
-				return nil, lastTS, err
+				return nil, startTs, err
 			}
 		},
 	}
@@ -123,24 +120,42 @@ func buildStartSelector(t time.Time) *commonv1.StartSelector {
 
 type myFetcher[E any] interface {
 	next() *E
+	err() error // error which caused next() to return nil (if any)
 }
 
 type (
-	myLoaderFunc[E any] func(startTS time.Time) ([]*E, time.Time, error)
+	myLoaderFunc[E any] func(ctx context.Context, startTS time.Time) ([]*E, time.Time, error)
 	mapToSessionType    func(sessionNum uint32) commonv1.SessionType
 )
 
-//nolint:unused // false positive
+// fetchConfig controls how data is loaded from the source server
+type fetchConfig struct {
+	ctx          context.Context
+	pageSize     int
+	prefetch     bool          // load the next page in background
+	retries      int           // retries for a failed page
+	retryBackoff time.Duration // doubled on each retry
+}
+
+type page[E any] struct {
+	items []*E
+	err   error
+}
+
 type commonFetcher[E any] struct {
 	loader             myLoaderFunc[E]
+	cfg                *fetchConfig
 	buffer             []*E
 	lastTS             time.Time
 	resolveSessionType mapToSessionType
+	pages              chan page[E] // prefetched pages
+	done               bool
+	mu                 sync.Mutex
+	lastErr            error
 }
 
-//nolint:unused // false positive
 func (f *commonFetcher[E]) next() *E {
-	if len(f.buffer) == 0 {
+	if len(f.buffer) == 0 && !f.done {
 		f.fetch()
 	}
 	if len(f.buffer) == 0 {
@@ -152,7 +167,73 @@ func (f *commonFetcher[E]) next() *E {
 	return ret
 }
 
-//nolint:unused // false positive
+func (f *commonFetcher[E]) err() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.lastErr
+}
+
 func (f *commonFetcher[E]) fetch() {
-	f.buffer, f.lastTS, _ = f.loader(f.lastTS)
+	var p page[E]
+	if f.cfg.prefetch {
+		if f.pages == nil {
+			f.pages = make(chan page[E], 1)
+			go f.prefetch()
+		}
+		var ok bool
+		if p, ok = <-f.pages; !ok {
+			f.done = true
+			return
+		}
+	} else {
+		p.items, p.err = f.load()
+	}
+	f.buffer = p.items
+	if p.err != nil {
+		f.mu.Lock()
+		f.lastErr = p.err
+		f.mu.Unlock()
+	}
+	if p.err != nil || len(p.items) == 0 {
+		f.done = true
+	}
+}
+
+// prefetch loads the pages in background. There is always one page ahead.
+func (f *commonFetcher[E]) prefetch() {
+	defer close(f.pages)
+	for {
+		items, err := f.load()
+		select {
+		case f.pages <- page[E]{items: items, err: err}:
+		case <-f.cfg.ctx.Done():
+			return
+		}
+		if err != nil || len(items) == 0 {
+			return
+		}
+	}
+}
+
+// load loads the next page, failed requests are retried
+func (f *commonFetcher[E]) load() ([]*E, error) {
+	backoff := f.cfg.retryBackoff
+	for attempt := 0; ; attempt++ {
+		items, lastTS, err := f.loader(f.cfg.ctx, f.lastTS)
+		if err == nil {
+			f.lastTS = lastTS
+			return items, nil
+		}
+		if attempt >= f.cfg.retries || f.cfg.ctx.Err() != nil {
+			return nil, err
+		}
+		timer := time.NewTimer(backoff)
+		select {
+		case <-timer.C:
+		case <-f.cfg.ctx.Done():
+			timer.Stop()
+			return nil, err
+		}
+		backoff *= 2
+	}
 }
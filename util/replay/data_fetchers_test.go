@@ -0,0 +1,163 @@
+package replay
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+// loadResult is the result of a single loader call
+type loadResult struct {
+	items []int
+	err   error
+}
+
+// scriptedLoader returns the results in order, afterwards empty pages.
+// The start timestamps of the calls are recorded.
+type scriptedLoader struct {
+	results []loadResult
+	starts  []time.Time
+}
+
+//nolint:whitespace // by design
+func (l *scriptedLoader) load(
+	_ context.Context,
+	startTS time.Time,
+) ([]*int, time.Time, error) {
+	l.starts = append(l.starts, startTS)
+	call := len(l.starts)
+	if call > len(l.results) {
+		return nil, startTS, nil
+	}
+	res := l.results[call-1]
+	if res.err != nil {
+		return nil, startTS, res.err
+	}
+	items := make([]*int, len(res.items))
+	for i := range res.items {
+		items[i] = &res.items[i]
+	}
+	return items, time.Unix(int64(call), 0), nil
+}
+
+//nolint:funlen // table
+func Test_commonFetcher(t *testing.T) {
+	errLoad := errors.New("load failed")
+	tests := []struct {
+		name       string
+		retries    int
+		results    []loadResult
+		want       []int
+		wantErr    error
+		wantStarts []int64 // unix seconds of the loader calls
+	}{
+		{
+			name: "pages",
+			results: []loadResult{
+				{items: []int{1, 2}},
+				{items: []int{3}},
+			},
+			want:       []int{1, 2, 3},
+			wantStarts: []int64{0, 1, 2},
+		},
+		{
+			name:    "retry succeeds",
+			retries: 2,
+			results: []loadResult{
+				{items: []int{1}},
+				{err: errLoad},
+				{err: errLoad},
+				{items: []int{2}},
+			},
+			want:       []int{1, 2},
+			wantStarts: []int64{0, 1, 1, 1, 4},
+		},
+		{
+			name:    "retries exhausted",
+			retries: 1,
+			results: []loadResult{
+				{items: []int{1}},
+				{err: errLoad},
+				{err: errLoad},
+				{items: []int{2}},
+			},
+			want:       []int{1},
+			wantErr:    errLoad,
+			wantStarts: []int64{0, 1, 1},
+		},
+		{
+			name:       "no retries",
+			results:    []loadResult{{err: errLoad}},
+			want:       []int{},
+			wantErr:    errLoad,
+			wantStarts: []int64{0},
+		},
+	}
+	for _, tt := range tests {
+		for _, prefetch := range []bool{false, true} {
+			name := tt.name
+			if prefetch {
+				name += " (prefetch)"
+			}
+			t.Run(name, func(t *testing.T) {
+				l := &scriptedLoader{results: tt.results}
+				f := &commonFetcher[int]{
+					loader: l.load,
+					lastTS: time.Unix(0, 0),
+					cfg: &fetchConfig{
+						ctx:          context.Background(),
+						prefetch:     prefetch,
+						retries:      tt.retries,
+						retryBackoff: time.Millisecond,
+					},
+				}
+				got := []int{}
+				for item := f.next(); item != nil; item = f.next() {
+					got = append(got, *item)
+				}
+				if !slices.Equal(got, tt.want) {
+					t.Errorf("next() = %v, want %v", got, tt.want)
+				}
+				if err := f.err(); !errors.Is(err, tt.wantErr) {
+					t.Errorf("err() = %v, want %v", err, tt.wantErr)
+				}
+				if f.next() != nil {
+					t.Errorf("next() after end returned data")
+				}
+				starts := []int64{}
+				for _, s := range l.starts {
+					starts = append(starts, s.Unix())
+				}
+				if !slices.Equal(starts, tt.wantStarts) {
+					t.Errorf("loader starts = %v, want %v", starts, tt.wantStarts)
+				}
+			})
+		}
+	}
+}
+
+// verifies that a canceled context stops the retries
+func Test_commonFetcherCanceled(t *testing.T) {
+	errLoad := errors.New("load failed")
+	ctx, cancel := context.WithCancel(context.Background())
+	calls := 0
+	f := &commonFetcher[int]{
+		loader: func(context.Context, time.Time) ([]*int, time.Time, error) {
+			calls++
+			cancel()
+			return nil, time.Time{}, errLoad
+		},
+		cfg: &fetchConfig{ctx: ctx, retries: 5, retryBackoff: time.Hour},
+	}
+	if item := f.next(); item != nil {
+		t.Errorf("next() = %v, want nil", *item)
+	}
+	if err := f.err(); !errors.Is(err, errLoad) {
+		t.Errorf("err() = %v, want %v", err, errLoad)
+	}
+	if calls != 1 {
+		t.Errorf("loader calls = %d, want 1", calls)
+	}
+}
@@ -0,0 +1,273 @@
+package replay
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	providerv1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/provider/v1"
+	racestatev1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/racestate/v1"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/mpapenbr/iracelog-cli/log"
+)
+
+// Replay files are NDJSON files. Each line contains one record.
+// The data of a record is the protojson representation of the message
+// denoted by the record type.
+// Files ending with .gz are read as gzip compressed files.
+//
+// Example:
+//
+//	{"type":"register","data":{"key":"...","event":{...},"track":{...}}}
+//	{"type":"state","data":{"timestamp":"...","session":{...},"cars":[...]}}
+//	{"type":"driver","data":{...}}
+//	{"type":"speedmap","data":{...}}
+type (
+	recordType string
+	fileRecord struct {
+		Type   recordType      `json:"type"`
+		TS     *time.Time      `json:"ts,omitempty"`     // send time (see dryrun.go)
+		DataTS *time.Time      `json:"dataTs,omitempty"` // data time (see dryrun.go)
+		Data   json.RawMessage `json:"data"`
+	}
+	// protoPtr is used to create new proto messages of type E
+	protoPtr[E any] interface {
+		*E
+		proto.Message
+	}
+)
+
+const (
+	RecordRegister recordType = "register"
+	RecordState    recordType = "state"
+	RecordSpeedmap recordType = "speedmap"
+	RecordDriver   recordType = "driver"
+)
+
+// max size of a single line in a replay file
+const maxRecordSize = 64 * 1024 * 1024
+
+var ErrNoRegisterRecord = errors.New("no register record found")
+
+// ReadEventFromFile returns the register request stored in the replay file
+func ReadEventFromFile(filename string) (*providerv1.RegisterEventRequest, error) {
+	f, err := newRecordReader(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	for {
+		rec, err := f.next()
+		if errors.Is(err, io.EOF) {
+			return nil, ErrNoRegisterRecord
+		}
+		if err != nil {
+			return nil, err
+		}
+		if rec.Type == RecordRegister {
+			ret := &providerv1.RegisterEventRequest{}
+			if err := unmarshalRecord(rec, ret); err != nil {
+				return nil, err
+			}
+			return ret, nil
+		}
+	}
+}
+
+// NewFileDataProvider creates a ReplayDataProvider which reads the data from
+// a replay file instead of a source server.
+// The event information is taken from eventRequestProvider.
+//
+//nolint:whitespace // by design
+func NewFileDataProvider(
+	filename string,
+	eventRequestProvider ProvideEventRequest,
+) (ReplayDataProvider, error) {
+	// fail early if the file is not usable
+	f, err := newRecordReader(filename)
+	if err != nil {
+		return nil, err
+	}
+	f.Close()
+
+	getLogger := func(name string) *log.Logger {
+		return log.Default().Named("replay").Named(name)
+	}
+	eventReq := eventRequestProvider()
+	states := newFileFetcher[racestatev1.PublishStateRequest](
+		filename, RecordState, getLogger("state"))
+	speedmaps := newFileFetcher[racestatev1.PublishSpeedmapRequest](
+		filename, RecordSpeedmap, getLogger("speedmap"))
+	drivers := newFileFetcher[racestatev1.PublishDriverDataRequest](
+		filename, RecordDriver, getLogger("driver"))
+	ret := &dataProviderImpl{
+		eventRequestProvider: func() *providerv1.RegisterEventRequest {
+			return eventReq
+		},
+		sNumToType: sessionTypeMapper(eventReq),
+		cancel: func() {
+			states.close()
+			speedmaps.close()
+			drivers.close()
+		},
+		stateFetcher:      states,
+		speedmapFetcher:   speedmaps,
+		driverDataFetcher: drivers,
+	}
+	return ret, nil
+}
+
+// fileFetcher reads the records of a single type from a replay file.
+// Each fetcher uses its own reader with its own position in the file.
+// This way the memory usage doesn't depend on how the record types are
+// distributed over the file (for example if there are no speedmap records
+// at all). The price is that the file is read once per record type.
+type fileFetcher[E any, P protoPtr[E]] struct {
+	filename  string
+	recType   recordType
+	logger    *log.Logger
+	reader    *recordReader
+	exhausted bool
+	mu        sync.Mutex
+	lastErr   error
+}
+
+//nolint:whitespace // by design
+func newFileFetcher[E any, P protoPtr[E]](
+	filename string,
+	recType recordType,
+	logger *log.Logger,
+) *fileFetcher[E, P] {
+	return &fileFetcher[E, P]{
+		filename: filename,
+		recType:  recType,
+		logger:   logger,
+	}
+}
+
+func (f *fileFetcher[E, P]) next() *E {
+	if f.exhausted {
+		return nil
+	}
+	if f.reader == nil {
+		var err error
+		if f.reader, err = newRecordReader(f.filename); err != nil {
+			f.logger.Error("could not open replay file", log.ErrorField(err))
+			f.stop(err)
+			return nil
+		}
+	}
+	for {
+		rec, err := f.reader.next()
+		if errors.Is(err, io.EOF) {
+			f.stop(nil)
+			return nil
+		}
+		if err != nil {
+			f.logger.Error("could not read replay file", log.ErrorField(err))
+			f.stop(err)
+			return nil
+		}
+		if rec.Type != f.recType {
+			continue
+		}
+		item := P(new(E))
+		if err := unmarshalRecord(rec, item); err != nil {
+			f.logger.Error("could not unmarshal record",
+				log.String("type", string(rec.Type)),
+				log.ErrorField(err))
+			f.stop(err)
+			return nil
+		}
+		return (*E)(item)
+	}
+}
+
+// stop closes the reader and records the error which stopped reading (if any)
+func (f *fileFetcher[E, P]) stop(err error) {
+	f.exhausted = true
+	f.close()
+	if err != nil {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		f.lastErr = err
+	}
+}
+
+// close releases the file if it wasn't read completely
+func (f *fileFetcher[E, P]) close() {
+	if f.reader != nil {
+		f.reader.Close()
+		f.reader = nil
+	}
+}
+
+func (f *fileFetcher[E, P]) err() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.lastErr
+}
+
+type recordReader struct {
+	file    *os.File
+	gz      *gzip.Reader
+	scanner *bufio.Scanner
+}
+
+func newRecordReader(filename string) (*recordReader, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	ret := &recordReader{file: file}
+	var r io.Reader = file
+	if strings.HasSuffix(filename, ".gz") {
+		if ret.gz, err = gzip.NewReader(file); err != nil {
+			file.Close()
+			return nil, err
+		}
+		r = ret.gz
+	}
+	ret.scanner = bufio.NewScanner(r)
+	ret.scanner.Buffer(make([]byte, 0, 64*1024), maxRecordSize)
+	return ret, nil
+}
+
+func (r *recordReader) next() (*fileRecord, error) {
+	for r.scanner.Scan() {
+		line := r.scanner.Bytes()
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		rec := &fileRecord{}
+		if err := json.Unmarshal(line, rec); err != nil {
+			return nil, fmt.Errorf("invalid record: %w", err)
+		}
+		return rec, nil
+	}
+	if err := r.scanner.Err(); err != nil {
+		return nil, err
+	}
+	return nil, io.EOF
+}
+
+func (r *recordReader) Close() {
+	if r.gz != nil {
+		r.gz.Close()
+	}
+	r.file.Close()
+}
+
+func unmarshalRecord(rec *fileRecord, msg proto.Message) error {
+	return protojson.UnmarshalOptions{DiscardUnknown: true}.Unmarshal(rec.Data, msg)
+}
@@ -0,0 +1,237 @@
+package replay
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	providerv1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/provider/v1"
+	racestatev1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/racestate/v1"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+func recordLine(t *testing.T, rt recordType, msg proto.Message) string {
+	t.Helper()
+	data, err := protojson.Marshal(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	line, err := json.Marshal(&fileRecord{Type: rt, Data: data})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(line)
+}
+
+// writeReplayFile writes the lines to a (gzip compressed) file in a temp dir
+func writeReplayFile(t *testing.T, name string, lines []string) string {
+	t.Helper()
+	filename := filepath.Join(t.TempDir(), name)
+	f, err := os.Create(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	content := []byte(strings.Join(lines, "\n") + "\n")
+	if strings.HasSuffix(name, ".gz") {
+		gz := gzip.NewWriter(f)
+		if _, err := gz.Write(content); err != nil {
+			t.Fatal(err)
+		}
+		if err := gz.Close(); err != nil {
+			t.Fatal(err)
+		}
+		return filename
+	}
+	if _, err := f.Write(content); err != nil {
+		t.Fatal(err)
+	}
+	return filename
+}
+
+// drain returns the session times of the items provided by next
+func drain[E any](next func() *E, sessionTime func(*E) float64) []float64 {
+	ret := []float64{}
+	for item := next(); item != nil; item = next() {
+		ret = append(ret, sessionTime(item))
+	}
+	return ret
+}
+
+//nolint:funlen // table
+func Test_fileDataProvider(t *testing.T) {
+	p := newTestProvider(nil, nil, nil)
+	register := recordLine(t, RecordRegister, p.eventReq)
+	state := func(sec float64) string {
+		return recordLine(t, RecordState, testState(sec))
+	}
+	driver := func(sec float64) string {
+		return recordLine(t, RecordDriver, testDriver(sec))
+	}
+	speedmap := func(sec float64) string {
+		return recordLine(t, RecordSpeedmap, testSpeedmap(sec))
+	}
+	tests := []struct {
+		name      string
+		file      string
+		lines     []string
+		states    []float64
+		drivers   []float64
+		speedmaps []float64
+		wantErr   bool
+	}{
+		{
+			name: "plain",
+			file: "replay.ndjson",
+			lines: []string{
+				register, state(0), driver(0), state(1), speedmap(1), "",
+				state(2), driver(2),
+			},
+			states:    []float64{0, 1, 2},
+			drivers:   []float64{0, 2},
+			speedmaps: []float64{1},
+		},
+		{
+			name:      "gzip",
+			file:      "replay.ndjson.gz",
+			lines:     []string{register, state(0), speedmap(0), driver(1), state(1)},
+			states:    []float64{0, 1},
+			drivers:   []float64{1},
+			speedmaps: []float64{0},
+		},
+		{
+			name:      "no speedmap records",
+			file:      "replay.ndjson",
+			lines:     []string{register, state(0), driver(0), state(1)},
+			states:    []float64{0, 1},
+			drivers:   []float64{0},
+			speedmaps: []float64{},
+		},
+		{
+			name:      "unknown record type",
+			file:      "replay.ndjson",
+			lines:     []string{register, `{"type":"other","data":{}}`, state(0)},
+			states:    []float64{0},
+			drivers:   []float64{},
+			speedmaps: []float64{},
+		},
+		{
+			name:      "invalid line",
+			file:      "replay.ndjson",
+			lines:     []string{register, state(0), driver(0), "{invalid", state(1)},
+			states:    []float64{0},
+			drivers:   []float64{0},
+			speedmaps: []float64{},
+			wantErr:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filename := writeReplayFile(t, tt.file, tt.lines)
+			dp, err := NewFileDataProvider(filename,
+				func() *providerv1.RegisterEventRequest { return p.eventReq })
+			if err != nil {
+				t.Fatalf("NewFileDataProvider() error = %v", err)
+			}
+			defer dp.(interface{ Close() }).Close()
+			// the types are read one after another to show they are independent
+			gotStates := drain(dp.NextStateData,
+				func(s *racestatev1.PublishStateRequest) float64 {
+					return float64(s.Session.SessionTime)
+				})
+			gotDrivers := drain(dp.NextDriverData,
+				func(d *racestatev1.PublishDriverDataRequest) float64 {
+					return float64(d.SessionTime)
+				})
+			gotSpeedmaps := drain(dp.NextSpeedmapData,
+				func(s *racestatev1.PublishSpeedmapRequest) float64 {
+					return s.Timestamp.AsTime().Sub(testStart).Seconds()
+				})
+			if !slices.Equal(gotStates, tt.states) {
+				t.Errorf("states = %v, want %v", gotStates, tt.states)
+			}
+			if !slices.Equal(gotDrivers, tt.drivers) {
+				t.Errorf("drivers = %v, want %v", gotDrivers, tt.drivers)
+			}
+			if !slices.Equal(gotSpeedmaps, tt.speedmaps) {
+				t.Errorf("speedmaps = %v, want %v", gotSpeedmaps, tt.speedmaps)
+			}
+			if err := dp.(ErrorReporter).Err(); (err != nil) != tt.wantErr {
+				t.Errorf("Err() = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_ReadEventFromFile(t *testing.T) {
+	p := newTestProvider(nil, nil, nil)
+	tests := []struct {
+		name    string
+		lines   []string
+		wantKey string
+		wantErr error
+	}{
+		{
+			name: "register record",
+			lines: []string{
+				recordLine(t, RecordState, testState(0)),
+				recordLine(t, RecordRegister, p.eventReq),
+			},
+			wantKey: "test",
+		},
+		{
+			name:    "missing register record",
+			lines:   []string{recordLine(t, RecordState, testState(0))},
+			wantErr: ErrNoRegisterRecord,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ReadEventFromFile(writeReplayFile(t, "replay.ndjson", tt.lines))
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ReadEventFromFile() error = %v, want %v", err, tt.wantErr)
+			}
+			if got.GetKey() != tt.wantKey {
+				t.Errorf("ReadEventFromFile() key = %v, want %v", got.GetKey(), tt.wantKey)
+			}
+		})
+	}
+}
+
+// verifies that a dry-run output can be replayed again with the same result
+func Test_fileDataProviderRoundTrip(t *testing.T) {
+	first := dryRunRecords(t, newTestProvider(
+		[]float64{0, 1, 2}, []float64{0, 2}, []float64{1.5}))
+	lines := []string{}
+	want := []publishedRecord{}
+	for _, rec := range first {
+		line, err := json.Marshal(rec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		lines = append(lines, string(line))
+		dataTS := time.Time{}
+		if rec.DataTS != nil {
+			dataTS = *rec.DataTS
+		}
+		want = append(want, publishedRecord{rec.Type, *rec.TS, dataTS})
+	}
+	filename := writeReplayFile(t, "replay.ndjson", lines)
+	eventReq, err := ReadEventFromFile(filename)
+	if err != nil {
+		t.Fatalf("ReadEventFromFile() error = %v", err)
+	}
+	dp, err := NewFileDataProvider(filename,
+		func() *providerv1.RegisterEventRequest { return eventReq })
+	if err != nil {
+		t.Fatalf("NewFileDataProvider() error = %v", err)
+	}
+	checkRecords(t, dryRunRecords(t, dp), want)
+}
@@ -1,6 +1,7 @@
 package replay
 
 import (
+	"context"
 	"time"
 
 	"buf.build/gen/go/mpapenbr/iracelog/grpc/go/iracelog/racestate/v1/racestatev1grpc"
@@ -14,54 +15,87 @@ import (
 
 type (
 	ProvideEventRequest func() *providerv1.RegisterEventRequest
+	DataProviderOption  func(*fetchConfig)
+	// ErrorReporter is implemented by data providers which may stop providing
+	// data because of an error
+	ErrorReporter interface {
+		Err() error
+	}
 )
 
+// WithPageSize sets the number of items requested per call
+func WithPageSize(size int) DataProviderOption {
+	return func(cfg *fetchConfig) {
+		cfg.pageSize = size
+	}
+}
+
+// WithPrefetch enables loading the next page in background
+func WithPrefetch(arg bool) DataProviderOption {
+	return func(cfg *fetchConfig) {
+		cfg.prefetch = arg
+	}
+}
+
+// WithFetchRetries configures retries for failed requests
+func WithFetchRetries(retries int, backoff time.Duration) DataProviderOption {
+	return func(cfg *fetchConfig) {
+		cfg.retries = retries
+		cfg.retryBackoff = backoff
+	}
+}
+
 //nolint:whitespace // by design
 func NewDataProvider(
 	source *grpc.ClientConn,
 	eventID uint32,
 	eventRequestProvider ProvideEventRequest,
+	opts ...DataProviderOption,
 ) ReplayDataProvider {
 	service := racestatev1grpc.NewRaceStateServiceClient(source)
 	getLogger := func(name string) *log.Logger {
 		return log.Default().Named("replay").Named(name)
 	}
-	eventReq := eventRequestProvider()
-	mapFunc := func(sessionNum uint32) commonv1.SessionType {
-		if sessionNum < uint32(len(eventReq.Event.Sessions)) {
-			return eventReq.Event.Sessions[sessionNum].Type
-		}
-		return commonv1.SessionType_SESSION_TYPE_PRACTICE
-	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cfg := newFetchConfig(ctx, opts...)
 	ret := &dataProviderImpl{
 		source:               source,
 		eventRequestProvider: eventRequestProvider,
-		sNumToType:           mapFunc,
+		sNumToType:           sessionTypeMapper(eventRequestProvider()),
+		cancel:               cancel,
 		stateFetcher: initStateDataFetcher(
-			service,
-			getLogger("state"),
-			eventID,
-			time.Time{},
-			100,
-		),
+			service, getLogger("state"), eventID, cfg),
 		speedmapFetcher: initSpeedmapDataFetcher(
-			service,
-			getLogger("speedmap"),
-			eventID,
-			time.Time{},
-			100,
-		),
+			service, getLogger("speedmap"), eventID, cfg),
 		driverDataFetcher: initDriverDataFetcher(
-			service,
-			getLogger("driver"),
-			eventID,
-			time.Time{},
-			100,
-		),
+			service, getLogger("driver"), eventID, cfg),
 	}
 	return ret
 }
 
+func newFetchConfig(ctx context.Context, opts ...DataProviderOption) *fetchConfig {
+	cfg := &fetchConfig{
+		ctx:          ctx,
+		pageSize:     100,
+		prefetch:     true,
+		retries:      3,
+		retryBackoff: time.Second,
+	}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	return cfg
+}
+
+func sessionTypeMapper(eventReq *providerv1.RegisterEventRequest) mapToSessionType {
+	return func(sessionNum uint32) commonv1.SessionType {
+		if sessionNum < uint32(len(eventReq.Event.Sessions)) {
+			return eventReq.Event.Sessions[sessionNum].Type
+		}
+		return commonv1.SessionType_SESSION_TYPE_PRACTICE
+	}
+}
+
 type dataProviderImpl struct {
 	ReplayDataProvider
 	eventSelector        *commonv1.EventSelector
@@ -71,6 +105,7 @@ type dataProviderImpl struct {
 	speedmapFetcher      myFetcher[racestatev1.PublishSpeedmapRequest]
 	driverDataFetcher    myFetcher[racestatev1.PublishDriverDataRequest]
 	sNumToType           mapToSessionType
+	cancel               context.CancelFunc
 }
 
 //nolint:whitespace // false positive
@@ -117,3 +152,24 @@ func (r *dataProviderImpl) NextSpeedmapData() *racestatev1.PublishSpeedmapReques
 func (r *dataProviderImpl) MapSessionNumToType(sessionNum uint32) commonv1.SessionType {
 	return r.sNumToType(sessionNum)
 }
+
+// Err returns the first error which caused a fetcher to stop
+func (r *dataProviderImpl) Err() error {
+	for _, err := range []error{
+		r.stateFetcher.err(),
+		r.speedmapFetcher.err(),
+		r.driverDataFetcher.err(),
+	} {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close stops background activities of the fetchers
+func (r *dataProviderImpl) Close() {
+	if r.cancel != nil {
+		r.cancel()
+	}
+}
@@ -0,0 +1,282 @@
+package replay
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"time"
+
+	"buf.build/gen/go/mpapenbr/iracelog/grpc/go/iracelog/racestate/v1/racestatev1grpc"
+	racestatev1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/racestate/v1"
+	"google.golang.org/grpc"
+
+	"github.com/mpapenbr/iracelog-cli/log"
+)
+
+// NewStreamDataProvider creates a data provider which uses the server-side
+// streaming calls of the source server. All stored data is delivered exactly
+// once and in order, even if multiple items share the same timestamp.
+// The page size is used as buffer size when prefetch is enabled.
+//
+//nolint:whitespace // by design
+func NewStreamDataProvider(
+	source *grpc.ClientConn,
+	eventID uint32,
+	eventRequestProvider ProvideEventRequest,
+	opts ...DataProviderOption,
+) ReplayDataProvider {
+	service := racestatev1grpc.NewRaceStateServiceClient(source)
+	getLogger := func(name string) *log.Logger {
+		return log.Default().Named("replay").Named(name)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cfg := newFetchConfig(ctx, opts...)
+	return &dataProviderImpl{
+		source:               source,
+		eventRequestProvider: eventRequestProvider,
+		sNumToType:           sessionTypeMapper(eventRequestProvider()),
+		cancel:               cancel,
+		stateFetcher: initStateStreamFetcher(
+			service, getLogger("state"), eventID, cfg),
+		speedmapFetcher: initSpeedmapStreamFetcher(
+			service, getLogger("speedmap"), eventID, cfg),
+		driverDataFetcher: initDriverDataStreamFetcher(
+			service, getLogger("driver"), eventID, cfg),
+	}
+}
+
+//nolint:whitespace,dupl // by design
+func initStateStreamFetcher(
+	service racestatev1grpc.RaceStateServiceClient,
+	logger *log.Logger,
+	eventID uint32,
+	cfg *fetchConfig,
+) myFetcher[racestatev1.PublishStateRequest] {
+	return &streamFetcher[racestatev1.PublishStateRequest]{
+		cfg:    cfg,
+		logger: logger,
+		open: func(ctx context.Context) (
+			streamRecvFunc[racestatev1.PublishStateRequest], error,
+		) {
+			stream, err := service.GetStateStream(ctx,
+				&racestatev1.GetStateStreamRequest{
+					Event: buildEventSelector(eventID),
+					Start: buildStartSelector(time.Time{}),
+				})
+			if err != nil {
+				return nil, err
+			}
+			return func() (*racestatev1.PublishStateRequest, error) {
+				resp, err := stream.Recv()
+				if err != nil {
+					return nil, err
+				}
+				return resp.State, nil
+			}, nil
+		},
+	}
+}
+
+//nolint:whitespace,dupl // by design
+func initSpeedmapStreamFetcher(
+	service racestatev1grpc.RaceStateServiceClient,
+	logger *log.Logger,
+	eventID uint32,
+	cfg *fetchConfig,
+) myFetcher[racestatev1.PublishSpeedmapRequest] {
+	return &streamFetcher[racestatev1.PublishSpeedmapRequest]{
+		cfg:    cfg,
+		logger: logger,
+		open: func(ctx context.Context) (
+			streamRecvFunc[racestatev1.PublishSpeedmapRequest], error,
+		) {
+			stream, err := service.GetSpeedmapStream(ctx,
+				&racestatev1.GetSpeedmapStreamRequest{
+					Event: buildEventSelector(eventID),
+					Start: buildStartSelector(time.Time{}),
+				})
+			if err != nil {
+				return nil, err
+			}
+			return func() (*racestatev1.PublishSpeedmapRequest, error) {
+				resp, err := stream.Recv()
+				if err != nil {
+					return nil, err
+				}
+				return resp.Speedmap, nil
+			}, nil
+		},
+	}
+}
+
+//nolint:whitespace,dupl // by design
+func initDriverDataStreamFetcher(
+	service racestatev1grpc.RaceStateServiceClient,
+	logger *log.Logger,
+	eventID uint32,
+	cfg *fetchConfig,
+) myFetcher[racestatev1.PublishDriverDataRequest] {
+	return &streamFetcher[racestatev1.PublishDriverDataRequest]{
+		cfg:    cfg,
+		logger: logger,
+		open: func(ctx context.Context) (
+			streamRecvFunc[racestatev1.PublishDriverDataRequest], error,
+		) {
+			stream, err := service.GetDriverDataStream(ctx,
+				&racestatev1.GetDriverDataStreamRequest{
+					Event: buildEventSelector(eventID),
+					Start: buildStartSelector(time.Time{}),
+				})
+			if err != nil {
+				return nil, err
+			}
+			return func() (*racestatev1.PublishDriverDataRequest, error) {
+				resp, err := stream.Recv()
+				if err != nil {
+					return nil, err
+				}
+				return resp.DriverData, nil
+			}, nil
+		},
+	}
+}
+
+type (
+	streamRecvFunc[E any] func() (*E, error)
+	streamOpenFunc[E any] func(ctx context.Context) (streamRecvFunc[E], error)
+)
+
+// streamFetcher reads the data from a server-side stream.
+// The stream doesn't deliver the record stamp the server uses for the start
+// selector and the data timestamps may differ from it. So a broken stream is
+// reopened at the start and the items already delivered are skipped.
+// The stored data doesn't change its order, so no item is lost or delivered
+// twice. The price is that each reopen transfers all items delivered so far
+// again, so a break late in a long replay is expensive.
+// Only consecutive failures count for the retries, the retry counter and the
+// backoff are reset as soon as the stream delivers data again.
+type streamFetcher[E any] struct {
+	cfg       *fetchConfig
+	logger    *log.Logger
+	open      streamOpenFunc[E]
+	recv      streamRecvFunc[E] // nil if no stream is open
+	delivered int               // number of items delivered so far
+	skip      int               // items to skip after reopening the stream
+	items     chan *E           // prefetched items
+	done      bool
+	mu        sync.Mutex
+	lastErr   error
+}
+
+func (f *streamFetcher[E]) next() *E {
+	if f.done {
+		return nil
+	}
+	if f.cfg.prefetch {
+		if f.items == nil {
+			f.items = make(chan *E, max(f.cfg.pageSize, 1))
+			go f.prefetch()
+		}
+		item, ok := <-f.items
+		if !ok {
+			f.done = true
+			return nil
+		}
+		return item
+	}
+	item, err := f.receive()
+	if err != nil {
+		f.setErr(err)
+	}
+	if item == nil {
+		f.done = true
+	}
+	return item
+}
+
+func (f *streamFetcher[E]) err() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.lastErr
+}
+
+func (f *streamFetcher[E]) setErr(err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.lastErr = err
+}
+
+// prefetch receives the items in background
+func (f *streamFetcher[E]) prefetch() {
+	defer close(f.items)
+	for {
+		item, err := f.receive()
+		if err != nil {
+			f.setErr(err)
+		}
+		if item == nil {
+			return
+		}
+		select {
+		case f.items <- item:
+		case <-f.cfg.ctx.Done():
+			return
+		}
+	}
+}
+
+// receive returns the next item from the stream. A broken stream is reopened.
+// Returns nil, nil at the end of the stream.
+func (f *streamFetcher[E]) receive() (*E, error) {
+	backoff := f.cfg.retryBackoff
+	attempt := 0
+	for {
+		var err error
+		if f.recv == nil {
+			f.recv, err = f.open(f.cfg.ctx)
+			f.skip = f.delivered
+		}
+		if err == nil {
+			var item *E
+			if item, err = f.recv(); err == nil {
+				if f.skip > 0 {
+					f.skip--
+					continue
+				}
+				// only new items are progress, skipped items were received before
+				attempt, backoff = 0, f.cfg.retryBackoff
+				f.delivered++
+				return item, nil
+			}
+			if errors.Is(err, io.EOF) {
+				return nil, nil
+			}
+		}
+		f.recv = nil
+		if attempt >= f.cfg.retries || f.cfg.ctx.Err() != nil {
+			f.logger.Error("failed to receive data", log.ErrorField(err))
+			return nil, err
+		}
+		f.logger.Warn("stream failed, reopening",
+			log.Int("attempt", attempt+1),
+			log.Int("delivered", f.delivered),
+			log.ErrorField(err))
+		if !f.wait(backoff) {
+			return nil, err
+		}
+		attempt++
+		backoff *= 2
+	}
+}
+
+func (f *streamFetcher[E]) wait(d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-f.cfg.ctx.Done():
+		return false
+	}
+}
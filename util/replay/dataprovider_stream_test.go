@@ -0,0 +1,101 @@
+package replay
+
+import (
+	"cmp"
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	racestatev1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/racestate/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/mpapenbr/iracelog-cli/log"
+)
+
+// verifies that items are delivered exactly once if the stream breaks
+// within a group of items sharing the same timestamp
+func Test_streamFetcherReopen(t *testing.T) {
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	stored := []*racestatev1.PublishStateRequest{}
+	for _, sec := range []int{0, 1, 1, 1, 2, 2, 3} {
+		stored = append(stored, &racestatev1.PublishStateRequest{
+			Timestamp: timestamppb.New(start.Add(time.Duration(sec) * time.Second)),
+		})
+	}
+	tests := []struct {
+		name    string
+		breaks  []int // the n-th opened stream breaks after breaks[n] items
+		retries int   // 0: one retry per break
+		wantErr bool  // the fetcher gives up after the first want items
+		want    int   // 0: all items
+	}{
+		{name: "no break", breaks: nil},
+		{name: "break within same timestamp", breaks: []int{2}},
+		{name: "break at end of same timestamp", breaks: []int{4}},
+		{name: "break while skipping", breaks: []int{3, 1}},
+		{name: "break before first item", breaks: []int{0, 5}},
+		// each reopened stream delivers some data before it breaks again
+		{name: "breaks after progress", breaks: []int{3, 4, 6}, retries: 1},
+		// skipped items are no progress, so the retries are used up
+		{
+			name: "breaks at same position", breaks: []int{3, 3, 3, 3}, retries: 2,
+			wantErr: true, want: 3,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opened := 0
+			f := &streamFetcher[racestatev1.PublishStateRequest]{
+				cfg: &fetchConfig{
+					ctx:          context.Background(),
+					retries:      cmp.Or(tt.retries, len(tt.breaks)),
+					retryBackoff: time.Millisecond,
+				},
+				logger: log.Default(),
+				open: func(context.Context) (
+					streamRecvFunc[racestatev1.PublishStateRequest], error,
+				) {
+					limit := len(stored)
+					if opened < len(tt.breaks) {
+						limit = tt.breaks[opened]
+					}
+					opened++
+					idx := 0
+					return func() (*racestatev1.PublishStateRequest, error) {
+						switch {
+						case idx == len(stored):
+							return nil, io.EOF
+						case idx == limit:
+							return nil, status.Error(codes.Unavailable, "broken")
+						}
+						idx++
+						return stored[idx-1], nil
+					}, nil
+				},
+			}
+			got := []*racestatev1.PublishStateRequest{}
+			for item := f.next(); item != nil; item = f.next() {
+				got = append(got, item)
+			}
+			if err := f.err(); (err != nil) != tt.wantErr {
+				t.Fatalf("err() = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr && opened != tt.retries+1 {
+				t.Errorf("opened %d streams, want %d", opened, tt.retries+1)
+			}
+			want := cmp.Or(tt.want, len(stored))
+			if len(got) != want {
+				t.Fatalf("got %d items, want %d", len(got), want)
+			}
+			for i := range want {
+				if got[i] != stored[i] {
+					t.Errorf("item %d: got %v, want %v",
+						i, got[i].Timestamp.AsTime(), stored[i].Timestamp.AsTime())
+				}
+			}
+		})
+	}
+}
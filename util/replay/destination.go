@@ -0,0 +1,278 @@
+package replay
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"buf.build/gen/go/mpapenbr/iracelog/grpc/go/iracelog/provider/v1/providerv1grpc"
+	"buf.build/gen/go/mpapenbr/iracelog/grpc/go/iracelog/racestate/v1/racestatev1grpc"
+	eventv1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/event/v1"
+	providerv1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/provider/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/mpapenbr/iracelog-cli/log"
+)
+
+var ErrNoDestination = errors.New("no destination configured")
+
+// Destination is an additional server receiving the replayed data
+type Destination struct {
+	Name          string // used for logging (default: connection target)
+	Conn          *grpc.ClientConn
+	TokenProvider func() string
+}
+
+// WithDestinations adds servers which receive the replayed data in addition
+// to the destination passed to NewReplayTask (which may be nil then).
+// All destinations get the same data in the same order and at the same time.
+// The destinations are served in parallel, each by its own goroutine. The
+// replay continues when all destinations received the data, so the slowest
+// destination sets the pace.
+// If a destination fails the error is reported and the replay continues with
+// the other destinations.
+func WithDestinations(dests ...*Destination) ReplayOption {
+	return func(r *ReplayTask) {
+		r.destinations = append(r.destinations, dests...)
+	}
+}
+
+// target holds the state of a destination during the replay
+type target struct {
+	name                string
+	tokenProvider       func() string
+	providerService     providerv1grpc.ProviderServiceClient
+	raceStateService    racestatev1grpc.RaceStateServiceClient
+	event               *eventv1.Event // event registered on this target
+	consecutiveFailures int            // used by the worker only
+	queue               chan func()    // actions processed by the worker
+	workerDone          chan struct{}
+	mu                  sync.Mutex
+	err                 error // target is skipped after an error
+}
+
+func newTarget(d *Destination) *target {
+	name := d.Name
+	if name == "" {
+		name = d.Conn.Target()
+	}
+	return &target{
+		name:             name,
+		tokenProvider:    d.TokenProvider,
+		providerService:  providerv1grpc.NewProviderServiceClient(d.Conn),
+		raceStateService: racestatev1grpc.NewRaceStateServiceClient(d.Conn),
+	}
+}
+
+// helper to add the api-token to the outgoing context
+func (t *target) prepOutgoingContext(ctx context.Context) context.Context {
+	if t.tokenProvider != nil {
+		md := metadata.Pairs("api-token", t.tokenProvider())
+		return metadata.NewOutgoingContext(ctx, md)
+	}
+	return ctx
+}
+
+func (r *ReplayTask) initTargets() {
+	r.targets = make([]*target, 0, len(r.destinations)+1)
+	if r.dest != nil {
+		r.targets = append(r.targets, newTarget(&Destination{
+			Conn:          r.dest,
+			TokenProvider: r.tokenProvider,
+		}))
+	}
+	for _, d := range r.destinations {
+		r.targets = append(r.targets, newTarget(d))
+	}
+	if r.dryRun != nil {
+		r.targets = append(r.targets, r.dryRun.target())
+	}
+}
+
+func (t *target) failed() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.err
+}
+
+func (t *target) setErr(err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.err == nil {
+		t.err = err
+	}
+}
+
+// startTargets starts a worker for each target. The workers process the
+// actions of their target in the order they were queued.
+func (r *ReplayTask) startTargets() {
+	for _, t := range r.targets {
+		t.queue = make(chan func(), 1)
+		t.workerDone = make(chan struct{})
+		go func() {
+			defer close(t.workerDone)
+			for action := range t.queue {
+				action()
+			}
+		}()
+	}
+}
+
+// stopTargets waits until the workers processed all queued actions
+func (r *ReplayTask) stopTargets() {
+	for _, t := range r.targets {
+		close(t.queue)
+	}
+	for _, t := range r.targets {
+		<-t.workerDone
+	}
+}
+
+// syncTargets waits until the workers processed the actions queued so far
+func (r *ReplayTask) syncTargets() {
+	wg := sync.WaitGroup{}
+	for _, t := range r.targets {
+		wg.Add(1)
+		t.queue <- wg.Done
+	}
+	wg.Wait()
+}
+
+func (r *ReplayTask) activeTargets() []*target {
+	ret := make([]*target, 0, len(r.targets))
+	for _, t := range r.targets {
+		if t.failed() == nil {
+			ret = append(ret, t)
+		}
+	}
+	return ret
+}
+
+// skipTarget marks the target as failed. It isn't used from now on.
+func (r *ReplayTask) skipTarget(t *target, action string, err error) {
+	t.setErr(err)
+	if len(r.targets) > 1 && r.localCtx.Err() == nil {
+		r.myLog.Error("Destination failed, skipping it",
+			log.String("destination", t.name),
+			log.String("action", action),
+			log.ErrorField(err))
+	}
+}
+
+// forEachTarget queues f for all active targets and waits until f is done
+// for all of them. A target is skipped from now on if f fails for it.
+// An error is returned if f failed for all targets.
+func (r *ReplayTask) forEachTarget(action string, f func(t *target) error) error {
+	active := r.activeTargets()
+	results := make(chan error, len(active))
+	for _, t := range active {
+		t.queue <- func() {
+			err := f(t)
+			if err != nil {
+				r.skipTarget(t, action, err)
+			}
+			results <- err
+		}
+	}
+	errs := make([]error, 0, len(active))
+	ok := false
+	for range active {
+		if err := <-results; err != nil {
+			errs = append(errs, err)
+		} else {
+			ok = true
+		}
+	}
+	switch {
+	case ok:
+		return nil
+	case len(errs) == 0:
+		return ErrNoDestination
+	case len(errs) == 1:
+		return errs[0]
+	default:
+		return errors.Join(errs...)
+	}
+}
+
+// registerEvents registers the event on all targets.
+// With reattach an event which is still registered on a target is used as is.
+//
+//nolint:whitespace // by design
+func (r *ReplayTask) registerEvents(
+	eventReq *providerv1.RegisterEventRequest,
+	reattach bool,
+) error {
+	errs := r.allTargets(r.activeTargets(), func(t *target) (err error) {
+		t.event, err = r.registerEvent(t, eventReq, reattach)
+		if err != nil {
+			r.skipTarget(t, "register", err)
+		}
+		return err
+	})
+	active := r.activeTargets()
+	if len(active) == 0 {
+		return errors.Join(errs...)
+	}
+	r.event = active[0].event
+	return nil
+}
+
+// unregisterEvents unregisters the event on all targets where it was registered
+func (r *ReplayTask) unregisterEvents() error {
+	registered := make([]*target, 0, len(r.targets))
+	for _, t := range r.targets {
+		if t.event != nil {
+			registered = append(registered, t)
+		}
+	}
+	return errors.Join(r.allTargets(registered, r.unregisterEvent)...)
+}
+
+// allTargets calls f for the targets in parallel and returns the errors
+func (r *ReplayTask) allTargets(targets []*target, f func(t *target) error) []error {
+	errs := make([]error, len(targets))
+	wg := sync.WaitGroup{}
+	for i, t := range targets {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = f(t)
+		}()
+	}
+	wg.Wait()
+	return errs
+}
+
+//nolint:whitespace // by design
+func (r *ReplayTask) registerEvent(
+	t *target,
+	eventReq *providerv1.RegisterEventRequest,
+	reattach bool,
+) (*eventv1.Event, error) {
+	resp, err := t.providerService.RegisterEvent(
+		t.prepOutgoingContext(r.ctx), eventReq)
+	if err == nil {
+		return resp.Event, nil
+	}
+	if reattach && status.Code(err) == codes.AlreadyExists {
+		r.myLog.Info("event still registered, re-attaching",
+			log.String("destination", t.name),
+			log.String("key", eventReq.Key))
+		return eventReq.Event, nil
+	}
+	return nil, err
+}
+
+func (r *ReplayTask) unregisterEvent(t *target) error {
+	req := &providerv1.UnregisterEventRequest{
+		EventSelector: r.buildEventSelector(),
+	}
+	_, err := t.providerService.UnregisterEvent(
+		t.prepOutgoingContext(context.Background()),
+		req)
+	return err
+}
@@ -0,0 +1,104 @@
+package replay
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mpapenbr/iracelog-cli/log"
+)
+
+func newTestTargets(names ...string) *ReplayTask {
+	r := &ReplayTask{myLog: log.Default(), localCtx: context.Background()}
+	for _, name := range names {
+		r.targets = append(r.targets, &target{name: name})
+	}
+	r.startTargets()
+	return r
+}
+
+// verifies that all targets get the data at the same pace
+func Test_forEachTargetSlow(t *testing.T) {
+	r := newTestTargets("fast", "slow")
+	defer r.stopTargets()
+	fast, slow := r.targets[0], r.targets[1]
+	mu := sync.Mutex{}
+	got := map[*target][]int{}
+	n := 20
+	for i := range n {
+		err := r.forEachTarget("publish", func(t *target) error {
+			if t == slow {
+				time.Sleep(time.Millisecond)
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			got[t] = append(got[t], i)
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("forEachTarget() error = %v", err)
+		}
+		// the slow target is done when forEachTarget returns
+		mu.Lock()
+		if len(got[slow]) != i+1 {
+			t.Errorf("slow got %d items after %d calls", len(got[slow]), i+1)
+		}
+		mu.Unlock()
+	}
+	want := make([]int, n)
+	for i := range want {
+		want[i] = i
+	}
+	for _, tgt := range []*target{fast, slow} {
+		if err := tgt.failed(); err != nil {
+			t.Errorf("%s.failed() = %v, want nil", tgt.name, err)
+		}
+		if !slices.Equal(got[tgt], want) {
+			t.Errorf("%s got %v, want %v", tgt.name, got[tgt], want)
+		}
+	}
+}
+
+func Test_forEachTargetErrors(t *testing.T) {
+	errA, errB := errors.New("a"), errors.New("b")
+	tests := []struct {
+		name    string
+		errs    map[string]error
+		wantErr []error
+	}{
+		{name: "all ok", errs: map[string]error{}},
+		{name: "one failed", errs: map[string]error{"a": errA}},
+		{
+			name:    "all failed",
+			errs:    map[string]error{"a": errA, "b": errB},
+			wantErr: []error{errA, errB},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newTestTargets("a", "b")
+			defer r.stopTargets()
+			err := r.forEachTarget("publish", func(t *target) error {
+				return tt.errs[t.name]
+			})
+			if len(tt.wantErr) == 0 && err != nil {
+				t.Errorf("forEachTarget() error = %v, want nil", err)
+			}
+			for _, want := range tt.wantErr {
+				if !errors.Is(err, want) {
+					t.Errorf("forEachTarget() error = %v, want %v", err, want)
+				}
+			}
+			// failed targets are not used anymore
+			r.syncTargets()
+			for _, tgt := range r.targets {
+				if got := tgt.failed(); got != tt.errs[tgt.name] {
+					t.Errorf("%s.failed() = %v, want %v", tgt.name, got, tt.errs[tgt.name])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,208 @@
+package replay
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+
+	"buf.build/gen/go/mpapenbr/iracelog/grpc/go/iracelog/provider/v1/providerv1grpc"
+	"buf.build/gen/go/mpapenbr/iracelog/grpc/go/iracelog/racestate/v1/racestatev1grpc"
+	providerv1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/provider/v1"
+	racestatev1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/racestate/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// A dry-run doesn't call a server. Instead all requests the replay would send
+// are written as records (see dataprovider_file.go) to a writer.
+// A dry-run doesn't wait for the data to become due. It uses a virtual clock
+// which is advanced to the scheduled send time of the data instead.
+// The field "ts" contains the time the request would have been sent at,
+// the field "dataTs" contains the data timestamp of published data.
+// Since the virtual clock starts at a given time, two runs of the same replay
+// with the same start time produce the same output.
+//
+// Example:
+//
+//	{"type":"register","ts":"...","data":{"key":"...","event":{...}}}
+//	{"type":"state","ts":"...","dataTs":"...","data":{...}}
+//	{"type":"unregister","ts":"...","data":{"eventSelector":{...}}}
+//
+// The output can be used as replay file.
+
+const RecordUnregister recordType = "unregister"
+
+type dryRun struct {
+	w     io.Writer
+	mu    sync.Mutex
+	clock time.Time // virtual wall clock
+}
+
+type sendTimeKey struct{}
+
+// WithDryRun writes the requests to w instead of sending them to a server.
+// The virtual clock of the dry-run starts at start.
+// The dry-run is an additional destination, so the destination passed to
+// NewReplayTask may be nil.
+func WithDryRun(w io.Writer, start time.Time) ReplayOption {
+	return func(r *ReplayTask) {
+		r.dryRun = &dryRun{w: w, clock: start}
+	}
+}
+
+// now returns the current wall clock time, the virtual one on a dry-run
+func (r *ReplayTask) now() time.Time {
+	if r.dryRun != nil {
+		return r.dryRun.now()
+	}
+	return time.Now()
+}
+
+// withSendTime stores the time the data is sent at in the context.
+// The data may be sent later by slow destinations.
+func withSendTime(ctx context.Context, ts time.Time) context.Context {
+	return context.WithValue(ctx, sendTimeKey{}, ts)
+}
+
+func (d *dryRun) target() *target {
+	return &target{
+		name:             "dry-run",
+		providerService:  &dryRunProviderService{d: d},
+		raceStateService: &dryRunRaceStateService{d: d},
+	}
+}
+
+func (d *dryRun) now() time.Time {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.clock
+}
+
+// advance moves the virtual clock forward to ts
+func (d *dryRun) advance(ts time.Time) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if ts.After(d.clock) {
+		d.clock = ts
+	}
+}
+
+// sendTime returns the send time stored in ctx or the current virtual time
+func (d *dryRun) sendTime(ctx context.Context) time.Time {
+	if ts, ok := ctx.Value(sendTimeKey{}).(time.Time); ok {
+		return ts
+	}
+	return d.now()
+}
+
+//nolint:whitespace // by design
+func (d *dryRun) write(
+	t recordType,
+	ts time.Time,
+	dataTS *time.Time,
+	msg proto.Message,
+) error {
+	data, err := protojson.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	// json.Marshal compacts the data, so the output doesn't depend on
+	// the (unstable) formatting of protojson
+	line, err := json.Marshal(&fileRecord{Type: t, TS: &ts, DataTS: dataTS, Data: data})
+	if err != nil {
+		return err
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	_, err = d.w.Write(append(line, '\n'))
+	return err
+}
+
+// publish writes a record for published data
+//
+//nolint:whitespace // by design
+func (d *dryRun) publish(
+	ctx context.Context,
+	t recordType,
+	dataTS *timestamppb.Timestamp,
+	msg proto.Message,
+) error {
+	ts := dataTS.AsTime()
+	return d.write(t, d.sendTime(ctx), &ts, msg)
+}
+
+// only the methods used by the replay are implemented
+type dryRunProviderService struct {
+	providerv1grpc.ProviderServiceClient
+	d *dryRun
+}
+
+//nolint:whitespace // by design
+func (s *dryRunProviderService) RegisterEvent(
+	_ context.Context,
+	req *providerv1.RegisterEventRequest,
+	_ ...grpc.CallOption,
+) (*providerv1.RegisterEventResponse, error) {
+	if err := s.d.write(RecordRegister, s.d.now(), nil, req); err != nil {
+		return nil, err
+	}
+	return &providerv1.RegisterEventResponse{Event: req.Event}, nil
+}
+
+//nolint:whitespace // by design
+func (s *dryRunProviderService) UnregisterEvent(
+	_ context.Context,
+	req *providerv1.UnregisterEventRequest,
+	_ ...grpc.CallOption,
+) (*providerv1.UnregisterEventResponse, error) {
+	if err := s.d.write(RecordUnregister, s.d.now(), nil, req); err != nil {
+		return nil, err
+	}
+	return &providerv1.UnregisterEventResponse{}, nil
+}
+
+// only the methods used by the replay are implemented
+type dryRunRaceStateService struct {
+	racestatev1grpc.RaceStateServiceClient
+	d *dryRun
+}
+
+//nolint:whitespace // by design
+func (s *dryRunRaceStateService) PublishState(
+	ctx context.Context,
+	req *racestatev1.PublishStateRequest,
+	_ ...grpc.CallOption,
+) (*racestatev1.PublishStateResponse, error) {
+	if err := s.d.publish(ctx, RecordState, req.Timestamp, req); err != nil {
+		return nil, err
+	}
+	return &racestatev1.PublishStateResponse{}, nil
+}
+
+//nolint:whitespace // by design
+func (s *dryRunRaceStateService) PublishSpeedmap(
+	ctx context.Context,
+	req *racestatev1.PublishSpeedmapRequest,
+	_ ...grpc.CallOption,
+) (*racestatev1.PublishSpeedmapResponse, error) {
+	if err := s.d.publish(ctx, RecordSpeedmap, req.Timestamp, req); err != nil {
+		return nil, err
+	}
+	return &racestatev1.PublishSpeedmapResponse{}, nil
+}
+
+//nolint:whitespace // by design
+func (s *dryRunRaceStateService) PublishDriverData(
+	ctx context.Context,
+	req *racestatev1.PublishDriverDataRequest,
+	_ ...grpc.CallOption,
+) (*racestatev1.PublishDriverDataResponse, error) {
+	if err := s.d.publish(ctx, RecordDriver, req.Timestamp, req); err != nil {
+		return nil, err
+	}
+	return &racestatev1.PublishDriverDataResponse{}, nil
+}
@@ -0,0 +1,222 @@
+package replay
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	commonv1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/common/v1"
+	eventv1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/event/v1"
+	providerv1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/provider/v1"
+	racestatev1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/racestate/v1"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// memDataProvider provides the replay data from memory
+type memDataProvider struct {
+	eventReq  *providerv1.RegisterEventRequest
+	states    []*racestatev1.PublishStateRequest
+	drivers   []*racestatev1.PublishDriverDataRequest
+	speedmaps []*racestatev1.PublishSpeedmapRequest
+}
+
+func (p *memDataProvider) ProvideEventData(uint32) *providerv1.RegisterEventRequest {
+	return p.eventReq
+}
+
+func (p *memDataProvider) NextStateData() *racestatev1.PublishStateRequest {
+	return popFirst(&p.states)
+}
+
+func (p *memDataProvider) NextDriverData() *racestatev1.PublishDriverDataRequest {
+	return popFirst(&p.drivers)
+}
+
+func (p *memDataProvider) NextSpeedmapData() *racestatev1.PublishSpeedmapRequest {
+	return popFirst(&p.speedmaps)
+}
+
+func (p *memDataProvider) MapSessionNumToType(sessionNum uint32) commonv1.SessionType {
+	return sessionTypeMapper(p.eventReq)(sessionNum)
+}
+
+func popFirst[E any](items *[]*E) *E {
+	if len(*items) == 0 {
+		return nil
+	}
+	ret := (*items)[0]
+	*items = (*items)[1:]
+	return ret
+}
+
+var (
+	testEventTime = time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	testStart     = testEventTime.Add(time.Hour)
+	testWallStart = time.Date(2025, 1, 1, 8, 0, 0, 0, time.UTC) // dry-run clock
+)
+
+// testTime returns the data timestamp sec seconds after the session start
+func testTime(sec float64) *timestamppb.Timestamp {
+	return timestamppb.New(testStart.Add(time.Duration(sec * float64(time.Second))))
+}
+
+func testState(sec float64) *racestatev1.PublishStateRequest {
+	return &racestatev1.PublishStateRequest{
+		Timestamp: testTime(sec),
+		Session:   &racestatev1.Session{SessionNum: 0, SessionTime: float32(sec)},
+	}
+}
+
+func testDriver(sec float64) *racestatev1.PublishDriverDataRequest {
+	return &racestatev1.PublishDriverDataRequest{
+		Timestamp:   testTime(sec),
+		SessionNum:  0,
+		SessionTime: float32(sec),
+	}
+}
+
+func testSpeedmap(sec float64) *racestatev1.PublishSpeedmapRequest {
+	return &racestatev1.PublishSpeedmapRequest{Timestamp: testTime(sec)}
+}
+
+// newTestProvider provides the data of a single race session at the given
+// session times
+func newTestProvider(states, drivers, speedmaps []float64) *memDataProvider {
+	ret := &memDataProvider{
+		eventReq: &providerv1.RegisterEventRequest{
+			Key: "test",
+			Event: &eventv1.Event{
+				Key:       "test",
+				EventTime: timestamppb.New(testEventTime),
+				Sessions: []*eventv1.Session{
+					{Num: 0, Type: commonv1.SessionType_SESSION_TYPE_RACE},
+				},
+			},
+		},
+	}
+	for _, sec := range states {
+		ret.states = append(ret.states, testState(sec))
+	}
+	for _, sec := range drivers {
+		ret.drivers = append(ret.drivers, testDriver(sec))
+	}
+	for _, sec := range speedmaps {
+		ret.speedmaps = append(ret.speedmaps, testSpeedmap(sec))
+	}
+	return ret
+}
+
+// dryRunRecords replays the data as dry-run and returns the written records
+//
+//nolint:whitespace // by design
+func dryRunRecords(
+	t *testing.T,
+	provider ReplayDataProvider,
+	opts ...ReplayOption,
+) []*fileRecord {
+	t.Helper()
+	buf := bytes.Buffer{}
+	opts = append([]ReplayOption{WithSpeed(0), WithDryRun(&buf, testWallStart)},
+		opts...)
+	if err := NewReplayTask(nil, provider, opts...).Replay(1); err != nil {
+		t.Fatalf("Replay() error = %v", err)
+	}
+	ret := []*fileRecord{}
+	scanner := bufio.NewScanner(&buf)
+	for scanner.Scan() {
+		rec := &fileRecord{}
+		if err := json.Unmarshal(scanner.Bytes(), rec); err != nil {
+			t.Fatalf("invalid record %s: %v", scanner.Text(), err)
+		}
+		ret = append(ret, rec)
+	}
+	return ret
+}
+
+// publishedRecord is the part of a record checked by the tests
+type publishedRecord struct {
+	Type   recordType
+	Sent   time.Time
+	DataTS time.Time // zero for register and unregister
+}
+
+//nolint:whitespace // by design
+func checkRecords(
+	t *testing.T,
+	got []*fileRecord,
+	want []publishedRecord,
+) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("got %d records, want %d", len(got), len(want))
+	}
+	for i := range want {
+		dataTS := time.Time{}
+		if got[i].DataTS != nil {
+			dataTS = *got[i].DataTS
+		}
+		if got[i].Type != want[i].Type || !got[i].TS.Equal(want[i].Sent) ||
+			!dataTS.Equal(want[i].DataTS) {
+			t.Errorf("record %d = %v %v %v, want %v %v %v", i,
+				got[i].Type, got[i].TS, dataTS,
+				want[i].Type, want[i].Sent, want[i].DataTS)
+		}
+	}
+}
+
+// verifies the order in which sendData publishes interleaved data
+func Test_dryRunOrder(t *testing.T) {
+	provider := newTestProvider(
+		[]float64{0, 2, 4},
+		[]float64{1, 3, 4},
+		[]float64{2.5, 5})
+	at := func(sec float64) time.Time { return testTime(sec).AsTime() }
+	now := testWallStart // as fast as possible, so everything is sent at once
+	checkRecords(t, dryRunRecords(t, provider), []publishedRecord{
+		{RecordRegister, now, time.Time{}},
+		{RecordState, now, at(0)},
+		{RecordDriver, now, at(1)},
+		{RecordState, now, at(2)},
+		{RecordSpeedmap, now, at(2.5)},
+		{RecordDriver, now, at(3)},
+		{RecordState, now, at(4)}, // state data wins on equal timestamps
+		{RecordDriver, now, at(4)},
+		{RecordSpeedmap, now, at(5)},
+		{RecordUnregister, now, time.Time{}},
+	})
+}
+
+// verifies that the records carry the scheduled send time
+func Test_dryRunSchedule(t *testing.T) {
+	tests := []struct {
+		name  string
+		speed float64
+		sent  []float64 // seconds after the start of the replay
+	}{
+		{name: "real time", speed: 1, sent: []float64{0, 1, 2, 3, 4}},
+		{name: "double speed", speed: 2, sent: []float64{0, 0.5, 1, 1.5, 2}},
+		{name: "half speed", speed: 0.5, sent: []float64{0, 2, 4, 6, 8}},
+		{name: "max speed", speed: 0, sent: []float64{0, 0, 0, 0, 0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider := newTestProvider([]float64{0, 2, 4}, []float64{1, 3}, nil)
+			wall := func(sec float64) time.Time {
+				return testWallStart.Add(time.Duration(sec * float64(time.Second)))
+			}
+			at := func(sec float64) time.Time { return testTime(sec).AsTime() }
+			got := dryRunRecords(t, provider, WithSpeed(tt.speed))
+			checkRecords(t, got, []publishedRecord{
+				{RecordRegister, wall(0), time.Time{}},
+				{RecordState, wall(tt.sent[0]), at(0)},
+				{RecordDriver, wall(tt.sent[1]), at(1)},
+				{RecordState, wall(tt.sent[2]), at(2)},
+				{RecordDriver, wall(tt.sent[3]), at(3)},
+				{RecordState, wall(tt.sent[4]), at(4)},
+				{RecordUnregister, wall(tt.sent[4]), time.Time{}},
+			})
+		})
+	}
+}
@@ -0,0 +1,159 @@
+package replay
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/mpapenbr/iracelog-cli/log"
+)
+
+// ErrorPolicy controls how publish errors are handled during a replay.
+// Without an error policy the replay is aborted on the first error.
+type ErrorPolicy struct {
+	MaxRetries     int           // max retries per message for retryable errors
+	InitialBackoff time.Duration // wait time before the first retry
+	MaxBackoff     time.Duration // backoff is doubled on each retry up to this value
+	// abort the replay after this many consecutive messages were dropped
+	// (0: never abort). Skipped messages don't count.
+	MaxConsecutiveFailures int
+	RetryCodes             []codes.Code // these errors are retried
+	SkipCodes              []codes.Code // these messages are skipped (invalid data)
+}
+
+// ErrorStats summarizes the handled publish errors of a replay
+type ErrorStats struct {
+	Retried int // number of retries
+	Skipped int // messages skipped because of invalid data
+	Dropped int // messages dropped after max retries
+}
+
+func DefaultErrorPolicy() *ErrorPolicy {
+	return &ErrorPolicy{
+		MaxRetries:             5,
+		InitialBackoff:         500 * time.Millisecond,
+		MaxBackoff:             30 * time.Second,
+		MaxConsecutiveFailures: 10,
+		RetryCodes: []codes.Code{
+			codes.Unavailable,
+			codes.ResourceExhausted,
+			codes.DeadlineExceeded,
+		},
+		SkipCodes: []codes.Code{
+			codes.InvalidArgument,
+			codes.OutOfRange,
+		},
+	}
+}
+
+func WithErrorPolicy(p *ErrorPolicy) ReplayOption {
+	return func(r *ReplayTask) {
+		r.errorPolicy = p
+	}
+}
+
+// ErrorStats returns the error statistics collected so far
+func (r *ReplayTask) ErrorStats() ErrorStats {
+	r.statsMu.Lock()
+	defer r.statsMu.Unlock()
+	return r.errStats
+}
+
+// publish applies the transformers and sends the data.
+// An error is returned if the replay should be aborted.
+func (r *ReplayTask) publish(p peek) error {
+	p.transform()
+	return r.send(p)
+}
+
+// send publishes the data to all destinations.
+// An error is returned if the replay should be aborted.
+func (r *ReplayTask) send(p peek) error {
+	p = p.clone() // the targets may send the data after p was refilled
+	ctx := withSendTime(r.ctx, r.now())
+	return r.forEachTarget("publish", func(t *target) error {
+		return r.sendTo(ctx, p, t)
+	})
+}
+
+// sendTo publishes the data to the target using the configured error policy.
+// An error is returned if the target should no longer be used.
+func (r *ReplayTask) sendTo(ctx context.Context, p peek, t *target) error {
+	policy := r.errorPolicy
+	if policy == nil {
+		return p.publish(ctx, t)
+	}
+	backoff := policy.InitialBackoff
+	for attempt := 0; ; attempt++ {
+		err := p.publish(ctx, t)
+		if err == nil {
+			t.consecutiveFailures = 0
+			return nil
+		}
+		if r.localCtx.Err() != nil {
+			return err
+		}
+		code := status.Code(err)
+		switch {
+		case slices.Contains(policy.RetryCodes, code) && attempt < policy.MaxRetries:
+			r.myLog.Warn("Publishing failed, retrying",
+				log.String("provider", string(p.provider())),
+				log.String("destination", t.name),
+				log.Int("attempt", attempt+1),
+				log.Duration("backoff", backoff),
+				log.ErrorField(err))
+			r.updateErrorStats(func(s *ErrorStats) { s.Retried++ })
+			if !r.sleepCtx(backoff) {
+				return err
+			}
+			backoff = min(2*backoff, policy.MaxBackoff)
+		case slices.Contains(policy.SkipCodes, code):
+			r.myLog.Warn("Skipping invalid data",
+				log.String("provider", string(p.provider())),
+				log.ErrorField(err))
+			r.updateErrorStats(func(s *ErrorStats) { s.Skipped++ })
+			return nil
+		case slices.Contains(policy.RetryCodes, code):
+			r.myLog.Warn("Dropping data after max retries",
+				log.String("provider", string(p.provider())),
+				log.ErrorField(err))
+			r.updateErrorStats(func(s *ErrorStats) { s.Dropped++ })
+			return r.checkConsecutiveFailures(t, err)
+		default:
+			return err
+		}
+	}
+}
+
+func (r *ReplayTask) checkConsecutiveFailures(t *target, err error) error {
+	t.consecutiveFailures++
+	limit := r.errorPolicy.MaxConsecutiveFailures
+	if limit > 0 && t.consecutiveFailures >= limit {
+		r.myLog.Error("Too many consecutive failures",
+			log.String("destination", t.name),
+			log.Int("failures", t.consecutiveFailures))
+		return err
+	}
+	return nil
+}
+
+func (r *ReplayTask) updateErrorStats(f func(s *ErrorStats)) {
+	r.statsMu.Lock()
+	defer r.statsMu.Unlock()
+	f(&r.errStats)
+}
+
+// sleepCtx waits for the given duration. Returns false if the context is done.
+func (r *ReplayTask) sleepCtx(d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-r.localCtx.Done():
+		return false
+	}
+}
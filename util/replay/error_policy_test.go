@@ -0,0 +1,95 @@
+package replay
+
+import (
+	"context"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/mpapenbr/iracelog-cli/log"
+)
+
+// errPeek fails to publish with the given code (codes.OK: success)
+type errPeek struct {
+	peek
+	code codes.Code
+}
+
+func (p *errPeek) provider() providerType { return StateData }
+
+func (p *errPeek) publish(context.Context, *target) error {
+	if p.code == codes.OK {
+		return nil
+	}
+	return status.Error(p.code, "test")
+}
+
+//nolint:funlen // table
+func Test_sendToConsecutiveFailures(t *testing.T) {
+	const (
+		ok      = codes.OK
+		skip    = codes.InvalidArgument
+		dropped = codes.Unavailable
+	)
+	tests := []struct {
+		name      string
+		max       int
+		codes     []codes.Code
+		wantAbort int // index of the item causing the abort, -1: no abort
+	}{
+		{
+			name:      "limit reached",
+			max:       2,
+			codes:     []codes.Code{dropped, dropped, ok},
+			wantAbort: 1,
+		},
+		{
+			name:      "reset on success",
+			max:       2,
+			codes:     []codes.Code{dropped, ok, dropped, ok},
+			wantAbort: -1,
+		},
+		{
+			name:      "skipped items don't count",
+			max:       2,
+			codes:     []codes.Code{skip, skip, skip, dropped, ok},
+			wantAbort: -1,
+		},
+		{
+			name:      "skipped items don't reset",
+			max:       2,
+			codes:     []codes.Code{dropped, skip, dropped},
+			wantAbort: 2,
+		},
+		{
+			name:      "disabled",
+			max:       0,
+			codes:     []codes.Code{dropped, dropped, dropped, skip},
+			wantAbort: -1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy := DefaultErrorPolicy()
+			policy.MaxRetries = 0
+			policy.MaxConsecutiveFailures = tt.max
+			r := &ReplayTask{
+				myLog:       log.Default(),
+				localCtx:    context.Background(),
+				errorPolicy: policy,
+			}
+			tgt := &target{name: "test"}
+			got := -1
+			for i, code := range tt.codes {
+				if err := r.sendTo(context.Background(), &errPeek{code: code}, tgt); err != nil {
+					got = i
+					break
+				}
+			}
+			if got != tt.wantAbort {
+				t.Errorf("aborted at %d, want %d", got, tt.wantAbort)
+			}
+		})
+	}
+}
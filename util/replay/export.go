@@ -0,0 +1,90 @@
+package replay
+
+import (
+	"encoding/json"
+	"io"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// ExportStats contains the number of records written by Export
+type ExportStats struct {
+	States    int
+	Drivers   int
+	Speedmaps int
+}
+
+// Export writes the event provided by dp as replay file (see dataprovider_file.go)
+// to w. The register record is written first, the data records follow ordered
+// by their timestamp.
+func Export(w io.Writer, dp ReplayDataProvider, eventID uint32) (*ExportStats, error) {
+	stats := &ExportStats{}
+	if err := writeRecord(w, RecordRegister, nil, dp.ProvideEventData(eventID)); err != nil {
+		return stats, err
+	}
+	state := dp.NextStateData()
+	driver := dp.NextDriverData()
+	speedmap := dp.NextSpeedmapData()
+	for state != nil || driver != nil || speedmap != nil {
+		var err error
+		// same order as the replay publishes (see comparePeek)
+		switch {
+		case state != nil &&
+			!after(state.Timestamp, driver.GetTimestamp()) &&
+			!after(state.Timestamp, speedmap.GetTimestamp()):
+			err = writeRecord(w, RecordState, state.Timestamp, state)
+			state = dp.NextStateData()
+			stats.States++
+		case driver != nil && !after(driver.Timestamp, speedmap.GetTimestamp()):
+			err = writeRecord(w, RecordDriver, driver.Timestamp, driver)
+			driver = dp.NextDriverData()
+			stats.Drivers++
+		default:
+			err = writeRecord(w, RecordSpeedmap, speedmap.Timestamp, speedmap)
+			speedmap = dp.NextSpeedmapData()
+			stats.Speedmaps++
+		}
+		if err != nil {
+			return stats, err
+		}
+	}
+	if er, ok := dp.(ErrorReporter); ok {
+		return stats, er.Err()
+	}
+	return stats, nil
+}
+
+// after reports whether a is after b. A missing b is treated as end of data.
+func after(a, b *timestamppb.Timestamp) bool {
+	if b == nil {
+		return false
+	}
+	return a.AsTime().After(b.AsTime())
+}
+
+//nolint:whitespace // by design
+func writeRecord(
+	w io.Writer,
+	t recordType,
+	dataTS *timestamppb.Timestamp,
+	msg proto.Message,
+) error {
+	data, err := protojson.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	rec := &fileRecord{Type: t, Data: data}
+	if dataTS != nil {
+		ts := dataTS.AsTime()
+		rec.DataTS = &ts
+	}
+	// json.Marshal compacts the data (see dryRun.write)
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(line, '\n'))
+	return err
+}
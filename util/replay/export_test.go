@@ -0,0 +1,87 @@
+package replay
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	providerv1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/provider/v1"
+	racestatev1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/racestate/v1"
+)
+
+// verifies that an exported event is provided unchanged by the file provider
+func Test_ExportRoundTrip(t *testing.T) {
+	states := []float64{0, 1, 2}
+	drivers := []float64{0, 2}
+	speedmaps := []float64{1.5}
+	buf := &bytes.Buffer{}
+	stats, err := Export(buf, newTestProvider(states, drivers, speedmaps), 1)
+	if err != nil {
+		t.Fatalf("Export() error = %v", err)
+	}
+	if *stats != (ExportStats{States: 3, Drivers: 2, Speedmaps: 1}) {
+		t.Errorf("Export() stats = %+v", *stats)
+	}
+	filename := filepath.Join(t.TempDir(), "export.ndjson")
+	if err := os.WriteFile(filename, buf.Bytes(), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	// records are ordered like they are published
+	recTypes := []recordType{}
+	reader, err := newRecordReader(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+	for rec, err := reader.next(); err == nil; rec, err = reader.next() {
+		recTypes = append(recTypes, rec.Type)
+	}
+	wantTypes := []recordType{
+		RecordRegister, RecordState, RecordDriver, RecordState,
+		RecordSpeedmap, RecordState, RecordDriver,
+	}
+	if !slices.Equal(recTypes, wantTypes) {
+		t.Errorf("record types = %v, want %v", recTypes, wantTypes)
+	}
+
+	eventReq, err := ReadEventFromFile(filename)
+	if err != nil {
+		t.Fatalf("ReadEventFromFile() error = %v", err)
+	}
+	if eventReq.GetKey() != "test" {
+		t.Errorf("ReadEventFromFile() key = %v, want test", eventReq.GetKey())
+	}
+	dp, err := NewFileDataProvider(filename,
+		func() *providerv1.RegisterEventRequest { return eventReq })
+	if err != nil {
+		t.Fatalf("NewFileDataProvider() error = %v", err)
+	}
+	defer dp.(interface{ Close() }).Close()
+	gotStates := drain(dp.NextStateData,
+		func(s *racestatev1.PublishStateRequest) float64 {
+			return float64(s.Session.SessionTime)
+		})
+	gotDrivers := drain(dp.NextDriverData,
+		func(d *racestatev1.PublishDriverDataRequest) float64 {
+			return float64(d.SessionTime)
+		})
+	gotSpeedmaps := drain(dp.NextSpeedmapData,
+		func(s *racestatev1.PublishSpeedmapRequest) float64 {
+			return s.Timestamp.AsTime().Sub(testStart).Seconds()
+		})
+	if !slices.Equal(gotStates, states) {
+		t.Errorf("states = %v, want %v", gotStates, states)
+	}
+	if !slices.Equal(gotDrivers, drivers) {
+		t.Errorf("drivers = %v, want %v", gotDrivers, drivers)
+	}
+	if !slices.Equal(gotSpeedmaps, speedmaps) {
+		t.Errorf("speedmaps = %v, want %v", gotSpeedmaps, speedmaps)
+	}
+	if err := dp.(ErrorReporter).Err(); err != nil {
+		t.Errorf("Err() = %v", err)
+	}
+}
@@ -0,0 +1,191 @@
+package replay
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	carv1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/car/v1"
+	commonv1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/common/v1"
+	providerv1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/provider/v1"
+	racestatev1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/racestate/v1"
+
+	"github.com/mpapenbr/iracelog-cli/log"
+)
+
+// SessionFilter limits the replay to selected sessions.
+// A session is replayed if it matches any of the types or nums.
+// Data of other sessions is not published. The latest driver data of a
+// skipped session is published when the next selected session starts.
+type SessionFilter struct {
+	Types []commonv1.SessionType
+	Nums  []uint32
+}
+
+var sessionTypeNames = map[string]commonv1.SessionType{
+	"practice": commonv1.SessionType_SESSION_TYPE_PRACTICE,
+	"qualify":  commonv1.SessionType_SESSION_TYPE_QUALIFY,
+	"race":     commonv1.SessionType_SESSION_TYPE_RACE,
+}
+
+func WithSessionFilter(f *SessionFilter) ReplayOption {
+	return func(r *ReplayTask) {
+		r.sessionFilter = f
+	}
+}
+
+// ParseSessionType converts a session type name (practice, qualify, race)
+func ParseSessionType(name string) (commonv1.SessionType, error) {
+	if t, ok := sessionTypeNames[strings.ToLower(name)]; ok {
+		return t, nil
+	}
+	return commonv1.SessionType_SESSION_TYPE_UNSPECIFIED,
+		fmt.Errorf("unknown session type: %s", name)
+}
+
+func (f *SessionFilter) matches(s *stampInfo) bool {
+	return slices.Contains(f.Types, s.sessionType) ||
+		slices.Contains(f.Nums, s.sessionNum)
+}
+
+// applySessionFilter checks if the data belongs to a selected session.
+// Speedmap data doesn't carry the session num, so it follows the other data.
+// Returns false if the data must not be published.
+func (r *ReplayTask) applySessionFilter(current peek, s *stampInfo) (bool, error) {
+	if r.sessionFilter == nil {
+		return true, nil
+	}
+	if current.provider() != SpeedmapData {
+		selected := r.sessionFilter.matches(s)
+		if selected != r.sessionSelected {
+			r.myLog.Info("session filter",
+				log.Uint32("sessionNum", s.sessionNum),
+				log.Bool("selected", selected))
+			r.sessionSelected = selected
+			r.resetSchedule()
+		}
+	}
+	if !r.sessionSelected {
+		if current.provider() == DriverData {
+			r.filteredDriverData = current.clone()
+			r.seedFrom(current)
+		}
+		return false, nil
+	}
+	// no need to publish the skipped driver data if there is newer one
+	if p := r.filteredDriverData; p != nil {
+		r.filteredDriverData = nil
+		if current.provider() != DriverData {
+			if err := r.publish(p); err != nil {
+				return false, err
+			}
+			r.recordPublished(p, time.Time{}, time.Time{})
+		}
+	}
+	return true, nil
+}
+
+// CarFilterTransformer removes all cars from the data which don't match
+// any of the car numbers or car classes.
+// The cars are identified by the car entries of the driver data. The first
+// driver data of the replay selects the cars for the registered event and
+// the data published before the next driver data.
+// Speedmap data is kept for the classes of the remaining cars.
+func CarFilterTransformer(carNums, carClasses []string) *Transformer {
+	f := &carFilter{
+		carNums:    carNums,
+		carClasses: carClasses,
+		carIdx:     make(map[uint32]bool),
+		classes:    make(map[string]bool),
+		classIDs:   make(map[int32]bool),
+		carTypes:   make(map[int32]bool),
+	}
+	return &Transformer{
+		Event:      f.event,
+		State:      f.state,
+		Speedmap:   f.speedmap,
+		DriverData: f.driverData,
+		Seed:       f.seed,
+	}
+}
+
+type carFilter struct {
+	carNums    []string
+	carClasses []string
+	carIdx     map[uint32]bool // carIdx of the selected cars
+	classes    map[string]bool // class names of the selected cars
+	classIDs   map[int32]bool  // class ids of the selected cars
+	carTypes   map[int32]bool  // car ids of the selected cars
+}
+
+func (f *carFilter) selected(car *carv1.CarInfo) bool {
+	return slices.Contains(f.carNums, car.CarNumber) ||
+		slices.Contains(f.carClasses, car.CarClassName)
+}
+
+// seed selects the cars of the driver data
+func (f *carFilter) seed(req *racestatev1.PublishDriverDataRequest) {
+	clear(f.carIdx)
+	clear(f.classes)
+	clear(f.classIDs)
+	clear(f.carTypes)
+	for _, e := range req.Entries {
+		if e.Car != nil && f.selected(e.Car) {
+			f.carIdx[e.Car.CarIdx] = true
+			f.classes[e.Car.CarClassName] = true
+			f.classIDs[e.Car.CarClassId] = true
+			f.carTypes[e.Car.CarId] = true
+		}
+	}
+}
+
+// event adjusts the car info of the event to the selected cars.
+// The event is kept as is if no driver data was seen.
+func (f *carFilter) event(req *providerv1.RegisterEventRequest) {
+	if len(f.carIdx) == 0 || req.Event == nil {
+		return
+	}
+	req.Event.NumCarTypes = uint32(len(f.carTypes))
+	req.Event.NumCarClasses = uint32(len(f.classes))
+	req.Event.MultiClass = len(f.classes) > 1
+}
+
+func (f *carFilter) driverData(req *racestatev1.PublishDriverDataRequest) {
+	f.seed(req)
+	req.Entries = slices.DeleteFunc(req.Entries, func(e *carv1.CarEntry) bool {
+		return e.Car == nil || !f.carIdx[e.Car.CarIdx]
+	})
+	req.Cars = slices.DeleteFunc(req.Cars, func(c *carv1.CarInfo) bool {
+		return !f.carIdx[c.CarIdx]
+	})
+	req.CarClasses = slices.DeleteFunc(req.CarClasses, func(c *carv1.CarClass) bool {
+		return !f.classIDs[c.Id]
+	})
+	for idx := range req.CurrentDrivers {
+		if !f.carIdx[idx] {
+			delete(req.CurrentDrivers, idx)
+		}
+	}
+}
+
+func (f *carFilter) state(req *racestatev1.PublishStateRequest) {
+	req.Cars = slices.DeleteFunc(req.Cars, func(c *racestatev1.Car) bool {
+		return !f.carIdx[uint32(c.CarIdx)] //nolint:gosec // carIdx is not negative
+	})
+	// messages without car number are not related to a car
+	req.Messages = slices.DeleteFunc(req.Messages, func(m *racestatev1.Message) bool {
+		return m.CarNum != "" && !f.carIdx[m.CarIdx]
+	})
+}
+
+func (f *carFilter) speedmap(req *racestatev1.PublishSpeedmapRequest) {
+	if req.Speedmap == nil {
+		return
+	}
+	for class := range req.Speedmap.Data {
+		if !f.classes[class] {
+			delete(req.Speedmap.Data, class)
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package replay
+
+import (
+	"slices"
+	"testing"
+
+	carv1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/car/v1"
+	eventv1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/event/v1"
+	providerv1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/provider/v1"
+	racestatev1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/racestate/v1"
+)
+
+// filterDriver returns driver data with a car (carIdx) per given class
+//
+//nolint:whitespace // by design
+func filterDriver(
+	sec float64,
+	classes ...string,
+) *racestatev1.PublishDriverDataRequest {
+	ret := testDriver(sec)
+	for idx, class := range classes {
+		ret.Entries = append(ret.Entries, &carv1.CarEntry{Car: &carv1.CarInfo{
+			CarIdx:       uint32(idx), //nolint:gosec // test data
+			CarNumber:    class + "-car",
+			CarClassName: class,
+			CarId:        int32(len(class)), //nolint:gosec // test data
+		}})
+	}
+	return ret
+}
+
+// filterState returns state data with numCars cars
+func filterState(sec float64, numCars int) *racestatev1.PublishStateRequest {
+	ret := testState(sec)
+	for idx := range int32(numCars) { //nolint:gosec // test data
+		ret.Cars = append(ret.Cars, &racestatev1.Car{CarIdx: idx})
+	}
+	return ret
+}
+
+// filterResult records the data after the car filter was applied
+type filterResult struct {
+	event *eventv1.Event
+	cars  [][]int32 // carIdx of the published states
+}
+
+func (f *filterResult) transformer() *Transformer {
+	return &Transformer{
+		Event: func(req *providerv1.RegisterEventRequest) {
+			f.event = req.Event
+		},
+		State: func(req *racestatev1.PublishStateRequest) {
+			idx := []int32{}
+			for _, c := range req.Cars {
+				idx = append(idx, c.CarIdx)
+			}
+			f.cars = append(f.cars, idx)
+		},
+	}
+}
+
+//nolint:funlen // table
+func Test_carFilter(t *testing.T) {
+	tests := []struct {
+		name      string
+		states    []*racestatev1.PublishStateRequest
+		drivers   []*racestatev1.PublishDriverDataRequest
+		window    *SessionWindow
+		wantCars  [][]int32
+		wantEvent *eventv1.Event
+	}{
+		{
+			// state is published before driver data on equal timestamps
+			name:   "state before driver data",
+			states: []*racestatev1.PublishStateRequest{filterState(0, 3)},
+			drivers: []*racestatev1.PublishDriverDataRequest{
+				filterDriver(0, "GT3", "LMP", "GT3"),
+			},
+			wantCars: [][]int32{{0, 2}},
+			wantEvent: &eventv1.Event{
+				NumCarTypes: 1, NumCarClasses: 1, MultiClass: false,
+			},
+		},
+		{
+			name:   "session window",
+			states: []*racestatev1.PublishStateRequest{filterState(9, 3), filterState(11, 3)},
+			drivers: []*racestatev1.PublishDriverDataRequest{
+				filterDriver(0, "GT3", "LMP", "LMP"),
+				filterDriver(10, "GT3", "LMP", "GT3"),
+			},
+			window: &SessionWindow{From: 10},
+			// the state before the window uses the driver data of the window start
+			wantCars: [][]int32{{0, 2}, {0, 2}},
+			wantEvent: &eventv1.Event{
+				NumCarTypes: 1, NumCarClasses: 1, MultiClass: false,
+			},
+		},
+		{
+			name:     "no driver data",
+			states:   []*racestatev1.PublishStateRequest{filterState(0, 2)},
+			wantCars: [][]int32{{}},
+			wantEvent: &eventv1.Event{
+				NumCarTypes: 5, NumCarClasses: 3, MultiClass: true,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider := newTestProvider(nil, nil, nil)
+			provider.states = tt.states
+			provider.drivers = tt.drivers
+			provider.eventReq.Event.NumCarTypes = 5
+			provider.eventReq.Event.NumCarClasses = 3
+			provider.eventReq.Event.MultiClass = true
+			got := &filterResult{}
+			opts := []ReplayOption{WithTransformer(
+				CarFilterTransformer(nil, []string{"GT3"}), got.transformer())}
+			if tt.window != nil {
+				opts = append(opts, WithSessionWindow(tt.window))
+			}
+			dryRunRecords(t, provider, opts...)
+			if !slices.EqualFunc(got.cars, tt.wantCars, slices.Equal) {
+				t.Errorf("state cars = %v, want %v", got.cars, tt.wantCars)
+			}
+			e := got.event
+			if e.NumCarTypes != tt.wantEvent.NumCarTypes ||
+				e.NumCarClasses != tt.wantEvent.NumCarClasses ||
+				e.MultiClass != tt.wantEvent.MultiClass {
+				t.Errorf("event cars = %d/%d/%v, want %d/%d/%v",
+					e.NumCarTypes, e.NumCarClasses, e.MultiClass,
+					tt.wantEvent.NumCarTypes, tt.wantEvent.NumCarClasses,
+					tt.wantEvent.MultiClass)
+			}
+		})
+	}
+}
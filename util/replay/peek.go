@@ -1,10 +1,12 @@
 package replay
 
 import (
+	"context"
 	"time"
 
 	commonv1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/common/v1"
 	racestatev1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/racestate/v1"
+	"google.golang.org/protobuf/proto"
 
 	"github.com/mpapenbr/iracelog-cli/log"
 )
@@ -17,9 +19,14 @@ const (
 	SpeedmapData providerType = "SpeedmapData"
 )
 
+// publishRank orders data with equal timestamps
+var publishRank = map[providerType]int{StateData: 0, DriverData: 1, SpeedmapData: 2}
+
 type stampInfo struct {
 	sessionType commonv1.SessionType
 	ts          time.Time
+	sessionNum  uint32
+	sessionTime float64
 }
 
 // this is used to peek into the data stream of the different provider.
@@ -28,9 +35,22 @@ type stampInfo struct {
 type peek interface {
 	stamp() *stampInfo
 	provider() providerType
-	publish() error
+	publish(ctx context.Context, t *target) error
 	refill() bool
+	clone() peek // keeps the current data when the original is refilled
+	size() int   // size of the current data in bytes
+	transform()  // applies the transformers of the ReplayTask
+}
+
+// comparePeek orders data the way it is published: by timestamp and
+// state data before driver data before speedmap data on equal timestamps.
+func comparePeek(a, b peek) int {
+	if c := a.stamp().ts.Compare(b.stamp().ts); c != 0 {
+		return c
+	}
+	return publishRank[a.provider()] - publishRank[b.provider()]
 }
+
 type commonStateData[E any] struct {
 	dataChan     chan *E
 	dataReq      *E
@@ -46,6 +66,13 @@ func (p *commonStateData[E]) refill() bool {
 	return ok
 }
 
+func (p *commonStateData[E]) size() int {
+	if m, ok := any(p.dataReq).(proto.Message); ok {
+		return proto.Size(m)
+	}
+	return 0
+}
+
 func (p *commonStateData[E]) provider() providerType {
 	return p.providerType
 }
@@ -59,3 +86,18 @@ type peekSpeedmapData struct {
 type peekDriverData struct {
 	commonStateData[racestatev1.PublishDriverDataRequest]
 }
+
+func (p *peekStateData) clone() peek {
+	ret := *p
+	return &ret
+}
+
+func (p *peekSpeedmapData) clone() peek {
+	ret := *p
+	return &ret
+}
+
+func (p *peekDriverData) clone() peek {
+	ret := *p
+	return &ret
+}
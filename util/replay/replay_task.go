@@ -2,18 +2,16 @@ package replay
 
 import (
 	"context"
+	"errors"
 	"sync"
 	"time"
 
-	"buf.build/gen/go/mpapenbr/iracelog/grpc/go/iracelog/provider/v1/providerv1grpc"
-	"buf.build/gen/go/mpapenbr/iracelog/grpc/go/iracelog/racestate/v1/racestatev1grpc"
 	commonv1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/common/v1"
 	eventv1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/event/v1"
 	providerv1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/provider/v1"
 	racestatev1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/racestate/v1"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
-	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
 
 	"github.com/mpapenbr/iracelog-cli/log"
@@ -40,6 +38,8 @@ func NewReplayTask(
 		myLog:        log.Default(),
 		ctx:          context.Background(),
 		ffPreRace:    true,
+		speed:        1,
+		ctrlChanged:  make(chan struct{}),
 	}
 	for _, opt := range opts {
 		opt(ret)
@@ -65,7 +65,9 @@ func WithTokenProvider(provider func() string) ReplayOption {
 	}
 }
 
-func WithSpeed(speed int) ReplayOption {
+// WithSpeed sets the replay speed multiplier. Values below 1 slow down the replay.
+// 0 means: go as fast as possible
+func WithSpeed(speed float64) ReplayOption {
 	return func(r *ReplayTask) {
 		r.speed = speed
 	}
@@ -87,12 +89,12 @@ type ReplayTask struct {
 	dataProvider ReplayDataProvider
 	dest         *grpc.ClientConn // destination server
 
-	ctx              context.Context
-	localCtx         context.Context
-	localCancel      context.CancelFunc
-	providerService  providerv1grpc.ProviderServiceClient
-	raceStateService racestatev1grpc.RaceStateServiceClient
-	event            *eventv1.Event
+	ctx          context.Context
+	localCtx     context.Context
+	localCancel  context.CancelFunc
+	event        *eventv1.Event // registered event (of the first destination)
+	destinations []*Destination
+	targets      []*target // all destinations (see destination.go)
 
 	wg             sync.WaitGroup
 	stateChan      chan *racestatev1.PublishStateRequest
@@ -101,22 +103,67 @@ type ReplayTask struct {
 	fastForward    time.Duration
 	ffStopTime     time.Time // time when fast forward should stop
 	tokenProvider  func() string
-	speed          int
+	speed          float64
 	myLog          *log.Logger // used to for replay task related logging
 	ffPreRace      bool        // fast forward messages prior to race session
+
+	// runtime control (see control.go), guarded by ctrlMu
+	ctrlMu         sync.Mutex
+	ctrlChanged    chan struct{} // closed (and replaced) on each control change
+	paused         bool
+	pausedAt       time.Time
+	seeking        bool
+	seekTime       float64
+	seekSessionNum uint32
+	curSessionNum  uint32   // session num of last published data
+	curSessionTime float64  // session time of last published data
+	sched          schedule // see schedule.go
+
+	catchUpLimit time.Duration
+
+	window        *SessionWindow
+	windowStarted bool
+	pending       map[providerType]peek // latest data seen before the window
+
+	sessionFilter      *SessionFilter
+	sessionSelected    bool // data of the current session is published
+	filteredDriverData peek // latest driver data of a skipped session
+
+	errorPolicy *ErrorPolicy
+	statsMu     sync.Mutex
+	errStats    ErrorStats
+
+	eventData             *eventv1.Event // event data as provided by dataProvider
+	stats                 Stats
+	statsCallback         func(*Stats)
+	statsCallbackDuration time.Duration
+
+	transformers    []*Transformer
+	firstDriverData *racestatev1.PublishDriverDataRequest // read before register
+
+	registerReq *providerv1.RegisterEventRequest
+	chaos       *Chaos
+
+	checkpoint *checkpointWriter
+	resume     *Checkpoint
+	completed  bool // all data was replayed
+
+	dryRun *dryRun
 }
 
 func (p *peekDriverData) stamp() *stampInfo {
 	return &stampInfo{
 		ts:          p.dataReq.Timestamp.AsTime(),
 		sessionType: p.mapFunc(p.dataReq.SessionNum),
+		sessionNum:  p.dataReq.SessionNum,
+		sessionTime: float64(p.dataReq.SessionTime),
 	}
 }
 
-func (p *peekDriverData) publish() error {
+func (p *peekDriverData) publish(ctx context.Context, t *target) error {
 	p.logger.Debug("Sending driver data", log.Time("time", p.dataReq.Timestamp.AsTime()))
-	ctx := p.r.prepOutgoingContext(p.r.ctx)
-	if _, err := p.r.raceStateService.PublishDriverData(ctx, p.dataReq); err != nil {
+	ctx = t.prepOutgoingContext(ctx)
+	if _, err := t.raceStateService.PublishDriverData(ctx, p.dataReq); err != nil {
 		return err
 	}
 	return nil
@@ -126,13 +173,15 @@ func (p *peekStateData) stamp() *stampInfo {
 	return &stampInfo{
 		ts:          p.dataReq.Timestamp.AsTime(),
 		sessionType: p.mapFunc(p.dataReq.Session.SessionNum),
+		sessionNum:  p.dataReq.Session.SessionNum,
+		sessionTime: float64(p.dataReq.Session.SessionTime),
 	}
 }
 
-func (p *peekStateData) publish() error {
+func (p *peekStateData) publish(ctx context.Context, t *target) error {
 	p.logger.Debug("Sending state data", log.Time("time", p.dataReq.Timestamp.AsTime()))
-	ctx := p.r.prepOutgoingContext(p.r.ctx)
-	if _, err := p.r.raceStateService.PublishState(ctx, p.dataReq); err != nil {
+	ctx = t.prepOutgoingContext(ctx)
+	if _, err := t.raceStateService.PublishState(ctx, p.dataReq); err != nil {
 		return err
 	}
 	return nil
@@ -153,18 +202,20 @@ func (p *peekSpeedmapData) stamp() *stampInfo {
 	}
 }
 
-func (p *peekSpeedmapData) publish() error {
+func (p *peekSpeedmapData) publish(ctx context.Context, t *target) error {
 	p.logger.Debug("Sending speedmap data", log.Time("time", p.dataReq.Timestamp.AsTime()))
-	ctx := p.r.prepOutgoingContext(p.r.ctx)
-	if _, err := p.r.raceStateService.PublishSpeedmap(ctx, p.dataReq); err != nil {
+	ctx = t.prepOutgoingContext(ctx)
+	if _, err := t.raceStateService.PublishSpeedmap(ctx, p.dataReq); err != nil {
 		return err
 	}
 	return nil
 }
 
 func (r *ReplayTask) Replay(eventID uint32) error {
-	r.providerService = providerv1grpc.NewProviderServiceClient(r.dest)
-	r.raceStateService = racestatev1grpc.NewRaceStateServiceClient(r.dest)
+	r.initTargets()
+	if len(r.targets) == 0 {
+		return ErrNoDestination
+	}
 	r.localCtx, r.localCancel = context.WithCancel(r.ctx)
 	defer r.localCancel()
 	r.myLog.Debug("ReplayTask started",
@@ -178,16 +229,31 @@ func (r *ReplayTask) Replay(eventID uint32) error {
 
 	var err error
 	registerReq := r.dataProvider.ProvideEventData(eventID)
+	r.restoreTransformers()
+	// the first driver data describes the cars of the event
+	r.firstDriverData = r.dataProvider.NextDriverData()
+	if r.firstDriverData != nil {
+		r.seedTransformers(r.firstDriverData)
+	}
+	r.transformEvent(registerReq)
+	r.registerReq = registerReq
 
-	if r.event, err = r.registerEvent(registerReq); err != nil {
+	r.startTargets()
+	// a resumed event may still be registered
+	if err = r.registerEvents(registerReq, r.resume != nil); err != nil {
+		r.stopTargets()
 		return err
 	}
+	r.eventData = registerReq.Event
 	r.myLog.Info("replaying event",
 		log.Uint32("id", eventID),
 		log.String("key", r.event.Key),
 		log.String("event", r.event.Name),
 	)
 
+	r.initCheckpoint()
+	stopCheckpoints := r.startCheckpoints()
+	stopStats := r.startStatsCallback()
 	r.wg = sync.WaitGroup{}
 	r.wg.Add(4)
 	go r.provideDriverData()
@@ -197,12 +263,38 @@ func (r *ReplayTask) Replay(eventID uint32) error {
 
 	r.myLog.Debug("Waiting for tasks to finish")
 	r.wg.Wait()
+	r.stopTargets()
+	stopStats()
+	stopCheckpoints(r.completed)
+	drift := r.GetStats().Drift
+	r.myLog.Info("replay drift",
+		log.Int("scheduled", drift.Count),
+		log.Duration("max", drift.Max),
+		log.Duration("mean", drift.Mean()))
+
+	var providerErr error
+	if ep, ok := r.dataProvider.(ErrorReporter); ok {
+		providerErr = ep.Err()
+	}
+	if c, ok := r.dataProvider.(interface{ Close() }); ok {
+		c.Close()
+	}
 
 	r.myLog.Debug("About to unregister event")
-	err = r.unregisterEvent()
+	err = r.unregisterEvents()
 	r.myLog.Debug("Event unregistered", log.String("key", r.event.Key))
 
-	return err
+	return errors.Join(providerErr, err)
+}
+
+// checkProviderError stops the replay if the data provider reports an error
+func (r *ReplayTask) checkProviderError() {
+	if ep, ok := r.dataProvider.(ErrorReporter); ok {
+		if err := ep.Err(); err != nil {
+			r.myLog.Error("Data provider failed", log.ErrorField(err))
+			r.localCancel()
+		}
+	}
 }
 
 //nolint:funlen,gocognit,cyclop //  by design
@@ -254,76 +346,111 @@ func (r *ReplayTask) sendData() {
 	lastSessionType := commonv1.SessionType_SESSION_TYPE_PRACTICE
 
 	for {
-		var selector providerType
 		var current peek
-		var delta time.Duration
 		var currentIdx int
-
-		// create a max time from  (don't use time.Unix(1<<63-1), that's not what we want)
-		nextTS := time.Unix(0, 0).Add(1<<63 - 1)
-
 		for i, p := range pData {
-			if p.stamp().ts.Before(nextTS) {
-				nextTS = p.stamp().ts
-				selector = p.provider()
+			if current == nil || comparePeek(p, current) < 0 {
 				current = pData[i]
 				currentIdx = i
 			}
 		}
+		if current == nil {
+			r.myLog.Error("No provider found")
+			return
+		}
+		selector := current.provider()
+		nextTS := current.stamp().ts
 		r.computeFastForwardStop(nextTS)
+		currentStamp := current.stamp()
+		doPublish, done := false, false
+		var err error
+		// data published before the checkpoint is neither published nor kept
+		if !r.skipResumed(selector, currentStamp) {
+			doPublish, done, err = r.applyWindow(current, currentStamp)
+		}
+		if err != nil {
+			r.handlePublishError(err, selector)
+			return
+		}
+		if done {
+			r.myLog.Info("End of session window reached")
+			r.completed = true
+			r.localCancel()
+			return
+		}
+		if doPublish {
+			if doPublish, err = r.applySessionFilter(current, currentStamp); err != nil {
+				r.handlePublishError(err, selector)
+				return
+			}
+		}
 
-		if !lastTS.IsZero() {
+		due := time.Time{} // when the current data should be sent
+		if doPublish {
 			// use lastSessionType because waitTime should only be calculated
 			// if we are within a race session
-			wait := r.calcWaitTime(nextTS, lastTS, lastSessionType)
-			if wait > 0 {
-				r.myLog.Debug("Sleeping",
-					log.Time("time", nextTS),
-					log.Duration("delta", delta),
-					log.Duration("wait", wait),
-				)
-				time.Sleep(wait)
+			var ok bool
+			if due, ok = r.waitForNext(nextTS, lastTS, lastSessionType); !ok {
+				r.myLog.Debug("Context done while waiting")
+				return
 			}
 		}
 		lastTS = nextTS
-		if current == nil {
-			r.myLog.Error("No provider found")
-			return
-		}
-		lastSessionType = current.stamp().sessionType
-		if err := current.publish(); err != nil {
-			st, ok := status.FromError(err)
-			if ok {
-				//nolint:exhaustive // false positive
-				switch st.Code() {
-				case codes.DeadlineExceeded, codes.Canceled, codes.Aborted:
-					r.myLog.Debug("context deadline exceeded")
-					r.localCancel()
-					return
-				}
+		lastSessionType = currentStamp.sessionType
+		if doPublish {
+			r.seedSameTime(pData, current)
+			sent := r.now()
+			if err := r.publishWithChaos(current); err != nil {
+				r.handlePublishError(err, selector)
+				return
 			}
-			r.myLog.Error("Error publishing data",
-				log.String("provider", string(selector)),
-				log.String("event", r.event.Key),
-				log.ErrorField(err))
-			r.localCancel()
-			return
-		} else {
 			r.myLog.Debug("Published data",
 				log.String("provider", string(selector)),
 			)
+			r.recordPublished(current, due, sent)
+			r.recordCheckpoint(selector, currentStamp)
+		}
+		// speedmap data doesn't carry the session num
+		if selector != SpeedmapData {
+			r.updatePosition(currentStamp)
 		}
 		if !current.refill() {
 			r.myLog.Debug("exhausted", log.String("provider", string(selector)))
 			pData = append(pData[:currentIdx], pData[currentIdx+1:]...)
 			if len(pData) == 0 {
 				r.myLog.Debug("All providers exhausted")
+				if err := r.flushChaos(); err != nil {
+					r.handlePublishError(err, selector)
+					return
+				}
+				// the providers also stop when the replay is canceled
+				r.completed = r.localCtx.Err() == nil
 				return
 			}
 		}
 	}
 }
 
+func (r *ReplayTask) handlePublishError(err error, selector providerType) {
+	defer r.localCancel()
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		r.myLog.Debug("context done while publishing")
+		return
+	}
+	if st, ok := status.FromError(err); ok {
+		//nolint:exhaustive // false positive
+		switch st.Code() {
+		case codes.DeadlineExceeded, codes.Canceled, codes.Aborted:
+			r.myLog.Debug("context deadline exceeded")
+			return
+		}
+	}
+	r.myLog.Error("Error publishing data",
+		log.String("provider", string(selector)),
+		log.String("event", r.event.Key),
+		log.ErrorField(err))
+}
+
 func (r *ReplayTask) computeFastForwardStop(cur time.Time) {
 	if r.fastForward > 0 && r.ffStopTime.IsZero() {
 		r.ffStopTime = cur.Add(r.fastForward)
@@ -331,34 +458,14 @@ func (r *ReplayTask) computeFastForwardStop(cur time.Time) {
 	}
 }
 
-func (r *ReplayTask) calcWaitTime(
-	nextTS, lastTS time.Time,
-	sType commonv1.SessionType,
-) time.Duration {
-	// we don't want to wait for messages prior to race start if ffPreRace is set
-	if r.ffPreRace && sType != commonv1.SessionType_SESSION_TYPE_RACE {
-		return 0
-	}
-	delta := nextTS.Sub(lastTS)
-
-	// handle fast forward
-	if nextTS.Before(r.ffStopTime) {
-		return 0
-	}
-	if r.speed > 0 {
-		return time.Duration(int(delta.Nanoseconds()) / r.speed)
-	} else {
-		return delta
-	}
-}
-
 func (r *ReplayTask) provideDriverData() {
 	defer r.wg.Done()
 	i := 0
 	for {
-		item := r.dataProvider.NextDriverData()
+		item := r.nextDriverData()
 		if item == nil {
 			r.myLog.Debug("No more driver data")
+			r.checkProviderError()
 			close(r.driverDataChan)
 			return
 		}
@@ -374,6 +481,16 @@ func (r *ReplayTask) provideDriverData() {
 	}
 }
 
+// nextDriverData returns the driver data read before the event was registered
+// first
+func (r *ReplayTask) nextDriverData() *racestatev1.PublishDriverDataRequest {
+	if item := r.firstDriverData; item != nil {
+		r.firstDriverData = nil
+		return item
+	}
+	return r.dataProvider.NextDriverData()
+}
+
 func (r *ReplayTask) provideStateData() {
 	defer r.wg.Done()
 	i := 0
@@ -381,6 +498,7 @@ func (r *ReplayTask) provideStateData() {
 		item := r.dataProvider.NextStateData()
 		if item == nil {
 			r.myLog.Debug("No more state data")
+			r.checkProviderError()
 			close(r.stateChan)
 			return
 		}
@@ -403,6 +521,7 @@ func (r *ReplayTask) provideSpeedmapData() {
 		item := r.dataProvider.NextSpeedmapData()
 		if item == nil {
 			r.myLog.Debug("No more speedmap data")
+			r.checkProviderError()
 			close(r.speedmapChan)
 			return
 		}
@@ -418,36 +537,6 @@ func (r *ReplayTask) provideSpeedmapData() {
 	}
 }
 
-//nolint:whitespace // by design
-func (r *ReplayTask) registerEvent(eventReq *providerv1.RegisterEventRequest) (
-	*eventv1.Event, error,
-) {
-	resp, err := r.providerService.RegisterEvent(r.prepOutgoingContext(r.ctx), eventReq)
-	if err == nil {
-		return resp.Event, nil
-	}
-	return nil, err
-}
-
-func (r *ReplayTask) unregisterEvent() error {
-	req := &providerv1.UnregisterEventRequest{
-		EventSelector: r.buildEventSelector(),
-	}
-	_, err := r.providerService.UnregisterEvent(
-		r.prepOutgoingContext(context.Background()),
-		req)
-	return err
-}
-
 func (r *ReplayTask) buildEventSelector() *commonv1.EventSelector {
 	return &commonv1.EventSelector{Arg: &commonv1.EventSelector_Key{Key: r.event.Key}}
 }
-
-// helper to add the api-token to the outgoing context
-func (r *ReplayTask) prepOutgoingContext(ctx context.Context) context.Context {
-	if r.tokenProvider != nil {
-		md := metadata.Pairs("api-token", r.tokenProvider())
-		return metadata.NewOutgoingContext(ctx, md)
-	}
-	return ctx
-}
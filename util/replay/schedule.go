@@ -0,0 +1,206 @@
+package replay
+
+import (
+	"fmt"
+	"time"
+
+	commonv1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/common/v1"
+
+	"github.com/mpapenbr/iracelog-cli/log"
+)
+
+// Data is sent according to an absolute schedule. The schedule is anchored at
+// the wall clock time when the replay started (or was re-anchored), so the time
+// needed for publishing doesn't accumulate over the replay.
+// If the replay is behind schedule data is sent without waiting until the
+// replay has caught up.
+
+// DriftStats collects the difference between the scheduled and the actual
+// send time of published data
+type DriftStats struct {
+	Count int
+	Total time.Duration
+	Max   time.Duration
+}
+
+// WithCatchUpLimit limits how far the replay may fall behind the schedule.
+// If the replay is further behind the schedule is re-anchored instead of
+// sending the delayed data as fast as possible. 0 means: always catch up
+func WithCatchUpLimit(d time.Duration) ReplayOption {
+	return func(r *ReplayTask) {
+		r.catchUpLimit = d
+	}
+}
+
+func (d *DriftStats) add(drift time.Duration) {
+	d.Count++
+	d.Total += drift
+	d.Max = max(d.Max, drift)
+}
+
+func (d *DriftStats) Add(other *DriftStats) {
+	d.Count += other.Count
+	d.Total += other.Total
+	d.Max = max(d.Max, other.Max)
+}
+
+func (d *DriftStats) Mean() time.Duration {
+	if d.Count == 0 {
+		return 0
+	}
+	return d.Total / time.Duration(d.Count)
+}
+
+func (d *DriftStats) String() string {
+	return fmt.Sprintf("max: %s, mean: %s",
+		d.Max.Round(time.Microsecond), d.Mean().Round(time.Microsecond))
+}
+
+// schedule maps data timestamps to wall clock times
+type schedule struct {
+	wall  time.Time // wall clock time of ts (zero: not anchored)
+	ts    time.Time
+	speed float64
+}
+
+func (s *schedule) anchored() bool {
+	return !s.wall.IsZero()
+}
+
+// due returns the wall clock time when data with ts should be sent
+func (s *schedule) due(ts time.Time) time.Time {
+	return s.wall.Add(time.Duration(float64(ts.Sub(s.ts)) / s.speed))
+}
+
+// position returns the data timestamp which is due at the given wall clock time
+func (s *schedule) position(wall time.Time) time.Time {
+	return s.ts.Add(time.Duration(float64(wall.Sub(s.wall)) * s.speed))
+}
+
+// changeSpeed re-anchors the schedule at the current position
+func (s *schedule) changeSpeed(now time.Time, speed float64) {
+	if !s.anchored() || speed <= 0 {
+		*s = schedule{}
+		return
+	}
+	*s = schedule{wall: now, ts: s.position(now), speed: speed}
+}
+
+// shift moves the schedule, used to skip the time the replay was paused
+func (s *schedule) shift(d time.Duration) {
+	if s.anchored() {
+		s.wall = s.wall.Add(d)
+	}
+}
+
+// resetSchedule re-anchors the schedule at the next data.
+// Used after data was skipped.
+func (r *ReplayTask) resetSchedule() {
+	r.ctrlMu.Lock()
+	defer r.ctrlMu.Unlock()
+	r.sched = schedule{}
+}
+
+// sendImmediately reports if data should be sent without waiting.
+// must be called with ctrlMu held
+//
+//nolint:whitespace // by design
+func (r *ReplayTask) sendImmediately(
+	nextTS time.Time,
+	sType commonv1.SessionType,
+) bool {
+	switch {
+	case r.seeking:
+		return true
+	// we don't want to wait for messages prior to race start if ffPreRace is set
+	case r.ffPreRace && sType != commonv1.SessionType_SESSION_TYPE_RACE:
+		return true
+	// handle fast forward
+	case nextTS.Before(r.ffStopTime):
+		return true
+	case r.speed <= 0:
+		return true // as fast as possible
+	default:
+		return false
+	}
+}
+
+// dueTime returns the wall clock time when the data with nextTS should be sent.
+// The zero time is returned if the data should be sent immediately.
+// After sending data immediately the schedule is anchored at the last data.
+// must be called with ctrlMu held
+//
+//nolint:whitespace // by design
+func (r *ReplayTask) dueTime(
+	nextTS, lastTS time.Time,
+	sType commonv1.SessionType,
+) time.Time {
+	if r.sendImmediately(nextTS, sType) {
+		r.sched = schedule{}
+		return time.Time{}
+	}
+	now := r.now()
+	if !r.sched.anchored() {
+		base := lastTS
+		if base.IsZero() {
+			base = nextTS
+		}
+		r.sched = schedule{wall: now, ts: base, speed: r.speed}
+	}
+	due := r.sched.due(nextTS)
+	if behind := now.Sub(due); r.catchUpLimit > 0 && behind > r.catchUpLimit {
+		r.myLog.Warn("replay behind schedule, re-anchoring",
+			log.Duration("behind", behind))
+		r.sched = schedule{wall: now, ts: nextTS, speed: r.speed}
+		due = now
+	}
+	return due
+}
+
+// waitForNext blocks until the message with nextTS is due.
+// Pause, speed changes and seek requests are honored while waiting.
+// Returns the time when the message was due (zero if not scheduled)
+// and false if the replay context is done.
+//
+//nolint:whitespace // by design
+func (r *ReplayTask) waitForNext(
+	nextTS, lastTS time.Time,
+	sType commonv1.SessionType,
+) (due time.Time, ok bool) {
+	for {
+		changed := r.controlChanged()
+		if r.IsPaused() {
+			select {
+			case <-changed:
+				continue
+			case <-r.localCtx.Done():
+				return time.Time{}, false
+			}
+		}
+		r.ctrlMu.Lock()
+		due = r.dueTime(nextTS, lastTS, sType)
+		r.ctrlMu.Unlock()
+		if r.dryRun != nil {
+			r.dryRun.advance(due)
+			return due, true
+		}
+		wait := time.Until(due)
+		if due.IsZero() || wait <= 0 {
+			return due, true
+		}
+		r.myLog.Debug("Sleeping",
+			log.Time("time", nextTS),
+			log.Duration("wait", wait),
+		)
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+			return due, true
+		case <-changed:
+			timer.Stop()
+		case <-r.localCtx.Done():
+			timer.Stop()
+			return time.Time{}, false
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package replay
+
+import (
+	"testing"
+	"time"
+
+	commonv1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/common/v1"
+
+	"github.com/mpapenbr/iracelog-cli/log"
+)
+
+// scheduleStep is a data item passed to dueTime
+type scheduleStep struct {
+	busy    float64 // seconds spent (for example publishing) before the item
+	ts      float64 // data timestamp (seconds)
+	wantDue float64 // seconds after the replay start, -1: send immediately
+}
+
+//nolint:funlen // table
+func Test_dueTime(t *testing.T) {
+	tests := []struct {
+		name         string
+		speed        float64
+		catchUpLimit time.Duration
+		ffStop       float64 // fast forward until this data timestamp
+		steps        []scheduleStep
+	}{
+		{
+			name:  "publish time doesn't accumulate",
+			speed: 1,
+			steps: []scheduleStep{
+				{busy: 0.3, ts: 0, wantDue: 0.3},
+				{busy: 0.3, ts: 1, wantDue: 1.3},
+				{busy: 0.3, ts: 2, wantDue: 2.3},
+				{busy: 0.3, ts: 3, wantDue: 3.3},
+			},
+		},
+		{
+			name:  "speed",
+			speed: 2,
+			steps: []scheduleStep{
+				{ts: 0, wantDue: 0},
+				{ts: 2, wantDue: 1},
+				{busy: 0.2, ts: 3, wantDue: 1.5},
+			},
+		},
+		{
+			name:  "catch up",
+			speed: 1,
+			steps: []scheduleStep{
+				{ts: 0, wantDue: 0},
+				{busy: 2.5, ts: 1, wantDue: 1}, // behind, sent at once
+				{ts: 2, wantDue: 2},
+				{ts: 3, wantDue: 3},
+			},
+		},
+		{
+			name:         "catch up limit re-anchors",
+			speed:        1,
+			catchUpLimit: time.Second,
+			steps: []scheduleStep{
+				{ts: 0, wantDue: 0},
+				{busy: 3, ts: 1, wantDue: 3},
+				{ts: 2, wantDue: 4},
+			},
+		},
+		{
+			name:  "as fast as possible",
+			speed: 0,
+			steps: []scheduleStep{
+				{ts: 0, wantDue: -1},
+				{ts: 10, wantDue: -1},
+			},
+		},
+		{
+			name:   "anchored after fast forward",
+			speed:  1,
+			ffStop: 10,
+			steps: []scheduleStep{
+				{ts: 0, wantDue: -1},
+				{ts: 8, wantDue: -1},
+				{busy: 0.5, ts: 10, wantDue: 2.5},
+				{ts: 11, wantDue: 3.5},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &ReplayTask{
+				myLog:        log.Default(),
+				speed:        tt.speed,
+				catchUpLimit: tt.catchUpLimit,
+				ffStopTime:   testTime(tt.ffStop).AsTime(),
+				dryRun:       &dryRun{clock: testWallStart},
+			}
+			wall := func(sec float64) time.Time {
+				return testWallStart.Add(time.Duration(sec * float64(time.Second)))
+			}
+			lastTS := time.Time{}
+			for i, step := range tt.steps {
+				r.dryRun.advance(r.now().Add(
+					time.Duration(step.busy * float64(time.Second))))
+				nextTS := testTime(step.ts).AsTime()
+				due := r.dueTime(nextTS, lastTS,
+					commonv1.SessionType_SESSION_TYPE_RACE)
+				want := time.Time{}
+				if step.wantDue >= 0 {
+					want = wall(step.wantDue)
+				}
+				if !due.Equal(want) {
+					t.Errorf("step %d: dueTime() = %v, want %v", i, due, want)
+				}
+				r.dryRun.advance(due) // wait until due
+				lastTS = nextTS
+			}
+		})
+	}
+}
+
+func Test_scheduleChangeSpeed(t *testing.T) {
+	start := testWallStart
+	s := schedule{wall: start, ts: testTime(0).AsTime(), speed: 1}
+	// 10s of data were replayed, the next 10s are replayed in 5s
+	s.changeSpeed(start.Add(10*time.Second), 2)
+	got, want := s.due(testTime(20).AsTime()), start.Add(15*time.Second)
+	if !got.Equal(want) {
+		t.Errorf("due() = %v, want %v", got, want)
+	}
+	// a pause of 5s moves the schedule
+	s.shift(5 * time.Second)
+	got, want = s.position(start.Add(20*time.Second)), testTime(20).AsTime()
+	if !got.Equal(want) {
+		t.Errorf("position() = %v, want %v", got, want)
+	}
+	s.changeSpeed(start, 0)
+	if s.anchored() {
+		t.Errorf("anchored() = true after speed 0, want false")
+	}
+}
+
+func Test_DriftStats(t *testing.T) {
+	d := DriftStats{}
+	if d.Mean() != 0 {
+		t.Errorf("Mean() = %v on empty stats, want 0", d.Mean())
+	}
+	for _, drift := range []time.Duration{time.Millisecond, 5 * time.Millisecond} {
+		d.add(drift)
+	}
+	other := DriftStats{}
+	other.add(3 * time.Millisecond)
+	d.Add(&other)
+	if d.Count != 3 || d.Max != 5*time.Millisecond || d.Mean() != 3*time.Millisecond {
+		t.Errorf("stats = %d/%v/%v, want 3/5ms/3ms", d.Count, d.Max, d.Mean())
+	}
+}
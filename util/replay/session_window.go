@@ -0,0 +1,166 @@
+package replay
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"time"
+
+	commonv1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/common/v1"
+	eventv1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/event/v1"
+
+	"github.com/mpapenbr/iracelog-cli/log"
+)
+
+// SessionWindow limits the replay to a range of session time within a session.
+// Data before the window is not published, except for the latest driver and
+// speedmap data which are published when the window starts.
+// The replay stops when the end of the window is reached.
+type SessionWindow struct {
+	SessionNum uint32
+	From       float64 // session time in seconds, 0: from session start
+	To         float64 // session time in seconds, 0: until session end
+}
+
+type windowPos int
+
+const (
+	windowBefore windowPos = iota
+	windowInside
+	windowAfter
+)
+
+func WithSessionWindow(w *SessionWindow) ReplayOption {
+	return func(r *ReplayTask) {
+		r.window = w
+	}
+}
+
+// SessionWindow builds the session window for the event.
+// Unset (nil) config values are taken from the event's ReplayInfo.
+// If no session num is configured the (last) race session is used.
+// Returns nil if no bounds are set at all.
+func (c *Config) SessionWindow(e *eventv1.Event) *SessionWindow {
+	bound := func(d *time.Duration, stored float64) float64 {
+		if d != nil {
+			return d.Seconds()
+		}
+		return stored
+	}
+	// without ReplayInfo the getters return 0
+	from := bound(c.FromSessionTime, float64(e.GetReplayInfo().GetMinSessionTime()))
+	to := bound(c.ToSessionTime, float64(e.GetReplayInfo().GetMaxSessionTime()))
+	if from == 0 && to == 0 && c.SessionNum < 0 {
+		return nil
+	}
+	ret := &SessionWindow{From: from, To: to}
+	if c.SessionNum >= 0 {
+		ret.SessionNum = uint32(c.SessionNum)
+	} else {
+		ret.SessionNum = raceSessionNum(e)
+	}
+	return ret
+}
+
+func (w *SessionWindow) String() string {
+	toSecs := func(v float64) time.Duration {
+		return time.Duration(v * float64(time.Second)).Round(time.Second)
+	}
+	to := "end"
+	if w.To > 0 {
+		to = toSecs(w.To).String()
+	}
+	return fmt.Sprintf("session %d %s-%s", w.SessionNum, toSecs(w.From), to)
+}
+
+// raceSessionNum returns the num of the last race session (or the last session)
+func raceSessionNum(e *eventv1.Event) uint32 {
+	for i := len(e.Sessions) - 1; i >= 0; i-- {
+		if e.Sessions[i].Type == commonv1.SessionType_SESSION_TYPE_RACE {
+			return e.Sessions[i].Num
+		}
+	}
+	if len(e.Sessions) > 0 {
+		return e.Sessions[len(e.Sessions)-1].Num
+	}
+	return 0
+}
+
+// checkWindow determines the position of the data relative to the session window.
+// Speedmap data doesn't carry the session num, so it follows the other data.
+func (r *ReplayTask) checkWindow(s *stampInfo, p providerType) windowPos {
+	w := r.window
+	if p == SpeedmapData {
+		if r.windowStarted {
+			return windowInside
+		}
+		return windowBefore
+	}
+	switch {
+	case s.sessionNum < w.SessionNum:
+		return windowBefore
+	case s.sessionNum > w.SessionNum:
+		return windowAfter
+	case s.sessionTime < w.From:
+		return windowBefore
+	case w.To > 0 && s.sessionTime > w.To:
+		return windowAfter
+	default:
+		return windowInside
+	}
+}
+
+// applyWindow checks the data against the session window (if any).
+// Data before the window is kept as pending and must not be published.
+// done is true if the end of the window is reached.
+//
+//nolint:whitespace // by design
+func (r *ReplayTask) applyWindow(current peek, s *stampInfo) (
+	doPublish, done bool, err error,
+) {
+	if r.window == nil {
+		return true, false, nil
+	}
+	switch r.checkWindow(s, current.provider()) {
+	case windowBefore:
+		if r.pending == nil {
+			r.pending = make(map[providerType]peek)
+		}
+		r.pending[current.provider()] = current.clone()
+		return false, false, nil
+	case windowAfter:
+		return false, true, nil
+	case windowInside:
+		if !r.windowStarted {
+			if err := r.startWindow(current); err != nil {
+				return false, false, err
+			}
+		}
+	}
+	return true, false, nil
+}
+
+// startWindow publishes the latest data of the other providers seen before
+// the window started. The data is published in the same order as the
+// main loop would have published it. The transformers are seeded with the
+// latest driver data first, so the pending data is transformed with it.
+func (r *ReplayTask) startWindow(current peek) error {
+	r.windowStarted = true
+	r.myLog.Info("session window started", log.Stringer("window", r.window))
+	if current.provider() == DriverData {
+		r.seedFrom(current)
+	} else if p, ok := r.pending[DriverData]; ok {
+		r.seedFrom(p)
+	}
+	for _, p := range slices.SortedFunc(maps.Values(r.pending), comparePeek) {
+		if p.provider() == current.provider() {
+			continue
+		}
+		if err := r.publish(p); err != nil {
+			return err
+		}
+		r.recordPublished(p, time.Time{}, time.Time{})
+	}
+	r.pending = nil
+	return nil
+}
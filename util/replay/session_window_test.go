@@ -0,0 +1,74 @@
+package replay
+
+import (
+	"testing"
+	"time"
+
+	commonv1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/common/v1"
+	eventv1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/event/v1"
+)
+
+// verifies that the data seen before the window is published in a fixed
+// order when the window starts
+func Test_startWindowOrder(t *testing.T) {
+	at := func(sec float64) time.Time { return testTime(sec).AsTime() }
+	now := testWallStart
+	want := []publishedRecord{
+		{RecordRegister, now, time.Time{}},
+		{RecordDriver, now, at(8)},
+		{RecordSpeedmap, now, at(8)}, // driver data wins on equal timestamps
+		{RecordState, now, at(10)},
+		{RecordDriver, now, at(12)},
+		{RecordSpeedmap, now, at(14)},
+		{RecordState, now, at(15)},
+		{RecordUnregister, now, time.Time{}},
+	}
+	// the pending data was kept in a map, so a single run could pass by chance
+	for range 20 {
+		provider := newTestProvider(
+			[]float64{0, 5, 10, 15},
+			[]float64{4, 8, 12},
+			[]float64{6, 8, 14})
+		got := dryRunRecords(t, provider,
+			WithSessionWindow(&SessionWindow{SessionNum: 0, From: 10}))
+		checkRecords(t, got, want)
+	}
+}
+
+func Test_ConfigSessionWindow(t *testing.T) {
+	dur := func(d time.Duration) *time.Duration { return &d }
+	event := &eventv1.Event{
+		Sessions: []*eventv1.Session{
+			{Num: 0, Type: commonv1.SessionType_SESSION_TYPE_PRACTICE},
+			{Num: 1, Type: commonv1.SessionType_SESSION_TYPE_RACE},
+		},
+		ReplayInfo: &eventv1.ReplayInfo{MinSessionTime: 100, MaxSessionTime: 200},
+	}
+	tests := []struct {
+		name string
+		from *time.Duration
+		to   *time.Duration
+		want SessionWindow
+	}{
+		{name: "replay info", want: SessionWindow{SessionNum: 1, From: 100, To: 200}},
+		{
+			name: "explicit bounds", from: dur(50 * time.Second), to: dur(150 * time.Second),
+			want: SessionWindow{SessionNum: 1, From: 50, To: 150},
+		},
+		{
+			name: "explicit zero from", from: dur(0),
+			want: SessionWindow{SessionNum: 1, From: 0, To: 200},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := DefaultConfig()
+			cfg.FromSessionTime = tt.from
+			cfg.ToSessionTime = tt.to
+			got := cfg.SessionWindow(event)
+			if got == nil || *got != tt.want {
+				t.Errorf("SessionWindow() = %v, want %v", got, &tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,195 @@
+package replay
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	commonv1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/common/v1"
+	"github.com/dustin/go-humanize"
+
+	"github.com/mpapenbr/iracelog-cli/log"
+	"github.com/mpapenbr/iracelog-cli/util/simulate"
+)
+
+// Stats contains the progress information of a replay
+type Stats struct {
+	Driver      simulate.DataStat
+	Speedmap    simulate.DataStat
+	State       simulate.DataStat
+	SessionNum  uint32
+	SessionTime float64       // session time (seconds) of the last published data
+	Lag         time.Duration // how much the replay is behind schedule
+	Drift       DriftStats    // deviations from the schedule
+	Remaining   time.Duration // estimated remaining wall clock time (0: unknown)
+}
+
+func WithStatsCallback(d time.Duration, callback func(*Stats)) ReplayOption {
+	return func(r *ReplayTask) {
+		r.statsCallback = callback
+		r.statsCallbackDuration = d
+	}
+}
+
+// GetStats returns a snapshot of the current replay stats
+func (r *ReplayTask) GetStats() Stats {
+	r.statsMu.Lock()
+	ret := r.stats
+	r.statsMu.Unlock()
+	ret.SessionNum, ret.SessionTime = r.Position()
+	ret.Remaining = r.estimateRemaining(ret.SessionNum, ret.SessionTime)
+	return ret
+}
+
+func (s *Stats) Add(other *Stats) {
+	s.Driver.Add(&other.Driver)
+	s.Speedmap.Add(&other.Speedmap)
+	s.State.Add(&other.State)
+	s.Drift.Add(&other.Drift)
+}
+
+func (s *Stats) Bytes() uint {
+	return s.Driver.Bytes + s.Speedmap.Bytes + s.State.Bytes
+}
+
+func (s *Stats) String() string {
+	return fmt.Sprintf("Driver: %s, Speedmap: %s, State: %s",
+		s.Driver.String(), s.Speedmap.String(), s.State.String())
+}
+
+// Progress returns a compact progress line
+func (s *Stats) Progress() string {
+	eta := "unknown"
+	if s.Remaining > 0 {
+		eta = s.Remaining.Round(time.Second).String()
+	}
+	return fmt.Sprintf("session %d at %s, msgs: %d (%s), lag: %s, eta: %s",
+		s.SessionNum,
+		time.Duration(s.SessionTime*float64(time.Second)).Round(time.Second),
+		s.Driver.Count+s.Speedmap.Count+s.State.Count,
+		humanize.IBytes(uint64(s.Bytes())),
+		s.Lag.Round(time.Millisecond),
+		eta)
+}
+
+// startStatsCallback calls the stats callback periodically until the
+// replay is done. The callback is called a final time when done.
+func (r *ReplayTask) startStatsCallback() (stop func()) {
+	if r.statsCallbackDuration <= 0 || r.statsCallback == nil {
+		return func() {}
+	}
+	done := make(chan struct{})
+	finished := make(chan struct{})
+	ticker := time.NewTicker(r.statsCallbackDuration)
+	go func() {
+		defer close(finished)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				s := r.GetStats()
+				r.statsCallback(&s)
+				return
+			case <-ticker.C:
+				s := r.GetStats()
+				r.statsCallback(&s)
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		<-finished
+	}
+}
+
+// recordPublished updates the stats after data was published.
+// due is the scheduled send time (zero if not scheduled), sent the actual one.
+func (r *ReplayTask) recordPublished(p peek, due, sent time.Time) {
+	r.statsMu.Lock()
+	defer r.statsMu.Unlock()
+	var ds *simulate.DataStat
+	switch p.provider() {
+	case DriverData:
+		ds = &r.stats.Driver
+	case StateData:
+		ds = &r.stats.State
+	case SpeedmapData:
+		ds = &r.stats.Speedmap
+	}
+	ds.Count++
+	ds.Bytes += uint(p.size())
+	if !due.IsZero() {
+		r.stats.Lag = sent.Sub(due)
+		r.stats.Drift.add(r.stats.Lag)
+	}
+}
+
+// estimateRemaining estimates the remaining wall clock time based on the
+// configured session lengths of the event
+func (r *ReplayTask) estimateRemaining(sessionNum uint32, cur float64) time.Duration {
+	speed := r.Speed()
+	if r.eventData == nil || speed <= 0 {
+		return 0
+	}
+	remain := 0.0
+	for _, s := range r.eventData.Sessions {
+		if s.Num < sessionNum {
+			continue
+		}
+		if r.ffPreRace && s.Type != commonv1.SessionType_SESSION_TYPE_RACE {
+			continue
+		}
+		end := float64(s.SessionTime)
+		if r.window != nil {
+			if s.Num != r.window.SessionNum {
+				continue
+			}
+			if r.window.To > 0 {
+				end = r.window.To
+			}
+		}
+		if s.Num == sessionNum {
+			end -= cur
+		}
+		remain += max(end, 0)
+	}
+	return time.Duration(remain / speed * float64(time.Second))
+}
+
+// StatsSummary aggregates the stats of replay tasks per worker
+type StatsSummary struct {
+	stats  []Stats
+	mu     sync.Mutex
+	logger *log.Logger
+}
+
+func NewStatsSummary(numWorker int, logger *log.Logger) *StatsSummary {
+	return &StatsSummary{
+		stats:  make([]Stats, numWorker),
+		mu:     sync.Mutex{},
+		logger: logger,
+	}
+}
+
+func (w *StatsSummary) AddStats(workerID int, s *Stats) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.stats[workerID].Add(s)
+}
+
+func (w *StatsSummary) Output(numWorkers int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	totals := Stats{}
+	s := w.stats[:min(numWorkers, len(w.stats))]
+	for i := range s {
+		totals.Add(&s[i])
+		w.logger.Info("summary",
+			log.Int("workerId", i),
+			log.String("stats", s[i].String()))
+	}
+	w.logger.Info("totals",
+		log.Int("totalWorkers", len(s)),
+		log.String("totalBytes", humanize.IBytes(uint64(totals.Bytes()))),
+		log.String("totals", totals.String()))
+}
@@ -0,0 +1,296 @@
+package replay
+
+import (
+	"cmp"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	providerv1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/provider/v1"
+	racestatev1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/racestate/v1"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Transformer modifies data before it is published. Unset funcs are skipped.
+// Transformers may keep state, so each ReplayTask needs its own instances.
+// State that has to survive a resumed replay is kept in the checkpoint by
+// SaveState and RestoreState.
+// Seed is called with driver data that is not published (yet), e.g. the
+// first driver data before the event is registered or driver data before a
+// session window. It must not modify req.
+type Transformer struct {
+	Event        func(req *providerv1.RegisterEventRequest)
+	State        func(req *racestatev1.PublishStateRequest)
+	Speedmap     func(req *racestatev1.PublishSpeedmapRequest)
+	DriverData   func(req *racestatev1.PublishDriverDataRequest)
+	Seed         func(req *racestatev1.PublishDriverDataRequest)
+	SaveState    func(cp *Checkpoint)
+	RestoreState func(cp *Checkpoint)
+}
+
+// WithTransformer adds transformers to the chain.
+// Transformers are applied in the order they are added.
+func WithTransformer(t ...*Transformer) ReplayOption {
+	return func(r *ReplayTask) {
+		r.transformers = append(r.transformers, t...)
+	}
+}
+
+func (r *ReplayTask) transformEvent(req *providerv1.RegisterEventRequest) {
+	for _, t := range r.transformers {
+		if t.Event != nil {
+			t.Event(req)
+		}
+	}
+}
+
+func (r *ReplayTask) seedTransformers(req *racestatev1.PublishDriverDataRequest) {
+	for _, t := range r.transformers {
+		if t.Seed != nil {
+			t.Seed(req)
+		}
+	}
+}
+
+// seedFrom seeds the transformers if p contains driver data
+func (r *ReplayTask) seedFrom(p peek) {
+	if d, ok := p.(*peekDriverData); ok {
+		r.seedTransformers(d.dataReq)
+	}
+}
+
+// seedSameTime seeds the transformers with the driver data which has the same
+// timestamp as the current state data. The state is published first on equal
+// timestamps but may already refer to the drivers of that driver data.
+func (r *ReplayTask) seedSameTime(pData []peek, current peek) {
+	if current.provider() != StateData {
+		return
+	}
+	for _, p := range pData {
+		if p.provider() == DriverData && p.stamp().ts.Equal(current.stamp().ts) {
+			r.seedFrom(p)
+		}
+	}
+}
+
+func (p *peekStateData) transform() {
+	for _, t := range p.r.transformers {
+		if t.State != nil {
+			t.State(p.dataReq)
+		}
+	}
+}
+
+func (p *peekSpeedmapData) transform() {
+	for _, t := range p.r.transformers {
+		if t.Speedmap != nil {
+			t.Speedmap(p.dataReq)
+		}
+	}
+}
+
+func (p *peekDriverData) transform() {
+	for _, t := range p.r.transformers {
+		if t.DriverData != nil {
+			t.DriverData(p.dataReq)
+		}
+	}
+}
+
+// RenameEventTransformer sets the name of the replayed event
+func RenameEventTransformer(name string) *Transformer {
+	return &Transformer{
+		Event: func(req *providerv1.RegisterEventRequest) {
+			req.Event.Name = name
+		},
+	}
+}
+
+// RetimeTransformer shifts all timestamps so the replayed event appears to
+// happen now. The offset is computed from the first published data.
+// A resumed replay continues with the offset of the checkpoint.
+func RetimeTransformer() *Transformer {
+	rt := &retimer{}
+	return &Transformer{
+		Event: func(req *providerv1.RegisterEventRequest) {
+			req.Event.EventTime = timestamppb.Now()
+		},
+		State: func(req *racestatev1.PublishStateRequest) {
+			req.Timestamp = rt.shift(req.Timestamp)
+		},
+		Speedmap: func(req *racestatev1.PublishSpeedmapRequest) {
+			req.Timestamp = rt.shift(req.Timestamp)
+		},
+		DriverData: func(req *racestatev1.PublishDriverDataRequest) {
+			req.Timestamp = rt.shift(req.Timestamp)
+		},
+		SaveState: func(cp *Checkpoint) {
+			if rt.initialized {
+				offset := rt.offset
+				cp.RetimeOffset = &offset
+			}
+		},
+		RestoreState: func(cp *Checkpoint) {
+			if cp.RetimeOffset != nil {
+				rt.offset = *cp.RetimeOffset
+				rt.initialized = true
+			}
+		},
+	}
+}
+
+type retimer struct {
+	offset      time.Duration
+	initialized bool
+}
+
+func (rt *retimer) shift(ts *timestamppb.Timestamp) *timestamppb.Timestamp {
+	if ts == nil {
+		return nil
+	}
+	if !rt.initialized {
+		rt.offset = time.Since(ts.AsTime())
+		rt.initialized = true
+	}
+	return timestamppb.New(ts.AsTime().Add(rt.offset))
+}
+
+// AnonymizeTransformer replaces driver and team names by generic names.
+// The same real name is always mapped to the same generic name.
+// Names mentioned in race messages are replaced as well. Names of driver data
+// that is not published yet are known by seeding (see Transformer).
+// A resumed replay continues with the names of the checkpoint.
+func AnonymizeTransformer() *Transformer {
+	a := &anonymizer{
+		drivers: make(map[string]int),
+		teams:   make(map[string]int),
+	}
+	return &Transformer{
+		State:      a.state,
+		DriverData: a.driverData,
+		Seed:       a.seed,
+		SaveState: func(cp *Checkpoint) {
+			// names are only added, so the size tells if something changed
+			if len(cp.AnonymizedDrivers) != len(a.drivers) {
+				cp.AnonymizedDrivers = maps.Clone(a.drivers)
+			}
+			if len(cp.AnonymizedTeams) != len(a.teams) {
+				cp.AnonymizedTeams = maps.Clone(a.teams)
+			}
+		},
+		RestoreState: func(cp *Checkpoint) {
+			maps.Copy(a.drivers, cp.AnonymizedDrivers)
+			maps.Copy(a.teams, cp.AnonymizedTeams)
+			a.replacer = nil
+		},
+	}
+}
+
+type anonymizer struct {
+	drivers  map[string]int
+	teams    map[string]int
+	replacer *strings.Replacer // replaces real names in messages
+}
+
+func (a *anonymizer) driverNum(name string) int {
+	if num, ok := a.drivers[name]; ok {
+		return num
+	}
+	num := len(a.drivers) + 1
+	a.drivers[name] = num
+	a.replacer = nil
+	return num
+}
+
+func (a *anonymizer) driverName(name string) string {
+	return fmt.Sprintf("Driver %d", a.driverNum(name))
+}
+
+func (a *anonymizer) teamName(name string) string {
+	// in single driver events the team name is the driver name
+	if num, ok := a.drivers[name]; ok {
+		return fmt.Sprintf("Driver %d", num)
+	}
+	num, ok := a.teams[name]
+	if !ok {
+		num = len(a.teams) + 1
+		a.teams[name] = num
+		a.replacer = nil
+	}
+	return fmt.Sprintf("Team %d", num)
+}
+
+// seed assigns the generic names in the same order as driverData
+func (a *anonymizer) seed(req *racestatev1.PublishDriverDataRequest) {
+	for _, e := range req.Entries {
+		for _, d := range e.Drivers {
+			a.driverNum(d.Name)
+		}
+	}
+	for _, e := range req.Entries {
+		if e.Team != nil {
+			a.teamName(e.Team.Name)
+		}
+	}
+	for _, v := range req.CurrentDrivers {
+		a.driverNum(v)
+	}
+}
+
+func (a *anonymizer) driverData(req *racestatev1.PublishDriverDataRequest) {
+	for _, e := range req.Entries {
+		for _, d := range e.Drivers {
+			num := a.driverNum(d.Name)
+			d.Id = int32(num)
+			d.Name = fmt.Sprintf("Driver %d", num)
+			d.AbbrevName = d.Name
+			d.Initials = fmt.Sprintf("D%d", num)
+		}
+	}
+	for _, e := range req.Entries {
+		if e.Team != nil {
+			e.Team.Name = a.teamName(e.Team.Name)
+		}
+	}
+	for k, v := range req.CurrentDrivers {
+		req.CurrentDrivers[k] = a.driverName(v)
+	}
+}
+
+func (a *anonymizer) state(req *racestatev1.PublishStateRequest) {
+	if len(req.Messages) == 0 {
+		return
+	}
+	r := a.getReplacer()
+	for _, m := range req.Messages {
+		m.Msg = r.Replace(m.Msg)
+	}
+}
+
+func (a *anonymizer) getReplacer() *strings.Replacer {
+	if a.replacer != nil {
+		return a.replacer
+	}
+	type pair struct{ from, to string }
+	pairs := make([]pair, 0, len(a.drivers)+len(a.teams))
+	for name, num := range a.drivers {
+		pairs = append(pairs, pair{name, fmt.Sprintf("Driver %d", num)})
+	}
+	for name, num := range a.teams {
+		pairs = append(pairs, pair{name, fmt.Sprintf("Team %d", num)})
+	}
+	// longer names first, so that names containing other names are replaced
+	slices.SortFunc(pairs, func(x, y pair) int {
+		return cmp.Compare(len(y.from), len(x.from))
+	})
+	args := make([]string, 0, 2*len(pairs))
+	for _, p := range pairs {
+		if p.from != "" {
+			args = append(args, p.from, p.to)
+		}
+	}
+	a.replacer = strings.NewReplacer(args...)
+	return a.replacer
+}
@@ -0,0 +1,93 @@
+package replay
+
+import (
+	"slices"
+	"testing"
+
+	carv1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/car/v1"
+	racestatev1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/racestate/v1"
+)
+
+// namedDriver returns driver data with a single car driven by the drivers.
+// The last driver is the current one.
+//
+//nolint:whitespace // by design
+func namedDriver(
+	sec float64,
+	drivers ...string,
+) *racestatev1.PublishDriverDataRequest {
+	ret := testDriver(sec)
+	entry := &carv1.CarEntry{
+		Car:  &carv1.CarInfo{CarIdx: 0, CarNumber: "1"},
+		Team: &carv1.Team{Name: "Real Team"},
+	}
+	for _, name := range drivers {
+		entry.Drivers = append(entry.Drivers, &carv1.Driver{Name: name})
+	}
+	ret.Entries = []*carv1.CarEntry{entry}
+	ret.CurrentDrivers = map[uint32]string{0: drivers[len(drivers)-1]}
+	return ret
+}
+
+func messageState(sec float64, msg string) *racestatev1.PublishStateRequest {
+	ret := testState(sec)
+	ret.Messages = []*racestatev1.Message{{CarIdx: 0, CarNum: "1", Msg: msg}}
+	return ret
+}
+
+//nolint:funlen // table
+func Test_anonymize(t *testing.T) {
+	tests := []struct {
+		name    string
+		states  []*racestatev1.PublishStateRequest
+		drivers []*racestatev1.PublishDriverDataRequest
+		want    []string
+	}{
+		{
+			name: "first data",
+			states: []*racestatev1.PublishStateRequest{
+				messageState(0, "Alice Real entered the pits"),
+			},
+			drivers: []*racestatev1.PublishDriverDataRequest{
+				namedDriver(0, "Alice Real"),
+			},
+			want: []string{"Driver 1 entered the pits"},
+		},
+		{
+			// the state is published before the driver data on equal timestamps
+			name: "driver change",
+			states: []*racestatev1.PublishStateRequest{
+				messageState(0, "Alice Real entered the pits"),
+				messageState(5, "Bob Real took over for Real Team"),
+			},
+			drivers: []*racestatev1.PublishDriverDataRequest{
+				namedDriver(0, "Alice Real"),
+				namedDriver(5, "Alice Real", "Bob Real"),
+			},
+			want: []string{
+				"Driver 1 entered the pits",
+				"Driver 2 took over for Team 1",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider := newTestProvider(nil, nil, nil)
+			provider.states = tt.states
+			provider.drivers = tt.drivers
+			got := []string{}
+			recorder := &Transformer{
+				State: func(req *racestatev1.PublishStateRequest) {
+					for _, m := range req.Messages {
+						got = append(got, m.Msg)
+					}
+				},
+			}
+			dryRunRecords(t, provider,
+				WithTransformer(AnonymizeTransformer(), recorder))
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("messages = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,152 @@
+// Package stream provides a helper for long-running live subscriptions.
+// Failed streams are reopened with exponential backoff unless the error
+// indicates that retrying is pointless (for example the event has ended).
+package stream
+
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/mpapenbr/iracelog-cli/config"
+	"github.com/mpapenbr/iracelog-cli/log"
+)
+
+type (
+	Option func(*param)
+	param  struct {
+		initialBackoff time.Duration
+		maxBackoff     time.Duration
+		maxRetries     int // 0: retry forever
+	}
+	// OpenFunc opens the stream. resume is true if the stream is reopened
+	// after an error.
+	OpenFunc[T any] func(ctx context.Context, resume bool) (
+		grpc.ServerStreamingClient[T], error)
+)
+
+var ErrTooManyRetries = errors.New("too many retries")
+
+func WithBackoff(initial, maxBackoff time.Duration) Option {
+	return func(p *param) {
+		p.initialBackoff = initial
+		p.maxBackoff = maxBackoff
+	}
+}
+
+func WithMaxRetries(n int) Option {
+	return func(p *param) {
+		p.maxRetries = n
+	}
+}
+
+func WithCliArgs(args *config.CliArgs) Option {
+	return func(p *param) {
+		if args.LiveMaxBackoff > 0 {
+			p.maxBackoff = args.LiveMaxBackoff
+		}
+		p.maxRetries = args.LiveMaxRetries
+	}
+}
+
+// Subscribe receives the stream opened by open and calls handle for each
+// message until the context is done or the stream ends.
+// The stream is reopened on retryable errors. The retry counter and the
+// backoff are reset once a message was received.
+// Returns nil if the stream ended regularly (including NotFound once the
+// event has ended) or the context is done.
+//
+//nolint:whitespace // by design
+func Subscribe[T any](
+	ctx context.Context,
+	name string,
+	open OpenFunc[T],
+	handle func(*T),
+	opts ...Option,
+) error {
+	p := &param{initialBackoff: time.Second, maxBackoff: 30 * time.Second}
+	for _, opt := range opts {
+		opt(p)
+	}
+	logger := log.Default().Named(name)
+	backoff := p.initialBackoff
+	retries := 0
+	for resume := false; ; resume = true {
+		received, err := receive(ctx, open, handle, resume)
+		if received {
+			backoff = p.initialBackoff
+			retries = 0
+		}
+		switch {
+		case ctx.Err() != nil:
+			return nil
+		case errors.Is(err, io.EOF):
+			logger.Info("server closed stream")
+			return nil
+		case status.Code(err) == codes.NotFound:
+			logger.Info("event is no longer available")
+			return nil
+		case !retryable(err):
+			return err
+		}
+		retries++
+		if p.maxRetries > 0 && retries > p.maxRetries {
+			return errors.Join(ErrTooManyRetries, err)
+		}
+		logger.Warn("stream failed, reconnecting",
+			log.ErrorField(err),
+			log.Int("retry", retries),
+			log.Duration("backoff", backoff))
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(backoff):
+		}
+		backoff = min(2*backoff, p.maxBackoff)
+	}
+}
+
+// receive opens the stream and handles the messages until an error occurs.
+//
+//nolint:whitespace // by design
+func receive[T any](
+	ctx context.Context,
+	open OpenFunc[T],
+	handle func(*T),
+	resume bool,
+) (received bool, err error) {
+	s, err := open(ctx, resume)
+	if err != nil {
+		return false, err
+	}
+	for {
+		resp, err := s.Recv()
+		if err != nil {
+			return received, err
+		}
+		received = true
+		handle(resp)
+	}
+}
+
+// retryable returns false for errors where reconnecting won't help.
+// NotFound is returned by the server once the event has ended.
+func retryable(err error) bool {
+	//nolint:exhaustive // by design
+	switch status.Code(err) {
+	case codes.NotFound,
+		codes.InvalidArgument,
+		codes.PermissionDenied,
+		codes.Unauthenticated,
+		codes.Unimplemented,
+		codes.Canceled:
+		return false
+	default:
+		return true
+	}
+}